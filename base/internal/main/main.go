@@ -1,8 +1,11 @@
 package main
 
 import (
+	_ "github.com/darlean-io/darlean.go/base/persistence"
 	_ "github.com/darlean-io/darlean.go/base/portal"
 	_ "github.com/darlean-io/darlean.go/base/services/actorregistry"
+	_ "github.com/darlean-io/darlean.go/base/services/persistenceservice"
+	_ "github.com/darlean-io/darlean.go/base/services/tablesservice"
 )
 
 func main() {
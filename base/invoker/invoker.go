@@ -17,6 +17,10 @@ type Request struct {
 	ActionName string
 	Parameters []any
 	Lazy       bool
+	// CallChain contains the ids of the calls that (indirectly) led to the request, one per hop. Receivers
+	// append the id of the call they process, and actors pass the resulting call chain on to the calls they
+	// make, so that receivers can detect reentrant calls. When empty, the request starts a new call chain.
+	CallChain []string
 }
 
 /*
@@ -32,3 +36,30 @@ type Invoker interface {
 	// Invoke performs the request and returns the result value or error.
 	Invoke(request *Request) (variant.Assignable, *actionerror.Error)
 }
+
+type chainInvoker struct {
+	invoker   Invoker
+	callChain []string
+}
+
+func (inv chainInvoker) Invoke(request *Request) (variant.Assignable, *actionerror.Error) {
+	if len(request.CallChain) == 0 {
+		r := *request
+		r.CallChain = inv.callChain
+		request = &r
+	}
+	return inv.invoker.Invoke(request)
+}
+
+// WithCallChain returns an invoker that makes the requests that do not have a call chain part of callChain
+// before passing them on to invoker. Actors use this to make the calls they perform while processing a call
+// part of the call chain of that call.
+func WithCallChain(invoker Invoker, callChain []string) Invoker {
+	if len(callChain) == 0 {
+		return invoker
+	}
+	return chainInvoker{
+		invoker:   invoker,
+		callChain: callChain,
+	}
+}
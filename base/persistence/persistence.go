@@ -0,0 +1,46 @@
+/*
+Package persistence defines the interface for storing actor state.
+
+State is stored per [Key] as an opaque chunk of bytes. Concurrent modifications are detected by means of
+version tokens (optimistic concurrency): every successful store returns a new version token, and a store
+or delete only succeeds when the provided expected version matches the current version of the item.
+*/
+package persistence
+
+import "errors"
+
+// ErrVersionConflict is returned when the expected version does not match the current version of an item.
+var ErrVersionConflict = errors.New("persistence: version conflict")
+
+// VERSION_NONE can be passed as expected version to indicate that the item must not exist yet.
+const VERSION_NONE = ""
+
+// VERSION_ANY can be passed as expected version to store or delete an item regardless of its current version.
+const VERSION_ANY = "*"
+
+// Key identifies one stored item.
+type Key struct {
+	ActorType string
+	ActorId   []string
+	// PartitionKey and SortKey can be used to store multiple items for the same actor instance.
+	PartitionKey []string
+	SortKey      []string
+}
+
+// Item is a stored value with its version token.
+type Item struct {
+	Value   []byte
+	Version string
+}
+
+// Store can load, store and delete items.
+type Store interface {
+	// Load returns the item for key, or nil when it does not exist.
+	Load(key Key) (*Item, error)
+	// Store stores value for key when the current version of the item matches expectedVersion, and
+	// returns the new version. Returns [ErrVersionConflict] when the versions do not match.
+	Store(key Key, value []byte, expectedVersion string) (string, error)
+	// Delete deletes the item for key when the current version of the item matches expectedVersion.
+	// Deleting an item that does not exist is not an error when expectedVersion is [VERSION_NONE] or [VERSION_ANY].
+	Delete(key Key, expectedVersion string) error
+}
@@ -29,8 +29,13 @@ func (proxy ActorProxy[ActorSig]) Invoke(action signature.Action) error {
 	var inputtps = strings.Split(inputtp.Name(), "_")
 	var actionName = inputtps[len(inputtps)-1]
 
+	actorType := strings.ToLower(tp.Name())
+	if named, ok := any(a).(signature.NamedActor); ok {
+		actorType = named.ActorTypeName()
+	}
+
 	req := invoker.Request{
-		ActorType:  strings.ToLower(tp.Name()),
+		ActorType:  actorType,
 		ActorId:    proxy.Id,
 		ActionName: strings.ToLower(actionName),
 	}
@@ -40,14 +45,19 @@ func (proxy ActorProxy[ActorSig]) Invoke(action signature.Action) error {
 			return strings.HasPrefix(name, "A0_")
 		})
 	}
-	a0value := a0.Interface()
-	req.Parameters = []any{a0value}
+	if (a0 != reflect.Value{}) {
+		req.Parameters = []any{a0.Interface()}
+	}
 	resp, err := proxy.Base.Invoke(&req)
 	if err != nil {
 		return err
 	}
 	res := reflect.ValueOf(action)
 	res = res.Elem().FieldByName("Result")
+	if resp == nil || (res == reflect.Value{}) {
+		// Void action, or no result value provided
+		return nil
+	}
 	return resp.AssignToReflectValue(&res)
 }
 
@@ -0,0 +1,88 @@
+/*
+Package persistenceservice contains the data types for and a client to the Darlean persistence service, which
+allows Go actors to share state with actors in other languages.
+
+Use [NewClient] to obtain a client that invokes the persistence service via a portal, or [NewLocal] to obtain
+an in-memory stand-in for tests. Both satisfy [Service].
+*/
+package persistenceservice
+
+import (
+	"github.com/darlean-io/darlean.go/base/portal"
+	"github.com/darlean-io/darlean.go/base/typedportal"
+)
+
+// Client invokes the persistence service via a portal. Satisfies [Service].
+type Client struct {
+	actor *portal.ActorProxy[PersistenceService]
+}
+
+// NewClient returns a client that uses p to invoke the persistence service.
+func NewClient(p portal.Portal) *Client {
+	return &Client{
+		actor: typedportal.ForSignature[PersistenceService](p).Obtain([]string{}),
+	}
+}
+
+func (client *Client) Store(options StoreOptions) error {
+	call := client.actor.NewCall().Store
+	call.A0_Options = options
+	return client.actor.Invoke(&call)
+}
+
+func (client *Client) Load(options LoadOptions) (*LoadResult, error) {
+	call := client.actor.NewCall().Load
+	call.A0_Options = options
+	err := client.actor.Invoke(&call)
+	if err != nil {
+		return nil, err
+	}
+	return &call.Result, nil
+}
+
+func (client *Client) Query(options QueryOptions) (*QueryResult, error) {
+	call := client.actor.NewCall().Query
+	call.A0_Options = options
+	err := client.actor.Invoke(&call)
+	if err != nil {
+		return nil, err
+	}
+	return &call.Result, nil
+}
+
+type DeleteOptions struct {
+	Specifiers   []string
+	PartitionKey []string
+	SortKey      []string
+	Version      string
+}
+
+// Delete deletes an item by storing a nil value.
+func Delete(service Service, options DeleteOptions) error {
+	return service.Store(StoreOptions{
+		Specifiers:   options.Specifiers,
+		PartitionKey: options.PartitionKey,
+		SortKey:      options.SortKey,
+		Version:      options.Version,
+	})
+}
+
+// QueryAll performs the query and invokes handler for every item on every page. It stops
+// when handler returns false or when there are no more items.
+func QueryAll(service Service, options QueryOptions, handler func(item QueryItem) bool) error {
+	for {
+		result, err := service.Query(options)
+		if err != nil {
+			return err
+		}
+		for _, item := range result.Items {
+			if !handler(item) {
+				return nil
+			}
+		}
+		if result.ContinuationToken == "" {
+			return nil
+		}
+		options.ContinuationToken = result.ContinuationToken
+	}
+}
@@ -0,0 +1,171 @@
+package persistenceservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/darlean-io/darlean.go/utils/binary"
+)
+
+type localItem struct {
+	sortKey []string
+	value   []byte
+	version string
+}
+
+// Local is an in-memory stand-in for the persistence service, intended for tests and local development.
+// Satisfies [Service].
+type Local struct {
+	// Map from specifiers + partition key to the items of the partition, ordered by sort key.
+	partitions map[string][]localItem
+	mutex      sync.RWMutex
+}
+
+func NewLocal() *Local {
+	return &Local{
+		partitions: make(map[string][]localItem),
+	}
+}
+
+func (local *Local) Store(options StoreOptions) error {
+	key := partitionName(options.Specifiers, options.PartitionKey)
+	local.mutex.Lock()
+	defer local.mutex.Unlock()
+
+	items := local.partitions[key]
+	idx, found := find(items, options.SortKey)
+	if options.Value == nil {
+		if found {
+			local.partitions[key] = append(items[:idx], items[idx+1:]...)
+		}
+		return nil
+	}
+
+	item := localItem{
+		sortKey: append([]string(nil), options.SortKey...),
+		value:   append([]byte(nil), options.Value.Bytes()...),
+		version: options.Version,
+	}
+	if found {
+		items[idx] = item
+		return nil
+	}
+	items = append(items, localItem{})
+	copy(items[idx+1:], items[idx:])
+	items[idx] = item
+	local.partitions[key] = items
+	return nil
+}
+
+func (local *Local) Load(options LoadOptions) (*LoadResult, error) {
+	key := partitionName(options.Specifiers, options.PartitionKey)
+	local.mutex.RLock()
+	defer local.mutex.RUnlock()
+
+	items := local.partitions[key]
+	idx, found := find(items, options.SortKey)
+	if !found {
+		return &LoadResult{}, nil
+	}
+	value := binary.FromBytes(items[idx].value)
+	return &LoadResult{
+		Value:   &value,
+		Version: items[idx].version,
+	}, nil
+}
+
+func (local *Local) Query(options QueryOptions) (*QueryResult, error) {
+	key := partitionName(options.Specifiers, options.PartitionKey)
+	descending := options.SortKeyOrder == SORT_KEY_ORDER_DESCENDING
+
+	var after []string
+	if options.ContinuationToken != "" {
+		err := decodeToken(options.ContinuationToken, &after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	local.mutex.RLock()
+	defer local.mutex.RUnlock()
+
+	items := local.partitions[key]
+	result := QueryResult{Items: []QueryItem{}}
+	for i := range items {
+		item := items[i]
+		if descending {
+			item = items[len(items)-1-i]
+		}
+		if options.SortKeyFrom != nil && CompareSortKeys(item.sortKey, options.SortKeyFrom) < 0 {
+			continue
+		}
+		if options.SortKeyTo != nil && CompareSortKeys(item.sortKey, options.SortKeyTo) > 0 {
+			continue
+		}
+		if after != nil {
+			c := CompareSortKeys(item.sortKey, after)
+			if (!descending && c <= 0) || (descending && c >= 0) {
+				continue
+			}
+		}
+		if options.MaxItems > 0 && len(result.Items) >= options.MaxItems {
+			last := result.Items[len(result.Items)-1].SortKey
+			token, err := encodeToken(last)
+			if err != nil {
+				return nil, err
+			}
+			result.ContinuationToken = token
+			break
+		}
+		value := binary.FromBytes(item.value)
+		result.Items = append(result.Items, QueryItem{
+			SortKey: item.sortKey,
+			Value:   &value,
+			Version: item.version,
+		})
+	}
+	return &result, nil
+}
+
+// CompareSortKeys compares two sort keys part by part. A key that is a prefix of another key is
+// smaller than that key. Returns a negative number when a < b, 0 when a == b and a positive number when a > b.
+func CompareSortKeys(a []string, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// find returns the index of sortKey within items, or the index at which it should be inserted.
+func find(items []localItem, sortKey []string) (int, bool) {
+	idx := sort.Search(len(items), func(i int) bool {
+		return CompareSortKeys(items[i].sortKey, sortKey) >= 0
+	})
+	return idx, idx < len(items) && CompareSortKeys(items[idx].sortKey, sortKey) == 0
+}
+
+func partitionName(specifiers []string, partitionKey []string) string {
+	data, _ := json.Marshal([][]string{specifiers, partitionKey})
+	return string(data)
+}
+
+func encodeToken(sortKey []string) (string, error) {
+	data, err := json.Marshal(sortKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeToken(token string, sortKey *[]string) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, sortKey)
+}
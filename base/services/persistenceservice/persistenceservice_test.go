@@ -0,0 +1,98 @@
+package persistenceservice
+
+import (
+	"testing"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/base/portal"
+	"github.com/darlean-io/darlean.go/utils/binary"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/jsonvariant"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+// wireInvoker passes requests to a local service after serializing them the way they
+// would travel over the wire.
+type wireInvoker struct {
+	service *Local
+	actors  []string
+}
+
+func roundtrip(source any, target any) {
+	data, err := jsonbinary.Serialize(source, nil)
+	if err != nil {
+		panic(err)
+	}
+	err = jsonbinary.Deserialize(data, target)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func toVariant(value any) variant.Assignable {
+	data, err := jsonbinary.Serialize(value, nil)
+	if err != nil {
+		panic(err)
+	}
+	return jsonvariant.FromJson(data)
+}
+
+func (inv *wireInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.actors = append(inv.actors, request.ActorType)
+	switch request.ActionName {
+	case ACTION_STORE:
+		var options StoreOptions
+		roundtrip(request.Parameters[0], &options)
+		return nil, actionerror.FromError(inv.service.Store(options))
+	case ACTION_LOAD:
+		var options LoadOptions
+		roundtrip(request.Parameters[0], &options)
+		result, _ := inv.service.Load(options)
+		return toVariant(result), nil
+	case ACTION_QUERY:
+		var options QueryOptions
+		roundtrip(request.Parameters[0], &options)
+		result, _ := inv.service.Query(options)
+		return toVariant(result), nil
+	}
+	return nil, actionerror.New(actionerror.Options{Code: "UNKNOWN_ACTION"})
+}
+
+func TestClient(t *testing.T) {
+	inv := wireInvoker{service: NewLocal()}
+	client := NewClient(portal.New(&inv))
+
+	for _, key := range []string{"c", "a", "d", "b"} {
+		value := binary.FromBytes([]byte("value-" + key))
+		err := client.Store(StoreOptions{PartitionKey: []string{"p"}, SortKey: []string{key}, Value: &value, Version: "1"})
+		checks.Equal(t, nil, err, "Store should succeed")
+	}
+	checks.Equal(t, SERVICE, inv.actors[0], "Actor type of the service")
+
+	loaded, err := client.Load(LoadOptions{PartitionKey: []string{"p"}, SortKey: []string{"b"}})
+	checks.Equal(t, nil, err, "Load should succeed")
+	checks.Equal(t, "value-b", string(loaded.Value.Bytes()), "Loaded value")
+	checks.Equal(t, "1", loaded.Version, "Loaded version")
+
+	err = Delete(client, DeleteOptions{PartitionKey: []string{"p"}, SortKey: []string{"b"}})
+	checks.Equal(t, nil, err, "Delete should succeed")
+	loaded, _ = client.Load(LoadOptions{PartitionKey: []string{"p"}, SortKey: []string{"b"}})
+	checks.Equal(t, true, loaded.Value == nil, "Deleted value should be nil")
+
+	var keys []string
+	err = QueryAll(client, QueryOptions{PartitionKey: []string{"p"}, SortKeyTo: []string{"c"}, MaxItems: 1}, func(item QueryItem) bool {
+		keys = append(keys, item.SortKey[0]+"="+string(item.Value.Bytes()))
+		return true
+	})
+	checks.Equal(t, nil, err, "Query should succeed")
+	checks.Equal(t, []string{"a=value-a", "c=value-c"}, keys, "Paged query results")
+
+	keys = nil
+	QueryAll(client, QueryOptions{PartitionKey: []string{"p"}, SortKeyFrom: []string{"b"}, SortKeyOrder: SORT_KEY_ORDER_DESCENDING, MaxItems: 2}, func(item QueryItem) bool {
+		keys = append(keys, item.SortKey[0])
+		return true
+	})
+	checks.Equal(t, []string{"d", "c"}, keys, "Descending query results")
+}
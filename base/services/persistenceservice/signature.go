@@ -0,0 +1,26 @@
+package persistenceservice
+
+// Signature of the persistence service for use with [typedportal.ForSignature].
+type PersistenceService struct {
+	Store PersistenceService_Store
+	Load  PersistenceService_Load
+	Query PersistenceService_Query
+}
+
+func (PersistenceService) ActorTypeName() string {
+	return SERVICE
+}
+
+type PersistenceService_Store struct {
+	A0_Options StoreOptions
+}
+
+type PersistenceService_Load struct {
+	A0_Options LoadOptions
+	Result     LoadResult
+}
+
+type PersistenceService_Query struct {
+	A0_Options QueryOptions
+	Result     QueryResult
+}
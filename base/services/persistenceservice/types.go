@@ -0,0 +1,68 @@
+package persistenceservice
+
+import "github.com/darlean-io/darlean.go/utils/binary"
+
+const SERVICE = "io.darlean.persistenceservice"
+
+const ACTION_STORE = "store"
+const ACTION_LOAD = "load"
+const ACTION_QUERY = "query"
+
+const SORT_KEY_ORDER_ASCENDING = "ascending"
+const SORT_KEY_ORDER_DESCENDING = "descending"
+
+type StoreOptions struct {
+	Specifiers   []string `json:"specifiers,omitempty"`
+	PartitionKey []string `json:"partitionKey"`
+	SortKey      []string `json:"sortKey"`
+	// Value to be stored. A nil value deletes the item.
+	Value   *binary.Binary `json:"value,omitempty"`
+	Version string         `json:"version"`
+}
+
+type LoadOptions struct {
+	Specifiers   []string `json:"specifiers,omitempty"`
+	PartitionKey []string `json:"partitionKey"`
+	SortKey      []string `json:"sortKey"`
+}
+
+type LoadResult struct {
+	// Value of the item, or nil when the item does not exist.
+	Value   *binary.Binary `json:"value"`
+	Version string         `json:"version"`
+}
+
+type QueryOptions struct {
+	Specifiers   []string `json:"specifiers,omitempty"`
+	PartitionKey []string `json:"partitionKey"`
+	// Inclusive lower bound of the sort key. Nil means no lower bound.
+	SortKeyFrom []string `json:"sortKeyFrom,omitempty"`
+	// Inclusive upper bound of the sort key. Nil means no upper bound.
+	SortKeyTo []string `json:"sortKeyTo,omitempty"`
+	// One of [SORT_KEY_ORDER_ASCENDING] (default) or [SORT_KEY_ORDER_DESCENDING].
+	SortKeyOrder string `json:"sortKeyOrder,omitempty"`
+	// Maximum number of items per page. 0 means no limit.
+	MaxItems int `json:"maxItems,omitempty"`
+	// Token from a previous [QueryResult] to obtain the next page.
+	ContinuationToken string `json:"continuationToken,omitempty"`
+}
+
+type QueryItem struct {
+	SortKey []string       `json:"sortKey"`
+	Value   *binary.Binary `json:"value"`
+	Version string         `json:"version"`
+}
+
+type QueryResult struct {
+	Items []QueryItem `json:"items"`
+	// Token to obtain the next page, or empty when there are no more items.
+	ContinuationToken string `json:"continuationToken"`
+}
+
+// Service is the interface to the persistence service. It is implemented by [Client] (that invokes
+// the actual persistence service) and by [Local] (an in-memory stand-in).
+type Service interface {
+	Store(options StoreOptions) error
+	Load(options LoadOptions) (*LoadResult, error)
+	Query(options QueryOptions) (*QueryResult, error)
+}
@@ -0,0 +1,76 @@
+/*
+Package tablesservice contains the data types for and a client to the Darlean tables service, which stores
+records that can be searched by means of secondary indexes.
+
+Use [NewClient] to obtain a client that invokes the tables service via a portal, or [NewLocal] to obtain
+an in-memory stand-in for tests and local development. Both satisfy [Service]. [Table] provides a typed
+API on top of a [Service].
+*/
+package tablesservice
+
+import (
+	"github.com/darlean-io/darlean.go/base/portal"
+	"github.com/darlean-io/darlean.go/base/typedportal"
+)
+
+// Client invokes the tables service via a portal. Satisfies [Service].
+type Client struct {
+	actor *portal.ActorProxy[TablesService]
+}
+
+// NewClient returns a client that uses p to invoke the tables service.
+func NewClient(p portal.Portal) *Client {
+	return &Client{
+		actor: typedportal.ForSignature[TablesService](p).Obtain([]string{}),
+	}
+}
+
+func (client *Client) Put(request PutRequest) (*PutResponse, error) {
+	call := client.actor.NewCall().Put
+	call.A0_Request = request
+	err := client.actor.Invoke(&call)
+	if err != nil {
+		return nil, err
+	}
+	return &call.Result, nil
+}
+
+func (client *Client) Get(request GetRequest) (*GetResponse, error) {
+	call := client.actor.NewCall().Get
+	call.A0_Request = request
+	err := client.actor.Invoke(&call)
+	if err != nil {
+		return nil, err
+	}
+	return &call.Result, nil
+}
+
+func (client *Client) Search(request SearchRequest) (*SearchResponse, error) {
+	call := client.actor.NewCall().Search
+	call.A0_Request = request
+	err := client.actor.Invoke(&call)
+	if err != nil {
+		return nil, err
+	}
+	return &call.Result, nil
+}
+
+// SearchAll performs the search and invokes handler for every item on every page. It stops
+// when handler returns false or when there are no more items.
+func SearchAll(service Service, request SearchRequest, handler func(item SearchItem) bool) error {
+	for {
+		response, err := service.Search(request)
+		if err != nil {
+			return err
+		}
+		for _, item := range response.Items {
+			if !handler(item) {
+				return nil
+			}
+		}
+		if response.ContinuationToken == "" {
+			return nil
+		}
+		request.ContinuationToken = response.ContinuationToken
+	}
+}
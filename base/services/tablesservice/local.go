@@ -0,0 +1,256 @@
+package tablesservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type localRecord struct {
+	id       []string
+	data     map[string]any
+	version  string
+	baseline int64
+	indexes  []IndexItem
+}
+
+// localEntry is a search candidate: a record together with the index entry (if any) it was found by.
+type localEntry struct {
+	keys   []string
+	record *localRecord
+	index  *IndexItem
+}
+
+// Local is an in-memory stand-in for the tables service, intended for tests and local development.
+// Satisfies [Service].
+type Local struct {
+	// Map from table name + specifier to the records of the table, by encoded id.
+	tables   map[string]map[string]*localRecord
+	baseline int64
+	mutex    sync.RWMutex
+}
+
+func NewLocal() *Local {
+	return &Local{
+		tables: make(map[string]map[string]*localRecord),
+	}
+}
+
+func (local *Local) Put(request PutRequest) (*PutResponse, error) {
+	name := encode(request.Table, request.Specifier)
+	id := encode(request.Id...)
+	local.mutex.Lock()
+	defer local.mutex.Unlock()
+
+	records := local.tables[name]
+	if records == nil {
+		records = make(map[string]*localRecord)
+		local.tables[name] = records
+	}
+	current := records[id]
+	if request.Baseline != "" && (current == nil || formatBaseline(current.baseline) != request.Baseline) {
+		return nil, ErrBaselineMismatch
+	}
+
+	if request.Data == nil {
+		delete(records, id)
+		return &PutResponse{}, nil
+	}
+
+	local.baseline++
+	records[id] = &localRecord{
+		id:       append([]string(nil), request.Id...),
+		data:     project(request.Data, nil),
+		version:  request.Version,
+		baseline: local.baseline,
+		indexes:  request.Indexes,
+	}
+	return &PutResponse{Baseline: formatBaseline(local.baseline)}, nil
+}
+
+func (local *Local) Get(request GetRequest) (*GetResponse, error) {
+	local.mutex.RLock()
+	defer local.mutex.RUnlock()
+
+	record := local.tables[encode(request.Table, request.Specifier)][encode(request.Id...)]
+	if record == nil {
+		return &GetResponse{}, nil
+	}
+	return &GetResponse{
+		Data:     project(record.data, request.Projection),
+		Version:  record.version,
+		Baseline: formatBaseline(record.baseline),
+	}, nil
+}
+
+func (local *Local) Search(request SearchRequest) (*SearchResponse, error) {
+	descending := request.KeysOrder == KEYS_ORDER_DESCENDING
+
+	var after *localEntryToken
+	if request.ContinuationToken != "" {
+		after = &localEntryToken{}
+		err := decodeToken(request.ContinuationToken, after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	local.mutex.RLock()
+	defer local.mutex.RUnlock()
+
+	var entries []localEntry
+	for _, record := range local.tables[encode(request.Table, request.Specifier)] {
+		if request.Index == "" {
+			if matches(record.id, request.Keys) {
+				entries = append(entries, localEntry{keys: record.id, record: record})
+			}
+			continue
+		}
+		for i := range record.indexes {
+			index := &record.indexes[i]
+			if index.Name == request.Index && matches(index.Keys, request.Keys) {
+				entries = append(entries, localEntry{keys: index.Keys, record: record, index: index})
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		c := compareEntries(entries[i].keys, entries[i].record.id, entries[j].keys, entries[j].record.id)
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	response := SearchResponse{Items: []SearchItem{}}
+	for _, entry := range entries {
+		if after != nil {
+			c := compareEntries(entry.keys, entry.record.id, after.Keys, after.Id)
+			if (!descending && c <= 0) || (descending && c >= 0) {
+				continue
+			}
+		}
+		if request.MaxItems > 0 && len(response.Items) >= request.MaxItems {
+			last := response.Items[len(response.Items)-1]
+			token, err := encodeToken(localEntryToken{Keys: last.Keys, Id: last.Id})
+			if err != nil {
+				return nil, err
+			}
+			response.ContinuationToken = token
+			break
+		}
+		item := SearchItem{
+			Keys:        entry.keys,
+			Id:          entry.record.id,
+			TableFields: project(entry.record.data, request.TableProjection),
+		}
+		if entry.index != nil {
+			item.IndexFields = project(entry.index.Data, request.IndexProjection)
+		}
+		response.Items = append(response.Items, item)
+	}
+	return &response, nil
+}
+
+type localEntryToken struct {
+	Keys []string `json:"keys"`
+	Id   []string `json:"id"`
+}
+
+// matches returns whether every constraint holds for the key part at the same position.
+func matches(keys []string, constraints []KeyConstraint) bool {
+	for i, constraint := range constraints {
+		if i >= len(keys) {
+			return false
+		}
+		key := keys[i]
+		var ok bool
+		switch constraint.Operator {
+		case OPERATOR_EQ:
+			ok = key == constraint.Value
+		case OPERATOR_LTE:
+			ok = key <= constraint.Value
+		case OPERATOR_GTE:
+			ok = key >= constraint.Value
+		case OPERATOR_PREFIX:
+			ok = strings.HasPrefix(key, constraint.Value)
+		case OPERATOR_BETWEEN:
+			ok = key >= constraint.Value && key <= constraint.Value2
+		case OPERATOR_CONTAINS:
+			ok = strings.Contains(key, constraint.Value)
+		case OPERATOR_CONTAINS_NI:
+			ok = strings.Contains(strings.ToLower(key), strings.ToLower(constraint.Value))
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// project returns a copy of the fields of data that are listed in projection. Returns all fields for a nil projection.
+func project(data map[string]any, projection []string) map[string]any {
+	if data == nil {
+		return nil
+	}
+	if projection == nil {
+		result := make(map[string]any, len(data))
+		for field, value := range data {
+			result[field] = value
+		}
+		return result
+	}
+	result := make(map[string]any, len(projection))
+	for _, field := range projection {
+		if value, has := data[field]; has {
+			result[field] = value
+		}
+	}
+	return result
+}
+
+func compareKeys(a []string, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareEntries orders by keys and then by id, so that the order is stable when multiple records
+// have index entries with the same keys.
+func compareEntries(aKeys []string, aId []string, bKeys []string, bId []string) int {
+	if c := compareKeys(aKeys, bKeys); c != 0 {
+		return c
+	}
+	return compareKeys(aId, bId)
+}
+
+func formatBaseline(baseline int64) string {
+	return strconv.FormatInt(baseline, 10)
+}
+
+func encode(parts ...string) string {
+	data, _ := json.Marshal(parts)
+	return string(data)
+}
+
+func encodeToken(token localEntryToken) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeToken(token string, target *localEntryToken) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
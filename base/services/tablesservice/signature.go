@@ -0,0 +1,27 @@
+package tablesservice
+
+// Signature of the tables service for use with [typedportal.ForSignature].
+type TablesService struct {
+	Put    TablesService_Put
+	Get    TablesService_Get
+	Search TablesService_Search
+}
+
+func (TablesService) ActorTypeName() string {
+	return SERVICE
+}
+
+type TablesService_Put struct {
+	A0_Request PutRequest
+	Result     PutResponse
+}
+
+type TablesService_Get struct {
+	A0_Request GetRequest
+	Result     GetResponse
+}
+
+type TablesService_Search struct {
+	A0_Request SearchRequest
+	Result     SearchResponse
+}
@@ -0,0 +1,229 @@
+package tablesservice
+
+import "encoding/json"
+
+// Index defines a secondary index for records of type T.
+type Index[T any] struct {
+	Name string
+	// Keys returns the keys of the index entry for value, or nil when value must not be in the index.
+	Keys func(value T) []string
+	// Names of the record fields that are also stored in the index entry.
+	Fields []string
+}
+
+// Table provides a typed API for the records of one table. Records are converted to and from fields by
+// means of their JSON representation.
+type Table[T any] struct {
+	service   Service
+	name      string
+	specifier string
+	indexes   []Index[T]
+}
+
+// NewTable returns a table with the provided name that uses service to store its records of type T,
+// and that maintains the provided indexes.
+func NewTable[T any](service Service, name string, indexes ...Index[T]) *Table[T] {
+	return &Table[T]{
+		service: service,
+		name:    name,
+		indexes: indexes,
+	}
+}
+
+// WithSpecifier returns a copy of the table that uses specifier to select the storage of the table.
+func (table *Table[T]) WithSpecifier(specifier string) *Table[T] {
+	result := *table
+	result.specifier = specifier
+	return &result
+}
+
+// PutOptions contains the optional parameters of [Table.Put].
+type PutOptions struct {
+	// Version of the record that is stored together with the record.
+	Version string
+	// When not empty, the put only succeeds when the baseline equals the baseline that was returned by
+	// the most recent get or put of the record (optimistic concurrency). Otherwise, [ErrBaselineMismatch]
+	// is returned.
+	Baseline string
+}
+
+type PutResult struct {
+	// Version with which the record was stored.
+	Version string
+	// Baseline of the stored record, to be used for the next put.
+	Baseline string
+}
+
+// Record is a record as returned by [Table.Get].
+type Record[T any] struct {
+	Value    T
+	Version  string
+	Baseline string
+}
+
+// Put stores value under id and updates the index entries of the record.
+func (table *Table[T]) Put(id []string, value T, options PutOptions) (*PutResult, error) {
+	data, err := toFields(value)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []IndexItem
+	for _, index := range table.indexes {
+		keys := index.Keys(value)
+		if keys == nil {
+			continue
+		}
+		item := IndexItem{Name: index.Name, Keys: keys}
+		if len(index.Fields) > 0 {
+			item.Data = project(data, index.Fields)
+		}
+		indexes = append(indexes, item)
+	}
+	response, err := table.service.Put(PutRequest{
+		Table:     table.name,
+		Specifier: table.specifier,
+		Id:        id,
+		Data:      data,
+		Indexes:   indexes,
+		Version:   options.Version,
+		Baseline:  options.Baseline,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PutResult{Version: options.Version, Baseline: response.Baseline}, nil
+}
+
+// Get returns the record for id, or nil when it does not exist.
+func (table *Table[T]) Get(id []string) (*Record[T], error) {
+	response, err := table.service.Get(GetRequest{
+		Table:     table.name,
+		Specifier: table.specifier,
+		Id:        id,
+	})
+	if err != nil || response.Data == nil {
+		return nil, err
+	}
+	record := Record[T]{
+		Version:  response.Version,
+		Baseline: response.Baseline,
+	}
+	err = fromFields(response.Data, &record.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Delete deletes the record for id together with its index entries. When baseline is not empty,
+// the record is only deleted when its baseline matches (see [PutOptions]).
+func (table *Table[T]) Delete(id []string, baseline string) error {
+	_, err := table.service.Put(PutRequest{
+		Table:     table.name,
+		Specifier: table.specifier,
+		Id:        id,
+		Baseline:  baseline,
+	})
+	return err
+}
+
+type SearchOptions struct {
+	// Name of the index to search. An empty name searches the table itself by id.
+	Index     string
+	Keys      []KeyConstraint
+	KeysOrder string
+	// Names of the record fields to fill in. Nil fills in all fields.
+	Projection []string
+	// Names of the index entry fields to return in [SearchResult.IndexFields]. Nil returns all fields.
+	IndexProjection []string
+	MaxItems        int
+	// Token from a previous search to obtain the next page.
+	ContinuationToken string
+}
+
+type SearchResult[T any] struct {
+	Keys  []string
+	Id    []string
+	Value T
+	// Fields that are stored in the index entry.
+	IndexFields map[string]any
+}
+
+// Search returns one page of records that match options, and a continuation token that is empty
+// when there are no more records.
+func (table *Table[T]) Search(options SearchOptions) ([]SearchResult[T], string, error) {
+	response, err := table.service.Search(table.searchRequest(options))
+	if err != nil {
+		return nil, "", err
+	}
+	results := make([]SearchResult[T], len(response.Items))
+	for i, item := range response.Items {
+		results[i], err = toResult[T](item)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return results, response.ContinuationToken, nil
+}
+
+// SearchAll invokes handler for every record that matches options, across all pages. It stops
+// when handler returns false or when there are no more records.
+func (table *Table[T]) SearchAll(options SearchOptions, handler func(result SearchResult[T]) bool) error {
+	var convErr error
+	err := SearchAll(table.service, table.searchRequest(options), func(item SearchItem) bool {
+		var result SearchResult[T]
+		result, convErr = toResult[T](item)
+		return convErr == nil && handler(result)
+	})
+	if err != nil {
+		return err
+	}
+	return convErr
+}
+
+func (table *Table[T]) searchRequest(options SearchOptions) SearchRequest {
+	return SearchRequest{
+		Table:             table.name,
+		Specifier:         table.specifier,
+		Index:             options.Index,
+		Keys:              options.Keys,
+		KeysOrder:         options.KeysOrder,
+		TableProjection:   options.Projection,
+		IndexProjection:   options.IndexProjection,
+		MaxItems:          options.MaxItems,
+		ContinuationToken: options.ContinuationToken,
+	}
+}
+
+func toResult[T any](item SearchItem) (SearchResult[T], error) {
+	result := SearchResult[T]{
+		Keys:        item.Keys,
+		Id:          item.Id,
+		IndexFields: item.IndexFields,
+	}
+	if item.TableFields != nil {
+		err := fromFields(item.TableFields, &result.Value)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func toFields(value any) (map[string]any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+func fromFields(fields map[string]any, value any) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
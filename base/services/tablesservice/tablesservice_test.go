@@ -0,0 +1,155 @@
+package tablesservice
+
+import (
+	"testing"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/base/portal"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/jsonvariant"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+// wireInvoker passes requests to a local service after serializing them the way they
+// would travel over the wire.
+type wireInvoker struct {
+	service *Local
+	actors  []string
+}
+
+func roundtrip(source any, target any) {
+	data, err := jsonbinary.Serialize(source, nil)
+	if err != nil {
+		panic(err)
+	}
+	err = jsonbinary.Deserialize(data, target)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func toVariant(value any) variant.Assignable {
+	data, err := jsonbinary.Serialize(value, nil)
+	if err != nil {
+		panic(err)
+	}
+	return jsonvariant.FromJson(data)
+}
+
+func (inv *wireInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.actors = append(inv.actors, request.ActorType)
+	var result any
+	var err error
+	switch request.ActionName {
+	case ACTION_PUT:
+		var req PutRequest
+		roundtrip(request.Parameters[0], &req)
+		result, err = inv.service.Put(req)
+	case ACTION_GET:
+		var req GetRequest
+		roundtrip(request.Parameters[0], &req)
+		result, err = inv.service.Get(req)
+	case ACTION_SEARCH:
+		var req SearchRequest
+		roundtrip(request.Parameters[0], &req)
+		result, err = inv.service.Search(req)
+	default:
+		return nil, actionerror.New(actionerror.Options{Code: "UNKNOWN_ACTION"})
+	}
+	if err != nil {
+		return nil, actionerror.FromError(err)
+	}
+	return toVariant(result), nil
+}
+
+type person struct {
+	Name string `json:"name"`
+	City string `json:"city"`
+	Age  int    `json:"age"`
+}
+
+func TestTable(t *testing.T) {
+	inv := wireInvoker{service: NewLocal()}
+	table := NewTable[person](NewClient(portal.New(&inv)), "persons", Index[person]{
+		Name:   "byCity",
+		Keys:   func(p person) []string { return []string{p.City, p.Name} },
+		Fields: []string{"age"},
+	})
+
+	for _, p := range []person{{"Alice", "Amsterdam", 30}, {"Bob", "Berlin", 40}, {"Carol", "Amsterdam", 50}, {"Dave", "Boston", 60}} {
+		_, err := table.Put([]string{p.Name}, p, PutOptions{})
+		checks.Equal(t, nil, err, "Put should succeed")
+	}
+	checks.Equal(t, SERVICE, inv.actors[0], "Actor type of the service")
+
+	bob, err := table.Get([]string{"Bob"})
+	checks.Equal(t, nil, err, "Get should succeed")
+	checks.Equal(t, person{"Bob", "Berlin", 40}, bob.Value, "Loaded record")
+
+	put, err := table.Put([]string{"Bob"}, person{"Bob", "Berlin", 41}, PutOptions{Version: "2", Baseline: bob.Baseline})
+	checks.Equal(t, nil, err, "Put with current baseline should succeed")
+	checks.Equal(t, "2", put.Version, "Version of the put")
+	_, err = table.Put([]string{"Bob"}, person{"Bob", "Berlin", 42}, PutOptions{Baseline: bob.Baseline})
+	checks.IsNotNil(t, err, "Put with outdated baseline should fail")
+	checks.IsNotNil(t, table.Delete([]string{"Bob"}, bob.Baseline), "Delete with outdated baseline should fail")
+	bob, _ = table.Get([]string{"Bob"})
+	checks.Equal(t, 41, bob.Value.Age, "Record of the successful put")
+	checks.Equal(t, "2", bob.Version, "Version of the record")
+	checks.Equal(t, put.Baseline, bob.Baseline, "Baseline of the record")
+
+	checks.Equal(t, nil, table.Delete([]string{"Bob"}, bob.Baseline), "Delete should succeed")
+	bob, _ = table.Get([]string{"Bob"})
+	checks.Equal(t, true, bob == nil, "Deleted record should be nil")
+
+	var names []string
+	err = table.SearchAll(SearchOptions{Index: "byCity", Keys: []KeyConstraint{{Operator: OPERATOR_PREFIX, Value: "B"}}}, func(r SearchResult[person]) bool {
+		names = append(names, r.Value.Name)
+		return true
+	})
+	checks.Equal(t, nil, err, "Search should succeed")
+	checks.Equal(t, []string{"Dave"}, names, "Index entries of deleted records should be removed")
+
+	results, token, err := table.Search(SearchOptions{
+		Index:           "byCity",
+		Keys:            []KeyConstraint{{Operator: OPERATOR_EQ, Value: "Amsterdam"}},
+		KeysOrder:       KEYS_ORDER_DESCENDING,
+		Projection:      []string{"name"},
+		IndexProjection: []string{},
+		MaxItems:        1,
+	})
+	checks.Equal(t, nil, err, "Search should succeed")
+	checks.Equal(t, 1, len(results), "Page size")
+	checks.Equal(t, person{Name: "Carol"}, results[0].Value, "Projected record")
+	checks.Equal(t, 0, len(results[0].IndexFields), "Projected index fields")
+
+	results, token, _ = table.Search(SearchOptions{
+		Index:             "byCity",
+		Keys:              []KeyConstraint{{Operator: OPERATOR_EQ, Value: "Amsterdam"}},
+		KeysOrder:         KEYS_ORDER_DESCENDING,
+		MaxItems:          1,
+		ContinuationToken: token,
+	})
+	checks.Equal(t, "Alice", results[0].Value.Name, "Second page")
+	checks.Equal(t, 30.0, results[0].IndexFields["age"], "Index fields")
+	checks.Equal(t, "", token, "No more pages")
+
+	names = nil
+	table.SearchAll(SearchOptions{Keys: []KeyConstraint{{Operator: OPERATOR_BETWEEN, Value: "B", Value2: "D"}}}, func(r SearchResult[person]) bool {
+		names = append(names, r.Id[0])
+		return true
+	})
+	checks.Equal(t, []string{"Carol"}, names, "Search on the table itself by id")
+}
+
+func TestLocal_Baseline(t *testing.T) {
+	local := NewLocal()
+	response, _ := local.Put(PutRequest{Table: "t", Id: []string{"a"}, Data: map[string]any{"x": 1}})
+	_, err := local.Put(PutRequest{Table: "t", Id: []string{"a"}, Data: map[string]any{"x": 2}, Baseline: "other"})
+	checks.Equal(t, ErrBaselineMismatch, err, "Put with wrong baseline should fail")
+	_, err = local.Put(PutRequest{Table: "t", Id: []string{"a"}, Data: map[string]any{"x": 3}, Baseline: response.Baseline})
+	checks.Equal(t, nil, err, "Put with current baseline should succeed")
+	got, _ := local.Get(GetRequest{Table: "t", Id: []string{"a"}})
+	checks.Equal(t, 3, got.Data["x"], "Stored value")
+}
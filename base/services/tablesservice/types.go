@@ -0,0 +1,116 @@
+package tablesservice
+
+import "errors"
+
+const SERVICE = "io.darlean.tablesservice"
+
+const ACTION_PUT = "put"
+const ACTION_GET = "get"
+const ACTION_SEARCH = "search"
+
+const KEYS_ORDER_ASCENDING = "ascending"
+const KEYS_ORDER_DESCENDING = "descending"
+
+const OPERATOR_EQ = "eq"
+const OPERATOR_LTE = "lte"
+const OPERATOR_GTE = "gte"
+const OPERATOR_PREFIX = "prefix"
+const OPERATOR_BETWEEN = "between"
+const OPERATOR_CONTAINS = "contains"
+
+// Case-insensitive variant of [OPERATOR_CONTAINS].
+const OPERATOR_CONTAINS_NI = "containsni"
+
+// ErrBaselineMismatch is returned when a put provides a baseline that does not match the current baseline of the record.
+var ErrBaselineMismatch = errors.New("tablesservice: baseline mismatch")
+
+// IndexItem is one entry of a record in a secondary index.
+type IndexItem struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+	// Fields that are stored in the index entry itself, so that searches can return them without
+	// consulting the table.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+type PutRequest struct {
+	Table     string   `json:"table"`
+	Specifier string   `json:"specifier,omitempty"`
+	Id        []string `json:"id"`
+	// Fields of the record. A nil value deletes the record.
+	Data map[string]any `json:"data,omitempty"`
+	// Index entries of the record. Replace the previous index entries of the record.
+	Indexes []IndexItem `json:"indexes"`
+	Version string      `json:"version"`
+	// When not empty, the put only succeeds when the baseline equals the baseline that was returned by
+	// the most recent get or put of the record (optimistic concurrency).
+	Baseline string `json:"baseline,omitempty"`
+}
+
+type PutResponse struct {
+	Baseline string `json:"baseline"`
+}
+
+type GetRequest struct {
+	Table     string   `json:"table"`
+	Specifier string   `json:"specifier,omitempty"`
+	Id        []string `json:"id"`
+	// Names of the fields to return. Nil returns all fields.
+	Projection []string `json:"projection,omitempty"`
+}
+
+type GetResponse struct {
+	// Fields of the record, or nil when the record does not exist.
+	Data     map[string]any `json:"data"`
+	Version  string         `json:"version"`
+	Baseline string         `json:"baseline"`
+}
+
+// KeyConstraint filters the key part at the same position as the constraint within [SearchRequest.Keys].
+type KeyConstraint struct {
+	// One of the OPERATOR_ constants.
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	// Upper bound for [OPERATOR_BETWEEN].
+	Value2 string `json:"value2,omitempty"`
+}
+
+type SearchRequest struct {
+	Table     string `json:"table"`
+	Specifier string `json:"specifier,omitempty"`
+	// Name of the index to search. An empty name searches the table itself by id.
+	Index string          `json:"index,omitempty"`
+	Keys  []KeyConstraint `json:"keys,omitempty"`
+	// One of [KEYS_ORDER_ASCENDING] (default) or [KEYS_ORDER_DESCENDING].
+	KeysOrder string `json:"keysOrder,omitempty"`
+	// Names of the record fields to return. Nil returns all fields; an empty slice returns no fields.
+	TableProjection []string `json:"tableProjection"`
+	// Names of the index entry fields to return. Nil returns all fields; an empty slice returns no fields.
+	IndexProjection []string `json:"indexProjection"`
+	// Maximum number of items per page. 0 means no limit.
+	MaxItems int `json:"maxItems,omitempty"`
+	// Token from a previous [SearchResponse] to obtain the next page.
+	ContinuationToken string `json:"continuationToken,omitempty"`
+}
+
+type SearchItem struct {
+	// Keys of the index entry (or the id when searching the table itself).
+	Keys        []string       `json:"keys"`
+	Id          []string       `json:"id"`
+	TableFields map[string]any `json:"tableFields,omitempty"`
+	IndexFields map[string]any `json:"indexFields,omitempty"`
+}
+
+type SearchResponse struct {
+	Items []SearchItem `json:"items"`
+	// Token to obtain the next page, or empty when there are no more items.
+	ContinuationToken string `json:"continuationToken"`
+}
+
+// Service is the interface to the tables service. It is implemented by [Client] (that invokes
+// the actual tables service) and by [Local] (an in-memory stand-in).
+type Service interface {
+	Put(request PutRequest) (*PutResponse, error)
+	Get(request GetRequest) (*GetResponse, error)
+	Search(request SearchRequest) (*SearchResponse, error)
+}
@@ -51,3 +51,17 @@ Therefore, action signatures must obey the following rules:
 * When the action is not a void, the struct should define a field called `Result` field of the proper type.
 */
 type Action any
+
+/*
+NamedActor can be implemented by an actor signature to explicitly provide the actor type. This is
+necessary for actor types that cannot be expressed as a Go struct name, like `io.darlean.persistenceservice`.
+
+Example:
+
+	func (PersistenceService) ActorTypeName() string {
+		return "io.darlean.persistenceservice"
+	}
+*/
+type NamedActor interface {
+	ActorTypeName() string
+}
@@ -0,0 +1,362 @@
+/*
+Package gateway exposes actors as HTTP endpoints, so that they can be invoked by clients that are not
+part of the Darlean cluster.
+
+Actions are invoked by means of `POST /actors/{type}/{id...}/{action}`, where the actor id can consist of
+zero or more (url-encoded) path segments. The body of the request provides the parameters of the action:
+  - `application/json` (or no content type): a JSON array contains the parameters; any other JSON value
+    is passed as the only parameter. An empty body means no parameters.
+  - `application/octet-stream`: the body is passed as the only parameter as a [binary.Binary].
+  - `multipart/form-data`: every part is one parameter, in order. Parts with a JSON content type are parsed
+    as JSON; other parts are passed as [binary.Binary].
+
+The result of the action is returned as JSON, or as raw bytes when the request accepts `application/octet-stream`
+(the action must then return a [binary.Binary]). Errors are returned as a JSON [actionerror.Error] with an
+HTTP status that depends on the kind and code of the error.
+
+Only actor types that are allowed can be invoked (see [Gateway.SetAllowedActorTypes]). By default, all actor
+types except the internal Darlean services are allowed.
+*/
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/utils/binary"
+)
+
+const PATH_PREFIX = "/actors/"
+
+// Maximum size of a request body. Can be changed per gateway via [Gateway.SetMaxBodySize].
+const MAX_BODY_SIZE = 10 * 1024 * 1024
+
+const ERROR_INVALID_PATH = "GATEWAY_INVALID_PATH"
+const ERROR_INVALID_BODY = "GATEWAY_INVALID_BODY"
+const ERROR_METHOD_NOT_ALLOWED = "GATEWAY_METHOD_NOT_ALLOWED"
+const ERROR_INVALID_RESULT = "GATEWAY_INVALID_RESULT"
+const ERROR_ACTOR_TYPE_NOT_ALLOWED = "GATEWAY_ACTOR_TYPE_NOT_ALLOWED"
+
+// Code of the error with which the invoker reports that a call failed after retrying. The causes are nested.
+const ERROR_INVOKE_ERROR = "INVOKE_ERROR"
+
+// Actor types that start with this prefix are internal services that can not be invoked unless they are
+// explicitly allowed. Compared against the normalized actor type, so that "io.darlean.streams" is covered as well.
+const INTERNAL_ACTOR_TYPE_PREFIX = "iodarlean"
+
+const CONTENT_TYPE_JSON = "application/json"
+const CONTENT_TYPE_OCTET_STREAM = "application/octet-stream"
+
+// HTTP status for application errors.
+const STATUS_APPLICATION_ERROR = http.StatusUnprocessableEntity
+
+// HTTP status for framework errors with a code that has no explicit mapping.
+const STATUS_FRAMEWORK_ERROR = http.StatusInternalServerError
+
+// Gateway is an [http.Handler] that invokes actors via an invoker (typically an invoke.DynamicInvoker).
+type Gateway struct {
+	invoker     invoker.Invoker
+	statuses    map[string]int
+	maxBodySize int64
+	allowed     map[normalized.ActorType]bool
+}
+
+func New(invoker invoker.Invoker) *Gateway {
+	return &Gateway{
+		invoker: invoker,
+		statuses: map[string]int{
+			ERROR_INVALID_PATH:           http.StatusNotFound,
+			ERROR_INVALID_BODY:           http.StatusBadRequest,
+			ERROR_METHOD_NOT_ALLOWED:     http.StatusMethodNotAllowed,
+			ERROR_INVALID_RESULT:         http.StatusNotAcceptable,
+			ERROR_ACTOR_TYPE_NOT_ALLOWED: http.StatusForbidden,
+			"UNKNOWN_ACTION":             http.StatusNotFound,
+			"NO_ACTOR_TYPE":              http.StatusNotFound,
+			"UNKNOWN_STREAM":             http.StatusNotFound,
+			"INVALID_SEQUENCE":           http.StatusBadRequest,
+			"INVALID_ARGUMENTS":          http.StatusBadRequest,
+			"ACTOR_TYPE_NOT_REGISTERED":  http.StatusServiceUnavailable,
+			"NO_RECEIVERS_AVAILABLE":     http.StatusServiceUnavailable,
+			"NOT_READY":                  http.StatusServiceUnavailable,
+			"DRAINING":                   http.StatusServiceUnavailable,
+			"TOO_BUSY":                   http.StatusServiceUnavailable,
+			"DEADLOCK":                   http.StatusConflict,
+			"INVOKE_ERROR":               http.StatusBadGateway,
+		},
+		maxBodySize: MAX_BODY_SIZE,
+	}
+}
+
+// SetStatus sets the HTTP status that is returned for framework errors with the provided code.
+func (gateway *Gateway) SetStatus(code string, status int) {
+	gateway.statuses[code] = status
+}
+
+func (gateway *Gateway) SetMaxBodySize(size int64) {
+	gateway.maxBodySize = size
+}
+
+// SetAllowedActorTypes restricts the actor types that can be invoked to actorTypes. Internal actor types
+// (see [INTERNAL_ACTOR_TYPE_PREFIX]) are only allowed when they are in actorTypes. Invoking SetAllowedActorTypes
+// without actor types disallows all actor types.
+func (gateway *Gateway) SetAllowedActorTypes(actorTypes ...string) {
+	gateway.allowed = make(map[normalized.ActorType]bool, len(actorTypes))
+	for _, actorType := range actorTypes {
+		gateway.allowed[normalized.NormalizeActorType(actorType)] = true
+	}
+}
+
+// IsAllowed returns whether actorType can be invoked via the gateway.
+func (gateway *Gateway) IsAllowed(actorType string) bool {
+	normalizedType := normalized.NormalizeActorType(actorType)
+	if gateway.allowed != nil {
+		return gateway.allowed[normalizedType]
+	}
+	return !strings.HasPrefix(string(normalizedType), INTERNAL_ACTOR_TYPE_PREFIX)
+}
+
+// StatusForError returns the HTTP status for e. The invoker wraps retryable framework errors in an
+// INVOKE_ERROR, so for those the status of the most recent cause that has a mapping is returned.
+func (gateway *Gateway) StatusForError(e *actionerror.Error) int {
+	if e.Kind == actionerror.ERROR_KIND_APPLICATION {
+		return STATUS_APPLICATION_ERROR
+	}
+	if status, has := gateway.causeStatus(e); has {
+		return status
+	}
+	if status, has := gateway.statuses[e.Code]; has {
+		return status
+	}
+	return STATUS_FRAMEWORK_ERROR
+}
+
+// causeStatus returns the status of the last nested cause of an INVOKE_ERROR that has a mapping.
+func (gateway *Gateway) causeStatus(e *actionerror.Error) (int, bool) {
+	if e.Code != ERROR_INVOKE_ERROR {
+		return 0, false
+	}
+	for i := len(e.Nested) - 1; i >= 0; i-- {
+		cause := e.Nested[i]
+		if cause == nil {
+			continue
+		}
+		if status, has := gateway.causeStatus(cause); has {
+			return status, true
+		}
+		if status, has := gateway.statuses[cause.Code]; has && cause.Code != ERROR_INVOKE_ERROR {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+func (gateway *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		gateway.writeError(w, newError(ERROR_METHOD_NOT_ALLOWED, "Method [Method] is not allowed", map[string]any{"Method": r.Method}))
+		return
+	}
+
+	request, e := parsePath(r.URL)
+	if e != nil {
+		gateway.writeError(w, e)
+		return
+	}
+
+	if !gateway.IsAllowed(request.ActorType) {
+		gateway.writeError(w, newError(ERROR_ACTOR_TYPE_NOT_ALLOWED, "Actor type [ActorType] can not be invoked via the gateway", map[string]any{"ActorType": request.ActorType}))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, gateway.maxBodySize)
+	request.Parameters, e = parseBody(r)
+	if e != nil {
+		gateway.writeError(w, e)
+		return
+	}
+
+	result, e := gateway.invoker.Invoke(request)
+	if e != nil {
+		gateway.writeError(w, e)
+		return
+	}
+
+	if accepts(r, CONTENT_TYPE_OCTET_STREAM) {
+		var value binary.Binary
+		if result != nil {
+			err := result.AssignTo(&value)
+			if err != nil {
+				gateway.writeError(w, newError(ERROR_INVALID_RESULT, "Result can not be returned as binary data: [Reason]", map[string]any{"Reason": err.Error()}))
+				return
+			}
+		}
+		w.Header().Set("Content-Type", CONTENT_TYPE_OCTET_STREAM)
+		w.WriteHeader(http.StatusOK)
+		w.Write(value.Bytes())
+		return
+	}
+
+	var value any
+	if result != nil {
+		err := result.AssignTo(&value)
+		if err != nil {
+			gateway.writeError(w, newError(ERROR_INVALID_RESULT, "Result can not be returned as JSON: [Reason]", map[string]any{"Reason": err.Error()}))
+			return
+		}
+	}
+	writeJson(w, http.StatusOK, value)
+}
+
+func (gateway *Gateway) writeError(w http.ResponseWriter, e *actionerror.Error) {
+	writeJson(w, gateway.StatusForError(e), e)
+}
+
+func writeJson(w http.ResponseWriter, status int, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(newError(ERROR_INVALID_RESULT, "Result can not be returned as JSON: [Reason]", map[string]any{"Reason": err.Error()}))
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", CONTENT_TYPE_JSON)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// parsePath extracts actor type, actor id and action name from a path of the form /actors/{type}/{id...}/{action}.
+func parsePath(u *url.URL) (*invoker.Request, *actionerror.Error) {
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, PATH_PREFIX) {
+		return nil, newError(ERROR_INVALID_PATH, "Path [Path] does not start with [Prefix]", map[string]any{"Path": path, "Prefix": PATH_PREFIX})
+	}
+	segments := strings.Split(strings.TrimPrefix(path, PATH_PREFIX), "/")
+	if len(segments) < 2 {
+		return nil, newError(ERROR_INVALID_PATH, "Path [Path] must contain an actor type and an action name", map[string]any{"Path": path})
+	}
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, newError(ERROR_INVALID_PATH, "Path [Path] is not properly escaped", map[string]any{"Path": path})
+		}
+		segments[i] = unescaped
+	}
+	request := invoker.Request{
+		ActorType:  segments[0],
+		ActorId:    segments[1 : len(segments)-1],
+		ActionName: segments[len(segments)-1],
+	}
+	if request.ActorType == "" || request.ActionName == "" {
+		return nil, newError(ERROR_INVALID_PATH, "Path [Path] must contain an actor type and an action name", map[string]any{"Path": path})
+	}
+	return &request, nil
+}
+
+func parseBody(r *http.Request) ([]any, *actionerror.Error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = CONTENT_TYPE_JSON
+	}
+
+	switch {
+	case mediaType == CONTENT_TYPE_OCTET_STREAM:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, invalidBody(err)
+		}
+		return []any{binary.FromBytes(data)}, nil
+	case mediaType == "multipart/form-data":
+		return parseMultipart(multipart.NewReader(r.Body, params["boundary"]))
+	default:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, invalidBody(err)
+		}
+		return parseJson(data)
+	}
+}
+
+func parseMultipart(reader *multipart.Reader) ([]any, *actionerror.Error) {
+	parameters := []any{}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return parameters, nil
+		}
+		if err != nil {
+			return nil, invalidBody(err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, invalidBody(err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if mediaType == CONTENT_TYPE_JSON {
+			var value any
+			e := decodeJson(data, &value)
+			if e != nil {
+				return nil, e
+			}
+			parameters = append(parameters, value)
+		} else {
+			parameters = append(parameters, binary.FromBytes(data))
+		}
+	}
+}
+
+func parseJson(data []byte) ([]any, *actionerror.Error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []any{}, nil
+	}
+	var value any
+	e := decodeJson(data, &value)
+	if e != nil {
+		return nil, e
+	}
+	if values, ok := value.([]any); ok {
+		return values, nil
+	}
+	return []any{value}, nil
+}
+
+// decodeJson decodes data while retaining the exact representation of numbers.
+func decodeJson(data []byte, value *any) *actionerror.Error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(value)
+	if err != nil {
+		return invalidBody(err)
+	}
+	return nil
+}
+
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		accepted, _, _ := mime.ParseMediaType(strings.TrimSpace(accept))
+		if accepted == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidBody(reason error) *actionerror.Error {
+	return newError(ERROR_INVALID_BODY, "Invalid request body: [Reason]", map[string]any{"Reason": reason.Error()})
+}
+
+func newError(code string, template string, parameters map[string]any) *actionerror.Error {
+	return frameworkerror.New(actionerror.Options{
+		Code:       code,
+		Template:   template,
+		Parameters: parameters,
+	})
+}
@@ -0,0 +1,223 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/core/backoff"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/invoke"
+	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/binary"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/jsonvariant"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+type echoInvoker struct {
+	request *invoker.Request
+}
+
+func (inv *echoInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.request = request
+	switch request.ActionName {
+	case "fail":
+		return nil, actionerror.New(actionerror.Options{Code: "NOPE", Template: "Nope"})
+	case "unknown":
+		return nil, frameworkerror.New(actionerror.Options{Code: "UNKNOWN_ACTION"})
+	case "busy":
+		return nil, frameworkerror.New(actionerror.Options{Code: "TOO_BUSY"})
+	case "deadlock":
+		return nil, frameworkerror.New(actionerror.Options{Code: "DEADLOCK"})
+	case "first":
+		data, _ := jsonbinary.Serialize(request.Parameters[0], nil)
+		return jsonvariant.FromJson(data), nil
+	}
+	data, _ := jsonbinary.Serialize(request.Parameters, nil)
+	return jsonvariant.FromJson(data), nil
+}
+
+// busyContainer rejects all calls like an instance with a full queue does.
+type busyContainer struct{}
+
+func (container busyContainer) Dispatch(call *wire.ActorCallRequestIn, onFinished inward.FinishedHandler) {
+	onFinished(nil, frameworkerror.New(actionerror.Options{
+		Code:       inward.ERROR_TOO_BUSY,
+		Template:   "Too busy",
+		Parameters: map[string]any{invoke.FRAMEWORK_ERROR_PARAMETER_REFUSED: true},
+	}))
+}
+
+// dispatcherInvoker passes calls to a dispatcher, like the transport handler of the receiving application does.
+type dispatcherInvoker struct {
+	dispatcher *inward.Dispatcher
+}
+
+func (inv dispatcherInvoker) Invoke(request *invoke.TransportHandlerInvokeRequest) *invoker.Response {
+	call := wire.ActorCallRequestIn{
+		ActorType:  request.ActorType,
+		ActorId:    request.ActorId,
+		ActionName: request.ActionName,
+	}
+	done := make(chan *wire.ActorCallResponseOut, 1)
+	inv.dispatcher.Dispatch(&call, func(response *wire.ActorCallResponseOut) {
+		done <- response
+	})
+	response := <-done
+	var result invoker.Response
+	if response.Error != nil {
+		data, _ := jsonbinary.Serialize(response.Error, nil)
+		result.Error = jsonvariant.FromJson(data)
+	}
+	return &result
+}
+
+type staticRegistry map[string]*actorregistry.ActorInfo
+
+func (registry staticRegistry) Get(actorType string) *actorregistry.ActorInfo {
+	return registry[actorType]
+}
+
+func post(gateway *Gateway, path string, contentType string, accept string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	gateway.ServeHTTP(w, r)
+	return w
+}
+
+func TestGateway_Json(t *testing.T) {
+	inv := echoInvoker{}
+	gateway := New(&inv)
+
+	w := post(gateway, "/actors/Echo/a%2Fb/c/echo", CONTENT_TYPE_JSON, "", []byte(`["hello", 42, {"x": true}]`))
+	checks.Equal(t, http.StatusOK, w.Code, "Status")
+	checks.Equal(t, "Echo", inv.request.ActorType, "Actor type")
+	checks.Equal(t, []string{"a/b", "c"}, inv.request.ActorId, "Actor id")
+	checks.Equal(t, "echo", inv.request.ActionName, "Action name")
+	checks.Equal(t, `["hello",42,{"x":true}]`, w.Body.String(), "Body")
+
+	w = post(gateway, "/actors/Echo/echo", "", "", nil)
+	checks.Equal(t, 0, len(inv.request.ActorId), "Empty actor id")
+	checks.Equal(t, `[]`, w.Body.String(), "No parameters")
+
+	w = post(gateway, "/actors/Echo/echo", CONTENT_TYPE_JSON, "", []byte(`"single"`))
+	checks.Equal(t, `["single"]`, w.Body.String(), "Single parameter")
+}
+
+func TestGateway_Errors(t *testing.T) {
+	gateway := New(&echoInvoker{})
+
+	var e actionerror.Error
+	w := post(gateway, "/actors/Echo/a/fail", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, STATUS_APPLICATION_ERROR, w.Code, "Application error status")
+	json.Unmarshal(w.Body.Bytes(), &e)
+	checks.Equal(t, "NOPE", e.Code, "Error code")
+	checks.Equal(t, actionerror.ERROR_KIND_APPLICATION, e.Kind, "Error kind")
+
+	w = post(gateway, "/actors/Echo/a/unknown", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusNotFound, w.Code, "Unknown action status")
+
+	w = post(gateway, "/actors/Echo/a/busy", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusServiceUnavailable, w.Code, "Too busy status")
+
+	w = post(gateway, "/actors/Echo/a/deadlock", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusConflict, w.Code, "Deadlock status")
+
+	w = post(gateway, "/actors/Echo", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusNotFound, w.Code, "Invalid path status")
+
+	w = post(gateway, "/actors/Echo/echo", CONTENT_TYPE_JSON, "", []byte(`[1,`))
+	checks.Equal(t, http.StatusBadRequest, w.Code, "Invalid body status")
+
+	r := httptest.NewRequest(http.MethodGet, "/actors/Echo/echo", nil)
+	rw := httptest.NewRecorder()
+	gateway.ServeHTTP(rw, r)
+	checks.Equal(t, http.StatusMethodNotAllowed, rw.Code, "Invalid method status")
+}
+
+func TestGateway_NestedErrors(t *testing.T) {
+	dispatcher := inward.NewDispatcher(nil)
+	dispatcher.RegisterActorType(inward.ActorInfo{
+		ActorType: normalized.NormalizeActorType("Busy"),
+		Container: busyContainer{},
+	})
+	registry := staticRegistry{
+		"Busy": &actorregistry.ActorInfo{Applications: []actorregistry.ApplicationInfo{{Name: "app"}}},
+	}
+	inv := invoke.NewDynamicInvoker(dispatcherInvoker{dispatcher: dispatcher}, backoff.Fixed(time.Millisecond, 3, 0), registry)
+	gateway := New(&inv)
+
+	var e actionerror.Error
+	w := post(gateway, "/actors/Busy/a/act", CONTENT_TYPE_JSON, "", nil)
+	json.Unmarshal(w.Body.Bytes(), &e)
+	checks.Equal(t, ERROR_INVOKE_ERROR, e.Code, "Invoker wraps the causes")
+	checks.Equal(t, http.StatusServiceUnavailable, w.Code, "Status of the nested too busy error")
+
+	checks.Equal(t, http.StatusBadGateway, gateway.StatusForError(frameworkerror.New(actionerror.Options{Code: ERROR_INVOKE_ERROR})), "Invoke error without mapped causes")
+}
+
+func TestGateway_AllowedActorTypes(t *testing.T) {
+	inv := echoInvoker{}
+	gateway := New(&inv)
+
+	w := post(gateway, "/actors/Echo/echo", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusOK, w.Code, "Regular actor type allowed by default")
+
+	w = post(gateway, "/actors/io.darlean.streams/read", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusForbidden, w.Code, "Internal actor type not allowed by default")
+
+	w = post(gateway, "/actors/IO.Darlean.Streams/read", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusForbidden, w.Code, "Internal actor type not allowed in other notation")
+
+	gateway.SetAllowedActorTypes("Other", "io.darlean.streams")
+	inv.request = nil
+	w = post(gateway, "/actors/Echo/echo", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusForbidden, w.Code, "Actor type not in allowlist")
+	checks.Equal(t, (*invoker.Request)(nil), inv.request, "Not invoked")
+
+	w = post(gateway, "/actors/other/echo", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusOK, w.Code, "Actor type in allowlist")
+
+	w = post(gateway, "/actors/io.darlean.streams/read", CONTENT_TYPE_JSON, "", nil)
+	checks.Equal(t, http.StatusOK, w.Code, "Internal actor type in allowlist")
+}
+
+func TestGateway_Binary(t *testing.T) {
+	inv := echoInvoker{}
+	gateway := New(&inv)
+
+	w := post(gateway, "/actors/Echo/a/first", CONTENT_TYPE_OCTET_STREAM, CONTENT_TYPE_OCTET_STREAM, []byte{1, 2, 3})
+	checks.Equal(t, http.StatusOK, w.Code, "Status")
+	checks.Equal(t, binary.FromBytes([]byte{1, 2, 3}), inv.request.Parameters[0], "Binary parameter")
+	checks.Equal(t, []byte{1, 2, 3}, w.Body.Bytes(), "Binary result")
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {CONTENT_TYPE_JSON}})
+	part.Write([]byte(`{"name":"x"}`))
+	part, _ = writer.CreateFormFile("data", "data.bin")
+	part.Write([]byte{4, 5})
+	writer.Close()
+
+	post(gateway, "/actors/Echo/a/echo", writer.FormDataContentType(), "", body.Bytes())
+	checks.Equal(t, 2, len(inv.request.Parameters), "Multipart parameters")
+	checks.Equal(t, binary.FromBytes([]byte{4, 5}), inv.request.Parameters[1], "Multipart binary parameter")
+}
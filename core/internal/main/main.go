@@ -2,12 +2,22 @@ package main
 
 import (
 	_ "github.com/darlean-io/darlean.go/core/backoff"
+	_ "github.com/darlean-io/darlean.go/core/gateway"
 	_ "github.com/darlean-io/darlean.go/core/invoke"
 	_ "github.com/darlean-io/darlean.go/core/inward"
+	_ "github.com/darlean-io/darlean.go/core/localpersistence"
+	_ "github.com/darlean-io/darlean.go/core/natsserver"
 	_ "github.com/darlean-io/darlean.go/core/natstransport"
 	_ "github.com/darlean-io/darlean.go/core/normalized"
+	_ "github.com/darlean-io/darlean.go/core/pubsub"
+	_ "github.com/darlean-io/darlean.go/core/reminders"
 	_ "github.com/darlean-io/darlean.go/core/remoteactorregistry"
+	_ "github.com/darlean-io/darlean.go/core/shutdown"
+	_ "github.com/darlean-io/darlean.go/core/staticactorregistry"
+	_ "github.com/darlean-io/darlean.go/core/streams"
+	_ "github.com/darlean-io/darlean.go/core/tcptransport"
 	_ "github.com/darlean-io/darlean.go/core/transporthandler"
+	_ "github.com/darlean-io/darlean.go/core/unixtransport"
 	_ "github.com/darlean-io/darlean.go/core/wire"
 )
 
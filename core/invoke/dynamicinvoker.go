@@ -16,10 +16,11 @@ import (
 )
 
 type DynamicInvoker struct {
-	staticInvoker TransportInvoker
-	backoff       backoff.BackOff
-	registry      actorregistry.ActorRegistryFetcher
-	cache         *PlacementCache
+	staticInvoker   TransportInvoker
+	backoff         backoff.BackOff
+	registry        actorregistry.ActorRegistryFetcher
+	cache           *PlacementCache
+	migrationPolicy MigrationPolicy
 }
 
 func NewDynamicInvoker(transportInvoker TransportInvoker, backoff backoff.BackOff, registry actorregistry.ActorRegistryFetcher) DynamicInvoker {
@@ -31,12 +32,19 @@ func NewDynamicInvoker(transportInvoker TransportInvoker, backoff backoff.BackOf
 	}
 }
 
+// SetMigrationPolicy sets the policy that determines to which applications calls are routed when the
+// applications for an actor type are at different migration versions. The default is [MIGRATION_POLICY_HIGHEST].
+func (invoker *DynamicInvoker) SetMigrationPolicy(policy MigrationPolicy) {
+	invoker.migrationPolicy = policy
+}
+
 func (invoker *DynamicInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
 	var bo backoff.BackOffSession
 	useCache := true
 	cacheInvalidated := false
 	lazy := false
 	suggestions := []string{}
+	refused := map[string]bool{}
 	causes := []*actionerror.Error{}
 	var cachePreparedKey [8]byte
 	triesLeft := 10
@@ -72,12 +80,17 @@ func (invoker *DynamicInvoker) Invoke(request *invoker.Request) (variant.Assigna
 		if len(suggestions) > 0 {
 			applications = suggestions
 		} else {
-			applications = make([]string, len(info.Applications))
-			for i, app := range info.Applications {
+			eligible := filterApplications(info.Applications, invoker.migrationPolicy)
+			applications = make([]string, len(eligible))
+			for i, app := range eligible {
 				applications[i] = app.Name
 			}
 		}
 
+		if len(refused) > 0 {
+			applications = withoutRefused(applications, refused)
+		}
+
 		if receiver == nil {
 			switch len(applications) {
 			case 0:
@@ -115,6 +128,8 @@ func (invoker *DynamicInvoker) Invoke(request *invoker.Request) (variant.Assigna
 				}
 				if err2.Kind != actionerror.ERROR_KIND_FRAMEWORK {
 					return nil, &err2
+				} else if notRetryable, _ := err2.Parameters[FRAMEWORK_ERROR_PARAMETER_NOT_RETRYABLE].(bool); notRetryable {
+					return nil, &err2
 				} else {
 					causes = append(causes, &err2)
 				}
@@ -123,13 +138,28 @@ func (invoker *DynamicInvoker) Invoke(request *invoker.Request) (variant.Assigna
 				if present {
 					var redirects []string
 					err := variant.Assign(redirect, &redirects)
-					if err != nil {
+					if err == nil {
 						suggestions = redirects
 					}
 				}
-				continue
+				isRefused, _ := err2.Parameters[FRAMEWORK_ERROR_PARAMETER_REFUSED].(bool)
+				if !isRefused {
+					continue
+				}
+				refused[*receiver] = true
+				if !allRefused(applications, refused) {
+					continue
+				}
+				// All receivers refused (like when they are all too busy), so back off before trying again.
 				// DONE: Fill suggestions based on redirect info in error and set doBackoff to false
 				// TODO: Also do this when lazy = true and other side indicates a refusal
+				if bo == nil {
+					bo = invoker.backoff.Begin()
+				}
+				if !bo.BackOff() {
+					break
+				}
+				continue
 			}
 
 			if info.Placement.Sticky != nil && *info.Placement.Sticky {
@@ -177,6 +207,30 @@ func (invoker *DynamicInvoker) Invoke(request *invoker.Request) (variant.Assigna
 	})
 }
 
+// withoutRefused returns the applications that did not refuse a call before. When all applications
+// refused, they are all returned so that we keep trying (they may have become available again).
+func withoutRefused(applications []string, refused map[string]bool) []string {
+	result := make([]string, 0, len(applications))
+	for _, app := range applications {
+		if !refused[app] {
+			result = append(result, app)
+		}
+	}
+	if len(result) == 0 {
+		return applications
+	}
+	return result
+}
+
+func allRefused(applications []string, refused map[string]bool) bool {
+	for _, app := range applications {
+		if !refused[app] {
+			return false
+		}
+	}
+	return true
+}
+
 func extractBindName(id []string, bindIdx *int) *string {
 	var idx int
 	if bindIdx == nil {
@@ -0,0 +1,69 @@
+package invoke
+
+import (
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/core/backoff"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/jsonvariant"
+)
+
+type staticRegistry map[string]*actorregistry.ActorInfo
+
+func (registry staticRegistry) Get(actorType string) *actorregistry.ActorInfo {
+	return registry[actorType]
+}
+
+// busyInvoker refuses the first busyCalls calls as too busy and returns "ok" afterwards.
+type busyInvoker struct {
+	busyCalls int
+	calls     int
+}
+
+func (inv *busyInvoker) Invoke(request *TransportHandlerInvokeRequest) *invoker.Response {
+	inv.calls++
+	if inv.calls <= inv.busyCalls {
+		err := frameworkerror.New(actionerror.Options{
+			Code:       "TOO_BUSY",
+			Template:   "Too busy",
+			Parameters: map[string]any{FRAMEWORK_ERROR_PARAMETER_REFUSED: true},
+		})
+		data, _ := jsonbinary.Serialize(err, nil)
+		return &invoker.Response{Error: jsonvariant.FromJson(data)}
+	}
+	data, _ := jsonbinary.Serialize("ok", nil)
+	return &invoker.Response{Value: jsonvariant.FromJson(data)}
+}
+
+func newBusyTest(busyCalls int) (*DynamicInvoker, *busyInvoker) {
+	transport := &busyInvoker{busyCalls: busyCalls}
+	registry := staticRegistry{
+		"actor": &actorregistry.ActorInfo{Applications: []actorregistry.ApplicationInfo{{Name: "app"}}},
+	}
+	inv := NewDynamicInvoker(transport, backoff.Fixed(time.Millisecond, 3, 0), registry)
+	return &inv, transport
+}
+
+func TestDynamicInvoker_AllRefused(t *testing.T) {
+	inv, transport := newBusyTest(2)
+	value, err := inv.Invoke(&invoker.Request{ActorType: "actor", ActionName: "act"})
+	checks.Equal(t, (*actionerror.Error)(nil), err, "Succeeds after backing off")
+	var result string
+	value.AssignTo(&result)
+	checks.Equal(t, "ok", result, "Result")
+	checks.Equal(t, 3, transport.calls, "Retried after refusals")
+
+	inv, transport = newBusyTest(100)
+	value, err = inv.Invoke(&invoker.Request{ActorType: "actor", ActionName: "act"})
+	checks.Equal(t, nil, value, "No value")
+	checks.IsNotNil(t, err, "Error when refusals persist")
+	checks.Equal(t, FRAMEWORK_ERROR_INVOKE_ERROR, err.Code, "Error code")
+	checks.Equal(t, "TOO_BUSY", err.Nested[0].Code, "Cause")
+	checks.Equal(t, 3, transport.calls, "Stops when back off is exhausted")
+}
@@ -0,0 +1,104 @@
+package invoke
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+)
+
+// MigrationPolicy determines to which applications calls are routed when the applications that host an actor
+// type are at different migration versions (like during a rolling upgrade).
+type MigrationPolicy int
+
+// Only route to the applications at the highest migration version.
+const MIGRATION_POLICY_HIGHEST = MigrationPolicy(0)
+
+// Route to the applications that have the same major migration version as the highest migration version.
+const MIGRATION_POLICY_COMPATIBLE = MigrationPolicy(1)
+
+// Route to all applications, regardless of their migration version.
+const MIGRATION_POLICY_MIXED = MigrationPolicy(2)
+
+// filterApplications returns the applications that are eligible according to policy.
+func filterApplications(applications []actorregistry.ApplicationInfo, policy MigrationPolicy) []actorregistry.ApplicationInfo {
+	if policy == MIGRATION_POLICY_MIXED || len(applications) <= 1 {
+		return applications
+	}
+
+	highest := ""
+	for _, app := range applications {
+		version := migrationVersion(app)
+		if CompareMigrationVersions(version, highest) > 0 {
+			highest = version
+		}
+	}
+
+	result := make([]actorregistry.ApplicationInfo, 0, len(applications))
+	for _, app := range applications {
+		version := migrationVersion(app)
+		var eligible bool
+		if policy == MIGRATION_POLICY_COMPATIBLE {
+			eligible = majorVersion(version) == majorVersion(highest)
+		} else {
+			eligible = CompareMigrationVersions(version, highest) == 0
+		}
+		if eligible {
+			result = append(result, app)
+		}
+	}
+	return result
+}
+
+func migrationVersion(app actorregistry.ApplicationInfo) string {
+	if app.MigrationVersion == nil {
+		return ""
+	}
+	return *app.MigrationVersion
+}
+
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+// CompareMigrationVersions compares two migration versions of the form "major.minor.patch" (any number of
+// dot-separated parts is allowed). Parts are compared numerically when both are numbers, and as strings otherwise.
+// A missing version (empty string) is lower than any other version.
+// Returns a negative number when a < b, 0 when a == b and a positive number when a > b.
+func CompareMigrationVersions(a string, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		if i >= len(aParts) {
+			return -1
+		}
+		if i >= len(bParts) {
+			return 1
+		}
+		aNum, aErr := strconv.ParseInt(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseInt(bParts[i], 10, 64)
+		if aErr == nil && bErr == nil {
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package invoke
+
+import (
+	"testing"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func TestCompareMigrationVersions(t *testing.T) {
+	checks.Equal(t, 0, CompareMigrationVersions("1.2.0", "1.2.0"), "Equal versions")
+	checks.Equal(t, -1, CompareMigrationVersions("1.2.0", "1.10.0"), "Numeric comparison")
+	checks.Equal(t, 1, CompareMigrationVersions("2", "1.9"), "Major version wins")
+	checks.Equal(t, -1, CompareMigrationVersions("1.2", "1.2.1"), "Shorter version is lower")
+	checks.Equal(t, -1, CompareMigrationVersions("", "0"), "Missing version is lowest")
+}
+
+func TestFilterApplications(t *testing.T) {
+	version := func(v string) *string { return &v }
+	apps := []actorregistry.ApplicationInfo{
+		{Name: "a", MigrationVersion: version("1.0.0")},
+		{Name: "b", MigrationVersion: version("2.0.0")},
+		{Name: "c", MigrationVersion: version("2.1.0")},
+		{Name: "d"},
+	}
+	names := func(apps []actorregistry.ApplicationInfo) []string {
+		result := []string{}
+		for _, app := range apps {
+			result = append(result, app.Name)
+		}
+		return result
+	}
+
+	checks.Equal(t, []string{"c"}, names(filterApplications(apps, MIGRATION_POLICY_HIGHEST)), "Highest")
+	checks.Equal(t, []string{"b", "c"}, names(filterApplications(apps, MIGRATION_POLICY_COMPATIBLE)), "Compatible")
+	checks.Equal(t, []string{"a", "b", "c", "d"}, names(filterApplications(apps, MIGRATION_POLICY_MIXED)), "Mixed")
+}
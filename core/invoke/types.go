@@ -3,6 +3,15 @@ package invoke
 import "github.com/darlean-io/darlean.go/base/invoker"
 
 const FRAMEWORK_ERROR_PARAMETER_REDIRECT_DESTINATION = "REDIRECT_DESTINATION"
+
+// Framework error parameter that indicates that the receiver refused to process the call
+// (for example, because it is shutting down). The action was not performed, so the call
+// can safely be retried on another receiver.
+const FRAMEWORK_ERROR_PARAMETER_REFUSED = "REFUSED"
+
+// Framework error parameter that indicates that retrying the call is useless (for example, because it
+// would deadlock). The invoker returns such errors immediately.
+const FRAMEWORK_ERROR_PARAMETER_NOT_RETRYABLE = "NOT_RETRYABLE"
 const FRAMEWORK_ERROR_INVOKE_ERROR = "INVOKE_ERROR"
 const FRAMEWORK_ERROR_NO_RECEIVERS_AVAILABLE = "NO_RECEIVERS_AVAILABLE"
 
@@ -18,6 +18,8 @@ type InstanceRunner interface {
 	TriggerDeactivate()
 }
 
+const ERROR_CONTAINER_DEACTIVATING = "CONTAINER_DEACTIVATING"
+
 type WrapperFactory func(id []string) InstanceWrapper
 
 type StandardActorContainer struct {
@@ -30,6 +32,8 @@ type StandardActorContainer struct {
 	onFinished     func()
 	active         bool
 	finishedChan   chan int
+	limits         QueueLimits
+	queueStats     queueStats
 }
 
 func NewStandardActorContainer(actorType normalized.ActorType, requiresLock bool, actionDefs map[normalized.ActionName]ActionDef, wrapperFactory WrapperFactory, onFinished func()) *StandardActorContainer {
@@ -63,7 +67,7 @@ func (container *StandardActorContainer) obtainInstanceRunner(actorId []string)
 
 	if !container.active {
 		return nil, frameworkerror.New(actionerror.Options{
-			Code:     "CONTAINER_DEACTIVATING",
+			Code:     ERROR_CONTAINER_DEACTIVATING,
 			Template: "Container is deactivating",
 		})
 	}
@@ -77,16 +81,40 @@ func (container *StandardActorContainer) obtainInstanceRunner(actorId []string)
 		if err != nil {
 			return nil, err
 		}
-		instancerunner = NewInstanceRunner(wrapper, container.actorType, actorId, container.requiresLock, container.actionDefs, func() {
+		runner := NewInstanceRunner(wrapper, container.actorType, actorId, container.requiresLock, container.actionDefs, func() {
 			wrapper.Release()
 			container.handleActorDeactivated(k)
 		})
+		runner.SetQueueLimits(container.limits)
+		runner.containerStats = &container.queueStats
+		instancerunner = runner
 		container.instances[k] = instancerunner
 	}
 
 	return instancerunner, nil
 }
 
+// SetQueueLimits sets the limits for the calls that wait to be processed by the instances of the container.
+// Calls that exceed the limits are rejected with a TOO_BUSY framework error, so that the caller can retry them
+// elsewhere. Only affects instances that are created afterwards.
+func (container *StandardActorContainer) SetQueueLimits(limits QueueLimits) {
+	container.lock.Lock()
+	defer container.lock.Unlock()
+	container.limits = limits
+}
+
+// QueueMetrics returns statistics about the calls that wait to be processed by the instances of the container.
+func (container *StandardActorContainer) QueueMetrics() QueueMetrics {
+	return container.queueStats.metrics()
+}
+
+// InstanceCount returns the number of instances in the container.
+func (container *StandardActorContainer) InstanceCount() int {
+	container.lock.RLock()
+	defer container.lock.RUnlock()
+	return len(container.instances)
+}
+
 func (container *StandardActorContainer) Stop() {
 	container.triggerStop()
 	<-container.finishedChan
@@ -2,6 +2,7 @@ package inward
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -22,12 +23,17 @@ func TestActorContainer(t *testing.T) {
 	}
 
 	var results []string
+	var mutex sync.Mutex
 
 	container := NewStandardActorContainer(normalized.NormalizeActorType("TestActor"), false, GetTestActionDefs(), wrapperFactory, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
 		results = append(results, "CONTAINER-STOPPED")
 	})
 
 	handleResult := func(result any, err *actionerror.Error) {
+		mutex.Lock()
+		defer mutex.Unlock()
 		if err != nil {
 			results = append(results, fmt.Sprintf("ERR:%v", err.Code))
 		} else {
@@ -50,6 +56,8 @@ func TestActorContainer(t *testing.T) {
 
 	time.Sleep(time.Second)
 
+	mutex.Lock()
+	defer mutex.Unlock()
 	checks.Equal(t, []string{
 		"123:hello",
 		"123:world",
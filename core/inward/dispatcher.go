@@ -1,7 +1,11 @@
 package inward
 
 import (
+	"sync"
+	"time"
+
 	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/invoke"
 	"github.com/darlean-io/darlean.go/core/normalized"
 	"github.com/darlean-io/darlean.go/core/wire"
 
@@ -18,14 +22,34 @@ type ActorInfo struct {
 	Container        ActorContainer
 	Placement        actorregistry.ActorPlacement
 	MigrationVersion string
+	// Private actor types can be invoked directly via the transport, but are not
+	// advertised to the actor registry.
+	Private bool
 }
 
 type Dispatcher struct {
-	actorTypes     map[normalized.ActorType]ActorInfo
+	// Pointers, so that we can detect whether a registration was replaced while a call was in flight
+	actorTypes map[normalized.ActorType]*ActorInfo
+	// Per actor type that is being replaced, a channel that is closed when the old container is stopped
+	replacing      map[normalized.ActorType]chan struct{}
+	typesLock      sync.RWMutex
 	registryPusher actorregistry.ActorRegistryPusher
+	readinessGate  <-chan struct{}
+	readinessWait  time.Duration
+	mutex          sync.Mutex
+	draining       bool
+	inFlight       int
+	idle           chan struct{}
 }
 
-func (dispatcher Dispatcher) Dispatch(call *wire.ActorCallRequestIn, onFinished func(*wire.ActorCallResponseOut)) {
+const ERROR_NOT_READY = "NOT_READY"
+const ERROR_DRAINING = "DRAINING"
+const ERROR_ACTOR_TYPE_NOT_REGISTERED = "ACTOR_TYPE_NOT_REGISTERED"
+
+// Maximum time that calls are held while their actor type is being replaced (see [Dispatcher.ReplaceActorType]).
+const REPLACE_TIMEOUT = 10 * time.Second
+
+func (dispatcher *Dispatcher) Dispatch(call *wire.ActorCallRequestIn, onFinished func(*wire.ActorCallResponseOut)) {
 	dispatcher.doDispatch(call, func(result any, err *actionerror.Error) {
 		if err != nil {
 			onFinished(&wire.ActorCallResponseOut{
@@ -40,7 +64,7 @@ func (dispatcher Dispatcher) Dispatch(call *wire.ActorCallRequestIn, onFinished
 	})
 }
 
-func (dispatcher Dispatcher) doDispatch(call *wire.ActorCallRequestIn, onFinished FinishedHandler) {
+func (dispatcher *Dispatcher) doDispatch(call *wire.ActorCallRequestIn, onFinished FinishedHandler) {
 	actorType := call.ActorType
 	if actorType == "" {
 		onFinished(nil, frameworkerror.New(actionerror.Options{
@@ -51,34 +75,317 @@ func (dispatcher Dispatcher) doDispatch(call *wire.ActorCallRequestIn, onFinishe
 	}
 
 	normalizedActorType := normalized.NormalizeActorType(actorType)
-	info, has := dispatcher.actorTypes[normalizedActorType]
-	if !has {
+	info, replaced := dispatcher.lookupReplaced(normalizedActorType)
+	if !replaced {
+		onFinished(nil, frameworkerror.New(actionerror.Options{
+			Code:     ERROR_NOT_READY,
+			Template: "Actor type [ActorType] is being replaced and not yet ready to process actions",
+			Parameters: map[string]any{
+				"ActorType":                              actorType,
+				invoke.FRAMEWORK_ERROR_PARAMETER_REFUSED: true,
+			}}))
+		return
+	}
+	if info == nil {
 		onFinished(nil, frameworkerror.New(actionerror.Options{
-			Code:     "ACTOR_TYPE_NOT_REGISTERED",
+			Code:     ERROR_ACTOR_TYPE_NOT_REGISTERED,
 			Template: "Actor type [ActorType] is not registered",
 			Parameters: map[string]any{
-				"ActorType": actorType,
+				"ActorType":                              actorType,
+				invoke.FRAMEWORK_ERROR_PARAMETER_REFUSED: true,
+			}}))
+		return
+	}
+
+	if info.Private {
+		info.Container.Dispatch(call, onFinished)
+		return
+	}
+
+	if !dispatcher.awaitReadiness() {
+		onFinished(nil, frameworkerror.New(actionerror.Options{
+			Code:     ERROR_NOT_READY,
+			Template: "Application is not yet ready to process actions on [ActorType]",
+			Parameters: map[string]any{
+				"ActorType":                              actorType,
+				invoke.FRAMEWORK_ERROR_PARAMETER_REFUSED: true,
+			}}))
+		return
+	}
+
+	if !dispatcher.enter() {
+		onFinished(nil, frameworkerror.New(actionerror.Options{
+			Code:     ERROR_DRAINING,
+			Template: "Application is shutting down and does not accept new actions on [ActorType]",
+			Parameters: map[string]any{
+				"ActorType":                              actorType,
+				invoke.FRAMEWORK_ERROR_PARAMETER_REFUSED: true,
 			}}))
 		return
 	}
 
-	info.Container.Dispatch(call, onFinished)
+	dispatcher.dispatchToContainer(call, info, func(result any, err *actionerror.Error) {
+		defer dispatcher.leave()
+		onFinished(result, err)
+	})
+}
+
+// dispatchToContainer dispatches the call to the container of info. When the container refuses the call
+// because it is being stopped after its actor type was replaced, the call is dispatched again to the
+// container that replaced it once the old container is stopped.
+func (dispatcher *Dispatcher) dispatchToContainer(call *wire.ActorCallRequestIn, info *ActorInfo, onFinished FinishedHandler) {
+	info.Container.Dispatch(call, func(result any, err *actionerror.Error) {
+		if err != nil && (err.Code == ERROR_CONTAINER_DEACTIVATING || err.Code == ERROR_DEACTIVATED) {
+			// The old container may refuse the call while it is stopping, so waiting for it to be stopped
+			// must not happen in the goroutine that refuses the call.
+			go func() {
+				current, replaced := dispatcher.lookupReplaced(info.ActorType)
+				if replaced && current != nil && current != info {
+					dispatcher.dispatchToContainer(call, current, onFinished)
+					return
+				}
+				onFinished(result, err)
+			}()
+			return
+		}
+		onFinished(result, err)
+	})
+}
+
+func (dispatcher *Dispatcher) lookup(actorType normalized.ActorType) (*ActorInfo, chan struct{}) {
+	dispatcher.typesLock.RLock()
+	defer dispatcher.typesLock.RUnlock()
+	return dispatcher.actorTypes[actorType], dispatcher.replacing[actorType]
+}
+
+// lookupReplaced returns the registration for actorType. When the actor type is being replaced, waits
+// until the old container is stopped, so that an actor instance is never active in both containers.
+// Returns false when the replacement takes longer than [REPLACE_TIMEOUT].
+func (dispatcher *Dispatcher) lookupReplaced(actorType normalized.ActorType) (*ActorInfo, bool) {
+	var timeout <-chan time.Time
+	for {
+		info, replacing := dispatcher.lookup(actorType)
+		if replacing == nil {
+			return info, true
+		}
+		if timeout == nil {
+			timer := time.NewTimer(REPLACE_TIMEOUT)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-replacing:
+		case <-timeout:
+			return nil, false
+		}
+	}
+}
+
+// enter registers a new in-flight call. Returns false when the dispatcher is draining.
+func (dispatcher *Dispatcher) enter() bool {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	if dispatcher.draining {
+		return false
+	}
+	dispatcher.inFlight++
+	return true
+}
+
+func (dispatcher *Dispatcher) leave() {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	dispatcher.inFlight--
+	if dispatcher.draining && dispatcher.inFlight == 0 {
+		close(dispatcher.idle)
+	}
+}
+
+// StartDraining makes the dispatcher reject new calls for non-private actor types with a DRAINING
+// framework error. Calls that are already in flight are allowed to finish.
+func (dispatcher *Dispatcher) StartDraining() {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	if dispatcher.draining {
+		return
+	}
+	dispatcher.draining = true
+	dispatcher.idle = make(chan struct{})
+	if dispatcher.inFlight == 0 {
+		close(dispatcher.idle)
+	}
+}
+
+// WaitIdle waits until all in-flight calls are finished after [Dispatcher.StartDraining] was invoked,
+// or until the timeout expires. Returns the number of calls that are still in flight.
+func (dispatcher *Dispatcher) WaitIdle(timeout time.Duration) int {
+	dispatcher.StartDraining()
+	select {
+	case <-dispatcher.idle:
+	case <-time.After(timeout):
+	}
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	return dispatcher.inFlight
+}
+
+// StopContainers stops all registered actor containers that support stopping, which deactivates
+// their actor instances. Blocks until all containers are stopped.
+func (dispatcher *Dispatcher) StopContainers() {
+	dispatcher.typesLock.RLock()
+	infos := make([]*ActorInfo, 0, len(dispatcher.actorTypes))
+	for _, info := range dispatcher.actorTypes {
+		infos = append(infos, info)
+	}
+	dispatcher.typesLock.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, info := range infos {
+		wg.Add(1)
+		go func(info *ActorInfo) {
+			defer wg.Done()
+			stopContainer(info.Container)
+		}(info)
+	}
+	wg.Wait()
 }
 
-func (dispatcher Dispatcher) RegisterActorType(info ActorInfo) {
-	dispatcher.actorTypes[info.ActorType] = info
-	dispatcher.TriggerBroadcast()
+func stopContainer(container ActorContainer) {
+	stoppable, ok := container.(interface{ Stop() })
+	if ok {
+		stoppable.Stop()
+	}
 }
 
-func (dispatcher Dispatcher) TriggerBroadcast() {
+// SetReadinessGate makes the dispatcher hold incoming calls for non-private actor types until
+// the gate channel is closed. Calls that are not released within timeout fail with
+// a NOT_READY framework error, so that the caller can retry elsewhere.
+func (dispatcher *Dispatcher) SetReadinessGate(gate <-chan struct{}, timeout time.Duration) {
+	dispatcher.readinessGate = gate
+	dispatcher.readinessWait = timeout
+}
+
+func (dispatcher *Dispatcher) awaitReadiness() bool {
+	if dispatcher.readinessGate == nil {
+		return true
+	}
+	select {
+	case <-dispatcher.readinessGate:
+		return true
+	case <-time.After(dispatcher.readinessWait):
+		return false
+	}
+}
+
+// RegisterActorType registers info. When the actor type is already registered, the registration is replaced
+// like with [Dispatcher.ReplaceActorType], except that RegisterActorType does not wait for the old container
+// to be stopped.
+func (dispatcher *Dispatcher) RegisterActorType(info ActorInfo) {
+	dispatcher.replace(info)
+}
+
+// ReplaceActorType registers info, replacing the current registration for the same actor type (if any).
+// The old container is stopped first. Meanwhile, new calls for the actor type are held (for at most
+// [REPLACE_TIMEOUT]), and so are the calls that the old container refuses while stopping. When the old
+// container is stopped, the held calls are dispatched to the new container. This way, an actor instance is
+// never active in both containers at the same time.
+// Blocks until the old container is stopped.
+func (dispatcher *Dispatcher) ReplaceActorType(info ActorInfo) {
+	<-dispatcher.replace(info)
+}
+
+// replace registers info and stops the old container (if any) in the background. Returns a channel that
+// is closed when the old container is stopped.
+func (dispatcher *Dispatcher) replace(info ActorInfo) <-chan struct{} {
+	done := make(chan struct{})
+
+	dispatcher.typesLock.Lock()
+	old := dispatcher.actorTypes[info.ActorType]
+	// Wait for a previous replacement of the same actor type, so that at most one old container is stopping
+	previous := dispatcher.replacing[info.ActorType]
+	dispatcher.actorTypes[info.ActorType] = &info
+	if old != nil {
+		dispatcher.replacing[info.ActorType] = done
+	}
+	dispatcher.typesLock.Unlock()
+
+	if !info.Private || (old != nil && !old.Private) {
+		dispatcher.TriggerBroadcast()
+	}
+	if old == nil {
+		close(done)
+		return done
+	}
+
+	go func() {
+		if previous != nil {
+			<-previous
+		}
+		stopContainer(old.Container)
+		dispatcher.typesLock.Lock()
+		if dispatcher.replacing[info.ActorType] == done {
+			delete(dispatcher.replacing, info.ActorType)
+		}
+		dispatcher.typesLock.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// UnregisterActorType removes the registration for actorType and stops its container. Calls that are already
+// being processed are allowed to finish; new calls are refused so that they are retried on other applications.
+// Returns false when the actor type was not registered. Blocks until the container is stopped.
+func (dispatcher *Dispatcher) UnregisterActorType(actorType normalized.ActorType) bool {
+	dispatcher.typesLock.Lock()
+	old := dispatcher.actorTypes[actorType]
+	delete(dispatcher.actorTypes, actorType)
+	dispatcher.typesLock.Unlock()
+
+	if old == nil {
+		return false
+	}
+	if !old.Private {
+		dispatcher.TriggerBroadcast()
+	}
+	stopContainer(old.Container)
+	return true
+}
+
+// SetMigrationVersion changes the migration version that is advertised for actorType. Other applications use the
+// migration version to route calls to the applications with the most recent (or a compatible) version.
+// Returns false when the actor type is not registered.
+func (dispatcher *Dispatcher) SetMigrationVersion(actorType normalized.ActorType, migrationVersion string) bool {
+	dispatcher.typesLock.Lock()
+	info := dispatcher.actorTypes[actorType]
+	if info != nil {
+		info.MigrationVersion = migrationVersion
+	}
+	dispatcher.typesLock.Unlock()
+
+	if info == nil {
+		return false
+	}
+	if !info.Private {
+		dispatcher.TriggerBroadcast()
+	}
+	return true
+}
+
+func (dispatcher *Dispatcher) TriggerBroadcast() {
 	info := make(map[string]actorregistry.ActorPushInfo)
 
+	dispatcher.typesLock.RLock()
 	for key, value := range dispatcher.actorTypes {
+		if value.Private {
+			continue
+		}
 		info[string(key)] = actorregistry.ActorPushInfo{
 			Placement:        value.Placement,
 			MigrationVersion: value.MigrationVersion,
 		}
 	}
+	dispatcher.typesLock.RUnlock()
+
 	if dispatcher.registryPusher != nil {
 		dispatcher.registryPusher.Set(info)
 	}
@@ -87,6 +394,7 @@ func (dispatcher Dispatcher) TriggerBroadcast() {
 func NewDispatcher(registryPusher actorregistry.ActorRegistryPusher) *Dispatcher {
 	return &Dispatcher{
 		registryPusher: registryPusher,
-		actorTypes:     make(map[normalized.ActorType]ActorInfo),
+		actorTypes:     make(map[normalized.ActorType]*ActorInfo),
+		replacing:      make(map[normalized.ActorType]chan struct{}),
 	}
 }
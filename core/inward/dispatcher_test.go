@@ -0,0 +1,175 @@
+package inward
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	. "github.com/darlean-io/darlean.go/utils/variant"
+)
+
+func TestDispatcher_Drain(t *testing.T) {
+	wrapperFactory := func(id []string) InstanceWrapper {
+		return &TestActorWrapper{}
+	}
+	container := NewStandardActorContainer(normalized.NormalizeActorType("TestActor"), false, GetTestActionDefs(), wrapperFactory, nil)
+	dispatcher := NewDispatcher(nil)
+	dispatcher.RegisterActorType(ActorInfo{
+		ActorType: normalized.NormalizeActorType("TestActor"),
+		Container: container,
+	})
+
+	var mutex sync.Mutex
+	var results []string
+	handleResult := func(response *wire.ActorCallResponseOut) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if response.Error != nil {
+			results = append(results, fmt.Sprintf("ERR:%v", response.Error.(*actionerror.Error).Code))
+		} else {
+			results = append(results, fmt.Sprintf("%v", response.Value))
+		}
+	}
+
+	go dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("Hello")}}, handleResult)
+	time.Sleep(SLEEP_BASIS_HALF)
+
+	dispatcher.StartDraining()
+	dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("Too-late")}}, handleResult)
+
+	remaining := dispatcher.WaitIdle(SLEEP_BASIS * 5)
+	checks.Equal(t, 0, remaining, "All in-flight calls should be finished")
+
+	dispatcher.StopContainers()
+
+	checks.Equal(t, []string{
+		"ERR:DRAINING",
+		"hello",
+	}, results, "Results should be as expected")
+}
+
+func TestDispatcher_ReplaceAndUnregister(t *testing.T) {
+	newContainer := func(name string) *StandardActorContainer {
+		return NewStandardActorContainer(normalized.NormalizeActorType("TestActor"), false, GetTestActionDefs(), func(id []string) InstanceWrapper {
+			return &TestActorWrapper{id: name}
+		}, nil)
+	}
+
+	dispatcher := NewDispatcher(nil)
+	dispatcher.RegisterActorType(ActorInfo{
+		ActorType: normalized.NormalizeActorType("TestActor"),
+		Container: newContainer("old"),
+	})
+
+	var mutex sync.Mutex
+	var results []string
+	handleResult := func(response *wire.ActorCallResponseOut) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if response.Error != nil {
+			results = append(results, fmt.Sprintf("ERR:%v", response.Error.(*actionerror.Error).Code))
+		} else {
+			results = append(results, fmt.Sprintf("%v", response.Value))
+		}
+	}
+
+	go dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("Hello")}}, handleResult)
+	// Wait until the instance is created and activated, and Hello is being performed
+	time.Sleep(SLEEP_BASIS * 5 / 2)
+
+	go dispatcher.ReplaceActorType(ActorInfo{
+		ActorType: normalized.NormalizeActorType("TestActor"),
+		Container: newContainer("new"),
+	})
+	time.Sleep(SLEEP_BASIS_TENTH)
+
+	dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("World")}}, handleResult)
+	time.Sleep(SLEEP_BASIS * 5)
+
+	checks.Equal(t, true, dispatcher.UnregisterActorType(normalized.NormalizeActorType("TestActor")), "Actor type should be unregistered")
+	dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("Too-late")}}, handleResult)
+
+	checks.Equal(t, []string{
+		"old:hello",
+		"new:world",
+		"ERR:ACTOR_TYPE_NOT_REGISTERED",
+	}, results, "Results should be as expected")
+}
+
+// eventWrapper records the activations and deactivations of the instances of multiple containers in one log.
+type eventWrapper struct {
+	TestActorWrapper
+	log *TestActorWrapper
+}
+
+func (wrapper *eventWrapper) Activate() *actionerror.Error {
+	wrapper.log.record(wrapper.id + ":Activate")
+	return wrapper.TestActorWrapper.Activate()
+}
+
+func (wrapper *eventWrapper) Deactivate() *actionerror.Error {
+	err := wrapper.TestActorWrapper.Deactivate()
+	wrapper.log.record(wrapper.id + ":Deactivated")
+	return err
+}
+
+func TestDispatcher_ReplaceHoldsCalls(t *testing.T) {
+	var log TestActorWrapper
+	newContainer := func(name string) *StandardActorContainer {
+		return NewStandardActorContainer(normalized.NormalizeActorType("TestActor"), false, GetTestActionDefs(), func(id []string) InstanceWrapper {
+			return &eventWrapper{TestActorWrapper: TestActorWrapper{id: name}, log: &log}
+		}, nil)
+	}
+
+	dispatcher := NewDispatcher(nil)
+	dispatcher.RegisterActorType(ActorInfo{
+		ActorType: normalized.NormalizeActorType("TestActor"),
+		Container: newContainer("old"),
+	})
+
+	var mutex sync.Mutex
+	var results []string
+	handleResult := func(response *wire.ActorCallResponseOut) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if response.Error != nil {
+			results = append(results, fmt.Sprintf("ERR:%v", response.Error.(*actionerror.Error).Code))
+		} else {
+			results = append(results, fmt.Sprintf("%v", response.Value))
+		}
+	}
+
+	go dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("Hello")}}, handleResult)
+	// Wait until the instance is created and activated, and Hello is being performed
+	time.Sleep(SLEEP_BASIS * 5 / 2)
+
+	// Does not wait for the old container to stop
+	dispatcher.RegisterActorType(ActorInfo{
+		ActorType: normalized.NormalizeActorType("TestActor"),
+		Container: newContainer("new"),
+	})
+
+	// Held until the instance in the old container is deactivated
+	dispatcher.Dispatch(&wire.ActorCallRequestIn{ActorType: "TestActor", ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString("World")}}, handleResult)
+	time.Sleep(SLEEP_BASIS * 5)
+	dispatcher.StopContainers()
+
+	checks.Equal(t, []string{
+		"old:Activate",
+		"old:Deactivated",
+		"new:Activate",
+		"new:Deactivated",
+	}, log.History(), "Instance should never be active in both containers")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	checks.Equal(t, []string{
+		"old:hello",
+		"new:world",
+	}, results, "Results should be as expected")
+}
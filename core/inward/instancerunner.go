@@ -3,12 +3,16 @@ package inward
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/darlean-io/darlean.go/base/actionerror"
 	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/invoke"
 	"github.com/darlean-io/darlean.go/core/normalized"
 	"github.com/darlean-io/darlean.go/core/wire"
 	"github.com/darlean-io/darlean.go/utils/variant"
+
+	"github.com/google/uuid"
 )
 
 type InstanceWrapper interface {
@@ -19,6 +23,13 @@ type InstanceWrapper interface {
 	Perform(actionName normalized.ActionName, args []variant.Assignable) (result any, err *actionerror.Error)
 }
 
+// ChainedInstanceWrapper is implemented by instance wrappers that want to know the call chain of the calls
+// they perform, so that they can make their own calls part of the same call chain (see [invoker.WithCallChain]).
+// When implemented, PerformInChain is invoked instead of Perform.
+type ChainedInstanceWrapper interface {
+	PerformInChain(actionName normalized.ActionName, args []variant.Assignable, callChain []string) (result any, err *actionerror.Error)
+}
+
 type ActionLockKind int
 
 const ACTION_LOCK_EXCLUSIVE = ActionLockKind(0)
@@ -30,9 +41,14 @@ type actionKind int
 const action_kind_action = actionKind(0)
 const action_kind_activate = actionKind(1)
 const action_kind_deactivate = actionKind(2)
+const action_kind_timer = actionKind(3)
 
 type ActionDef struct {
 	Locking ActionLockKind
+	// Reentrant allows the action to be invoked while an exclusive or shared action that (indirectly) made the
+	// call is in progress (like when actor A calls actor B, which calls back into A). The reentrant call then runs without
+	// taking a lock. When false, such calls fail immediately with a DEADLOCK error instead of waiting forever.
+	Reentrant bool
 }
 
 type DefaultInstanceRunner struct {
@@ -49,6 +65,16 @@ type DefaultInstanceRunner struct {
 	queueLock      sync.RWMutex
 	running        bool
 	onDeactivated  func()
+	timers         map[*Timer]bool
+	timersLock     sync.Mutex
+	timersStopped  bool
+	// Number of exclusive and shared calls in progress per call id (the last id of their call chain).
+	activeChains map[string]int
+	chainsLock   sync.Mutex
+	limits       QueueLimits
+	queueStats   queueStats
+	// Statistics of the container the runner belongs to, or nil.
+	containerStats *queueStats
 }
 
 const state_created = 0
@@ -65,6 +91,7 @@ type callRec struct {
 	kind       actionKind
 	onFinished FinishedHandler
 	def        *ActionDef
+	timer      *Timer
 }
 
 // Queue to which callRec's can be pushed. The queue maintains the number of in-progress items.
@@ -119,6 +146,7 @@ func (runner *DefaultInstanceRunner) releaseActorLock() error {
 
 const ERROR_DEACTIVATED = "DEACTIVATED"
 const ERROR_UNKNOWN_ACTION = "UNKNOWN_ACTION"
+const ERROR_DEADLOCK = "DEADLOCK"
 
 // Invokes a `call`. May block until the call is actually being processed.
 func (runner *DefaultInstanceRunner) Invoke(call *wire.ActorCallRequestIn, onFinished FinishedHandler) {
@@ -140,26 +168,126 @@ func (runner *DefaultInstanceRunner) Invoke(call *wire.ActorCallRequestIn, onFin
 		go runner.loop(onFinished, runner.finishedCalls)
 	})
 
+	// The incoming chain contains the ids of the calls that (indirectly) made this call. Only when one of
+	// them holds the lock, this call is a callback that would deadlock. Sibling calls of the same request
+	// do not share ids, so they wait for the lock as usual.
+	locking := actionDef.Locking
+	reentrant := locking != ACTION_LOCK_NONE && runner.isActiveChain(call.CallChain)
+
+	// Extend the chain with the id of this call, so that the calls made while processing this call can be
+	// recognized as its descendants.
+	chain := make([]string, len(call.CallChain), len(call.CallChain)+1)
+	copy(chain, call.CallChain)
+	call.CallChain = append(chain, uuid.NewString())
+
+	if reentrant {
+		if !actionDef.Reentrant {
+			onFinished(nil, frameworkerror.New(actionerror.Options{
+				Code:     ERROR_DEADLOCK,
+				Template: "Reentrant call to non-reentrant action [Action] on an instance of [ActorType] would deadlock",
+				Parameters: map[string]any{
+					"Action":    call.ActionName,
+					"ActorType": call.ActorType,
+					invoke.FRAMEWORK_ERROR_PARAMETER_NOT_RETRYABLE: true,
+				}}))
+			return
+		}
+		// The call is logically part of the call that holds the lock, so it must not wait for that lock.
+		locking = ACTION_LOCK_NONE
+	}
+
+	if err := runner.push(callRec{call: call, def: &actionDef, onFinished: onFinished}, locking); err != nil {
+		onFinished(nil, err)
+	}
+}
+
+// SetQueueLimits sets the limits for the calls that wait to be processed by this instance. Only
+// MaxInstanceQueue and QueueTimeout are used.
+func (runner *DefaultInstanceRunner) SetQueueLimits(limits QueueLimits) {
+	runner.limits = limits
+}
+
+// QueueMetrics returns statistics about the calls that wait to be processed by this instance.
+func (runner *DefaultInstanceRunner) QueueMetrics() QueueMetrics {
+	return runner.queueStats.metrics()
+}
+
+// Pushes call to the queue for the provided lock kind. May block until the call is actually being processed.
+// Returns a DEACTIVATED error when the runner is not running anymore, and a TOO_BUSY error when a queue
+// limit is reached or the call waited too long.
+func (runner *DefaultInstanceRunner) push(call callRec, locking ActionLockKind) *actionerror.Error {
 	runner.queueLock.RLock()
 	defer runner.queueLock.RUnlock()
 
 	if !runner.running {
-		onFinished(nil, frameworkerror.New(actionerror.Options{
+		return frameworkerror.New(actionerror.Options{
 			Code:     ERROR_DEACTIVATED,
 			Template: "Actor type [ActorType] is deactivated",
 			Parameters: map[string]any{
-				"ActorType": call.ActorType,
-			}}))
-		return
+				"ActorType": runner.actorType,
+			}})
+	}
+
+	if !runner.queueStats.enter(runner.limits.MaxInstanceQueue) {
+		runner.containerStats.count(false)
+		return newTooBusyError(runner.actorType, "instance queue is full")
 	}
+	defer runner.queueStats.leave()
+	if !runner.containerStats.enter(runner.limits.MaxContainerQueue) {
+		runner.queueStats.count(false)
+		return newTooBusyError(runner.actorType, "container queue is full")
+	}
+	defer runner.containerStats.leave()
 
-	switch actionDef.Locking {
+	var queue *callQueue
+	switch locking {
 	case ACTION_LOCK_EXCLUSIVE:
-		runner.exclusiveCalls.push(callRec{call: call, def: &actionDef, onFinished: onFinished})
+		queue = &runner.exclusiveCalls
 	case ACTION_LOCK_SHARED:
-		runner.sharedCalls.push(callRec{call: call, def: &actionDef, onFinished: onFinished})
-	case ACTION_LOCK_NONE:
-		runner.noneCalls.push(callRec{call: call, def: &actionDef, onFinished: onFinished})
+		queue = &runner.sharedCalls
+	default:
+		queue = &runner.noneCalls
+	}
+
+	if runner.limits.QueueTimeout <= 0 {
+		queue.push(call)
+		return nil
+	}
+
+	timer := time.NewTimer(runner.limits.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case queue.queue <- call:
+		return nil
+	case <-timer.C:
+		runner.queueStats.count(true)
+		runner.containerStats.count(true)
+		return newTooBusyError(runner.actorType, "call waited too long")
+	}
+}
+
+// isActiveChain returns whether an exclusive or shared call with one of the ids in callChain is in progress.
+func (runner *DefaultInstanceRunner) isActiveChain(callChain []string) bool {
+	runner.chainsLock.Lock()
+	defer runner.chainsLock.Unlock()
+	for _, id := range callChain {
+		if runner.activeChains[id] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enterChain registers (delta 1) or unregisters (delta -1) the exclusive or shared call with id as in progress.
+func (runner *DefaultInstanceRunner) enterChain(id string, delta int) {
+	if id == "" {
+		return
+	}
+	runner.chainsLock.Lock()
+	defer runner.chainsLock.Unlock()
+	runner.activeChains[id] += delta
+	if runner.activeChains[id] <= 0 {
+		delete(runner.activeChains, id)
 	}
 }
 
@@ -215,9 +343,9 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 				case call := <-queue.queue:
 					err := frameworkerror.New(actionerror.Options{
 						Code:     ERROR_DEACTIVATED,
-						Template: "Actor type [call.ActorType] is deactivated",
+						Template: "Actor type [ActorType] is deactivated",
 						Parameters: map[string]any{
-							"ActorType": call.call.ActorType,
+							"ActorType": runner.actorType,
 						}})
 
 					call.onFinished(nil, err)
@@ -231,6 +359,11 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 	// Invoke one specific call and update the administration for the queue accordingly
 	invoke := func(call callRec, queue *callQueue) {
 		queue.do()
+		var callId string
+		if call.kind == action_kind_action && queue != &runner.noneCalls {
+			callId = call.call.CallChain[len(call.call.CallChain)-1]
+			runner.enterChain(callId, 1)
+		}
 		go func() {
 			// Note: This code runs parallel to our loop in a goroutine. It should not modify the state
 			// of the runner to avoid race conditions/corruption. The only allowed communication with
@@ -239,6 +372,7 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 			var err *actionerror.Error
 
 			defer func() {
+				runner.enterChain(callId, -1)
 				if r := recover(); r != nil {
 					err = actionerror.New(actionerror.Options{
 						Code:     "UNEXPECTED_APPLICATION_ERROR",
@@ -262,8 +396,14 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 				err = runner.wrapper.Activate()
 			case action_kind_deactivate:
 				err = runner.wrapper.Deactivate()
+			case action_kind_timer:
+				err = call.timer.handler()
 			default:
-				result, err = runner.wrapper.Perform(normalized.NormalizeActionName(call.call.ActionName), call.call.Arguments)
+				if chained, ok := runner.wrapper.(ChainedInstanceWrapper); ok {
+					result, err = chained.PerformInChain(normalized.NormalizeActionName(call.call.ActionName), call.call.Arguments, call.call.CallChain)
+				} else {
+					result, err = runner.wrapper.Perform(normalized.NormalizeActionName(call.call.ActionName), call.call.Arguments)
+				}
 			}
 		}()
 	}
@@ -281,6 +421,7 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 	}
 
 	defer runner.releaseActorLock()
+	defer runner.stopTimers()
 
 	defer func() {
 		// Warning: a read-only queuelock may be held by Invoke. The Invoke waits for us
@@ -322,6 +463,7 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 			if finished == nil {
 				if state < state_deactivation_wanted {
 					state = state_deactivation_wanted
+					runner.stopTimers()
 				}
 				continue
 			}
@@ -329,9 +471,13 @@ func (runner *DefaultInstanceRunner) loop(activationErrorHandler FinishedHandler
 			finished.finishedQueue.done()
 			if finished.finishedActionKind == action_kind_activate {
 				if finished.err == nil {
-					state = state_active
+					// Deactivation may have been requested while activating
+					if state == state_activating {
+						state = state_active
+					}
 				} else {
 					state = state_deactivation_wanted
+					runner.stopTimers()
 					finished.finishedHandler(nil, finished.err)
 				}
 				// Do not invoke finishedHandler; already done for error, but should not
@@ -374,6 +520,11 @@ func NewInstanceRunner(wrapper InstanceWrapper, actorType normalized.ActorType,
 		noneCalls:      newCallQueue(),
 		finishedCalls:  make(chan *callFinishedRec),
 		onDeactivated:  onDeactivated,
+		timers:         make(map[*Timer]bool),
+		activeChains:   make(map[string]int),
+	}
+	if aware, ok := wrapper.(TimerAware); ok {
+		aware.SetTimers(&runner)
 	}
 	return &runner
 }
@@ -2,6 +2,7 @@ package inward
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -45,7 +46,7 @@ func TestInstanceRunner_Exclusive(t *testing.T) {
 		"Performed {exclusive} with {World}",
 		"Deactivate",
 		"Deactivated",
-	}, wrapper.history, "Events should be as expected")
+	}, wrapper.History(), "Events should be as expected")
 
 	checks.Equal(t, []string{
 		"hello",
@@ -98,7 +99,7 @@ func TestInstanceRunner_Shared(t *testing.T) {
 		"Performed {shared} with {World2}",
 		"Deactivate",
 		"Deactivated",
-	}, wrapper.history, "Events should be as expected")
+	}, wrapper.History(), "Events should be as expected")
 
 	checks.Equal(t, []string{
 		"hello",
@@ -169,7 +170,7 @@ func TestInstanceRunner_None(t *testing.T) {
 		"Deactivated",
 		"Performed {none} with {During-deactivate}",
 	}}
-	checks.EqualOneOf(t, truth, wrapper.history, "Events should be as expected")
+	checks.EqualOneOf(t, truth, wrapper.History(), "Events should be as expected")
 
 	checks.Equal(t, []string{
 		"hello",
@@ -179,3 +180,94 @@ func TestInstanceRunner_None(t *testing.T) {
 		"ERR:DEACTIVATED",
 	}, results, "Results should be as expected")
 }
+
+func TestInstanceRunner_Reentrancy(t *testing.T) {
+	runner, wrapper := newRunner()
+	var results []string
+	var mutex sync.Mutex
+
+	handleResult := func(result any, err *actionerror.Error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			results = append(results, fmt.Sprintf("ERR:%v", err.Code))
+		} else {
+			results = append(results, fmt.Sprintf("%v", result))
+		}
+	}
+
+	outer := &wire.ActorCallRequestIn{ActionName: "Exclusive", Arguments: []Assignable{FromString("Outer")}, CallChain: []string{"request"}}
+	runner.Invoke(outer, handleResult)
+	// Invoke extends the call chain with the id of the outer call; calls made by the outer call carry that chain.
+	chain := outer.CallChain
+	checks.Equal(t, 2, len(chain), "Call chain extended with id of the call")
+	// Invoke returns when the outer call is picked up after activation; wait until it is being performed
+	time.Sleep(SLEEP_BASIS_HALF)
+	runner.Invoke(&wire.ActorCallRequestIn{ActionName: "Exclusive", Arguments: []Assignable{FromString("Callback")}, CallChain: chain}, handleResult)
+	runner.Invoke(&wire.ActorCallRequestIn{ActionName: "Reentrant", Arguments: []Assignable{FromString("Reentrant")}, CallChain: chain}, handleResult)
+
+	time.Sleep(SLEEP_BASIS * 2)
+	runner.TriggerDeactivate()
+	time.Sleep(SLEEP_BASIS * 2)
+
+	checks.Equal(t, []string{
+		"Activate",
+		"Activated",
+		"Perform {exclusive} with {Outer}",
+		"Perform {reentrant} with {Reentrant}",
+		"Performed {exclusive} with {Outer}",
+		"Performed {reentrant} with {Reentrant}",
+		"Deactivate",
+		"Deactivated",
+	}, wrapper.History(), "Reentrant call should run while the outer call is in progress")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	checks.Equal(t, []string{
+		"ERR:DEADLOCK",
+		"outer",
+		"reentrant",
+	}, results, "Non-reentrant callback should fail fast")
+}
+
+func TestInstanceRunner_SiblingCalls(t *testing.T) {
+	runner, wrapper := newRunner()
+	var results []string
+	var mutex sync.Mutex
+
+	handleResult := func(result any, err *actionerror.Error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			results = append(results, fmt.Sprintf("ERR:%v", err.Code))
+		} else {
+			results = append(results, fmt.Sprintf("%v", result))
+		}
+	}
+
+	// Two calls that are made in parallel while processing the same request share the chain of that
+	// request. They are not reentrant, so the second one waits for the first one.
+	chain := []string{"request"}
+	runner.Invoke(&wire.ActorCallRequestIn{ActionName: "Exclusive", Arguments: []Assignable{FromString("First")}, CallChain: chain}, handleResult)
+	time.Sleep(SLEEP_BASIS_HALF)
+	runner.Invoke(&wire.ActorCallRequestIn{ActionName: "Exclusive", Arguments: []Assignable{FromString("Second")}, CallChain: chain}, handleResult)
+
+	time.Sleep(SLEEP_BASIS * 2)
+	runner.TriggerDeactivate()
+	time.Sleep(SLEEP_BASIS * 2)
+
+	checks.Equal(t, []string{
+		"Activate",
+		"Activated",
+		"Perform {exclusive} with {First}",
+		"Performed {exclusive} with {First}",
+		"Perform {exclusive} with {Second}",
+		"Performed {exclusive} with {Second}",
+		"Deactivate",
+		"Deactivated",
+	}, wrapper.History(), "Sibling calls should wait for each other")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	checks.Equal(t, []string{"first", "second"}, results, "Sibling calls should not deadlock")
+}
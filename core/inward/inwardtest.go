@@ -3,6 +3,7 @@ package inward
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/darlean-io/darlean.go/base/actionerror"
@@ -12,9 +13,23 @@ import (
 
 type TestActorWrapper struct {
 	history []string
+	mutex   sync.Mutex
 	id      string
 }
 
+func (wrapper *TestActorWrapper) record(event string) {
+	wrapper.mutex.Lock()
+	defer wrapper.mutex.Unlock()
+	wrapper.history = append(wrapper.history, event)
+}
+
+// History returns a copy of the events that happened so far.
+func (wrapper *TestActorWrapper) History() []string {
+	wrapper.mutex.Lock()
+	defer wrapper.mutex.Unlock()
+	return append([]string{}, wrapper.history...)
+}
+
 const SLEEP_BASIS_TENTH = time.Millisecond * 10
 
 const SLEEP_BASIS_HALF = SLEEP_BASIS_TENTH * 5
@@ -22,41 +37,41 @@ const SLEEP_BASIS_SHORT = SLEEP_BASIS_TENTH * 8
 const SLEEP_BASIS = SLEEP_BASIS_TENTH * 10
 
 func (wrapper *TestActorWrapper) Create() *actionerror.Error {
-	wrapper.history = append(wrapper.history, "Create")
+	wrapper.record("Create")
 	time.Sleep(SLEEP_BASIS)
-	wrapper.history = append(wrapper.history, "Created")
+	wrapper.record("Created")
 	return nil
 }
 
 func (wrapper *TestActorWrapper) Activate() *actionerror.Error {
-	wrapper.history = append(wrapper.history, "Activate")
+	wrapper.record("Activate")
 	time.Sleep(SLEEP_BASIS)
-	wrapper.history = append(wrapper.history, "Activated")
+	wrapper.record("Activated")
 	return nil
 }
 
 func (wrapper *TestActorWrapper) Deactivate() *actionerror.Error {
-	wrapper.history = append(wrapper.history, "Deactivate")
+	wrapper.record("Deactivate")
 	time.Sleep(SLEEP_BASIS)
-	wrapper.history = append(wrapper.history, "Deactivated")
+	wrapper.record("Deactivated")
 	return nil
 }
 
 func (wrapper *TestActorWrapper) Release() *actionerror.Error {
-	wrapper.history = append(wrapper.history, "Release")
+	wrapper.record("Release")
 	time.Sleep(SLEEP_BASIS)
-	wrapper.history = append(wrapper.history, "Released")
+	wrapper.record("Released")
 	return nil
 }
 
 func (wrapper *TestActorWrapper) Perform(actionName normalized.ActionName, args []variant.Assignable) (result any, err *actionerror.Error) {
-	wrapper.history = append(wrapper.history, fmt.Sprintf("Perform {%v} with {%v}", string(actionName), args[0]))
+	wrapper.record(fmt.Sprintf("Perform {%v} with {%v}", string(actionName), args[0]))
 	if strings.Contains(string(actionName), "faster") {
 		time.Sleep(SLEEP_BASIS_SHORT)
 	} else {
 		time.Sleep(SLEEP_BASIS)
 	}
-	wrapper.history = append(wrapper.history, fmt.Sprintf("Performed {%v} with {%v}", string(actionName), args[0]))
+	wrapper.record(fmt.Sprintf("Performed {%v} with {%v}", string(actionName), args[0]))
 	arg0, err0 := args[0].AssignToString()
 	resultstring := strings.ToLower(arg0)
 	if wrapper.id != "" {
@@ -71,6 +86,7 @@ func GetTestActionDefs() map[normalized.ActionName]ActionDef {
 		"shared":     {Locking: ACTION_LOCK_SHARED},
 		"none":       {Locking: ACTION_LOCK_NONE},
 		"nonefaster": {Locking: ACTION_LOCK_NONE},
+		"reentrant":  {Locking: ACTION_LOCK_EXCLUSIVE, Reentrant: true},
 	}
 }
 
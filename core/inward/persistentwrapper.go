@@ -0,0 +1,123 @@
+package inward
+
+import (
+	"errors"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/persistence"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+// PersistentInstance is an [InstanceWrapper] that has state that must be persisted.
+type PersistentInstance interface {
+	InstanceWrapper
+	// State returns a pointer to the state of the instance. The state is loaded into it before
+	// the instance is activated, and stored after the instance is deactivated.
+	State() any
+}
+
+const ERROR_STATE_LOAD_FAILED = "STATE_LOAD_FAILED"
+const ERROR_STATE_STORE_FAILED = "STATE_STORE_FAILED"
+const ERROR_STATE_VERSION_CONFLICT = "STATE_VERSION_CONFLICT"
+
+// PersistentWrapper wraps a [PersistentInstance] and automatically loads its state on activation
+// and stores it on deactivation. Satisfies [InstanceWrapper].
+type PersistentWrapper struct {
+	PersistentInstance
+	store   persistence.Store
+	key     persistence.Key
+	version string
+}
+
+// NewPersistentWrapper returns a wrapper around instance that uses store to persist the state
+// of the instance for the provided actor type and id.
+func NewPersistentWrapper(instance PersistentInstance, store persistence.Store, actorType normalized.ActorType, actorId []string) *PersistentWrapper {
+	return &PersistentWrapper{
+		PersistentInstance: instance,
+		store:              store,
+		key: persistence.Key{
+			ActorType: string(actorType),
+			ActorId:   actorId,
+		},
+		version: persistence.VERSION_NONE,
+	}
+}
+
+// PersistentWrapperFactory returns a [WrapperFactory] that wraps the instances created by factory in
+// a [PersistentWrapper].
+func PersistentWrapperFactory(actorType normalized.ActorType, store persistence.Store, factory func(id []string) PersistentInstance) WrapperFactory {
+	return func(id []string) InstanceWrapper {
+		return NewPersistentWrapper(factory(id), store, actorType, id)
+	}
+}
+
+// SetTimers passes timers on to the wrapped instance when it is [TimerAware].
+func (wrapper *PersistentWrapper) SetTimers(timers Timers) {
+	if aware, ok := wrapper.PersistentInstance.(TimerAware); ok {
+		aware.SetTimers(timers)
+	}
+}
+
+// PerformInChain passes the call chain on to the wrapped instance when it is a [ChainedInstanceWrapper].
+func (wrapper *PersistentWrapper) PerformInChain(actionName normalized.ActionName, args []variant.Assignable, callChain []string) (any, *actionerror.Error) {
+	if chained, ok := wrapper.PersistentInstance.(ChainedInstanceWrapper); ok {
+		return chained.PerformInChain(actionName, args, callChain)
+	}
+	return wrapper.PersistentInstance.Perform(actionName, args)
+}
+
+func (wrapper *PersistentWrapper) Activate() *actionerror.Error {
+	item, err := wrapper.store.Load(wrapper.key)
+	if err != nil {
+		return wrapper.newError(ERROR_STATE_LOAD_FAILED, "Unable to load state for an instance of [ActorType]: [Reason]", err)
+	}
+	if item != nil {
+		err = jsonbinary.Deserialize(item.Value, wrapper.State())
+		if err != nil {
+			return wrapper.newError(ERROR_STATE_LOAD_FAILED, "Unable to load state for an instance of [ActorType]: [Reason]", err)
+		}
+		wrapper.version = item.Version
+	}
+	return wrapper.PersistentInstance.Activate()
+}
+
+func (wrapper *PersistentWrapper) Deactivate() *actionerror.Error {
+	e := wrapper.PersistentInstance.Deactivate()
+	if e != nil {
+		return e
+	}
+	return wrapper.Save()
+}
+
+// Save stores the current state of the instance. Is invoked automatically after deactivation, but can
+// also be invoked explicitly to persist the state earlier. Returns a STATE_VERSION_CONFLICT error when
+// the state was modified by someone else since it was loaded or last saved.
+func (wrapper *PersistentWrapper) Save() *actionerror.Error {
+	data, err := jsonbinary.Serialize(wrapper.State(), nil)
+	if err != nil {
+		return wrapper.newError(ERROR_STATE_STORE_FAILED, "Unable to store state for an instance of [ActorType]: [Reason]", err)
+	}
+	version, err := wrapper.store.Store(wrapper.key, data, wrapper.version)
+	if errors.Is(err, persistence.ErrVersionConflict) {
+		return wrapper.newError(ERROR_STATE_VERSION_CONFLICT, "State for an instance of [ActorType] was modified concurrently", err)
+	}
+	if err != nil {
+		return wrapper.newError(ERROR_STATE_STORE_FAILED, "Unable to store state for an instance of [ActorType]: [Reason]", err)
+	}
+	wrapper.version = version
+	return nil
+}
+
+func (wrapper *PersistentWrapper) newError(code string, template string, reason error) *actionerror.Error {
+	return frameworkerror.New(actionerror.Options{
+		Code:     code,
+		Template: template,
+		Parameters: map[string]any{
+			"ActorType": wrapper.key.ActorType,
+			"Reason":    reason.Error(),
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package inward
+
+import (
+	"testing"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/persistence"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+type counterState struct {
+	Count int
+}
+
+type counterActor struct {
+	state counterState
+}
+
+func (actor *counterActor) Create() *actionerror.Error     { return nil }
+func (actor *counterActor) Activate() *actionerror.Error   { return nil }
+func (actor *counterActor) Deactivate() *actionerror.Error { return nil }
+func (actor *counterActor) Release() *actionerror.Error    { return nil }
+func (actor *counterActor) State() any                     { return &actor.state }
+
+func (actor *counterActor) Perform(actionName normalized.ActionName, args []variant.Assignable) (any, *actionerror.Error) {
+	actor.state.Count++
+	return actor.state.Count, nil
+}
+
+// mapStore is a minimal [persistence.Store] so that this test does not depend on the local persistence package.
+type mapStore map[string]persistence.Item
+
+func (store mapStore) Load(key persistence.Key) (*persistence.Item, error) {
+	item, has := store[key.ActorType+"/"+key.ActorId[0]]
+	if !has {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func (store mapStore) Store(key persistence.Key, value []byte, expectedVersion string) (string, error) {
+	k := key.ActorType + "/" + key.ActorId[0]
+	if store[k].Version != expectedVersion {
+		return "", persistence.ErrVersionConflict
+	}
+	version := expectedVersion + "+"
+	store[k] = persistence.Item{Value: value, Version: version}
+	return version, nil
+}
+
+func (store mapStore) Delete(key persistence.Key, expectedVersion string) error {
+	delete(store, key.ActorType+"/"+key.ActorId[0])
+	return nil
+}
+
+func TestPersistentWrapper(t *testing.T) {
+	store := mapStore{}
+	actorType := normalized.NormalizeActorType("Counter")
+
+	for i := 1; i <= 2; i++ {
+		wrapper := NewPersistentWrapper(&counterActor{}, store, actorType, []string{"a"})
+		checks.Equal(t, true, wrapper.Activate() == nil, "Activate should succeed")
+		result, _ := wrapper.Perform("increment", nil)
+		checks.Equal(t, i, result, "Count should continue from the stored state")
+		checks.Equal(t, true, wrapper.Deactivate() == nil, "Deactivate should succeed")
+	}
+
+	// Two instances for the same actor: the second one to save must detect the conflict
+	first := NewPersistentWrapper(&counterActor{}, store, actorType, []string{"a"})
+	second := NewPersistentWrapper(&counterActor{}, store, actorType, []string{"a"})
+	first.Activate()
+	second.Activate()
+	checks.Equal(t, true, first.Deactivate() == nil, "First deactivate should succeed")
+	checks.Equal(t, ERROR_STATE_VERSION_CONFLICT, second.Deactivate().Code, "Second deactivate should conflict")
+}
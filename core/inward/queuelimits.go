@@ -0,0 +1,90 @@
+package inward
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/invoke"
+	"github.com/darlean-io/darlean.go/core/normalized"
+)
+
+const ERROR_TOO_BUSY = "TOO_BUSY"
+
+// QueueLimits limits the number of calls that wait to be processed. A zero value means no limit.
+type QueueLimits struct {
+	// Maximum number of calls that wait for one instance.
+	MaxInstanceQueue int
+	// Maximum number of calls that wait for all instances of a container together.
+	MaxContainerQueue int
+	// Maximum time a call waits before it is rejected.
+	QueueTimeout time.Duration
+}
+
+// QueueMetrics contains statistics about waiting calls.
+type QueueMetrics struct {
+	// Number of calls that currently wait to be processed.
+	Waiting int
+	// Number of calls that were rejected because a queue limit was reached.
+	Rejected uint64
+	// Number of calls that were rejected because they waited longer than the queue timeout.
+	TimedOut uint64
+}
+
+type queueStats struct {
+	waiting  atomic.Int64
+	rejected atomic.Uint64
+	timedOut atomic.Uint64
+}
+
+// enter registers a waiting call. Returns false (and does not register the call) when that would exceed limit.
+func (stats *queueStats) enter(limit int) bool {
+	if stats == nil {
+		return true
+	}
+	if waiting := stats.waiting.Add(1); limit > 0 && waiting > int64(limit) {
+		stats.waiting.Add(-1)
+		stats.rejected.Add(1)
+		return false
+	}
+	return true
+}
+
+// count registers a rejected (or timed out) call that was not registered via enter.
+func (stats *queueStats) count(timedOut bool) {
+	if stats == nil {
+		return
+	}
+	if timedOut {
+		stats.timedOut.Add(1)
+	} else {
+		stats.rejected.Add(1)
+	}
+}
+
+func (stats *queueStats) leave() {
+	if stats != nil {
+		stats.waiting.Add(-1)
+	}
+}
+
+func (stats *queueStats) metrics() QueueMetrics {
+	return QueueMetrics{
+		Waiting:  int(stats.waiting.Load()),
+		Rejected: stats.rejected.Load(),
+		TimedOut: stats.timedOut.Load(),
+	}
+}
+
+func newTooBusyError(actorType normalized.ActorType, reason string) *actionerror.Error {
+	return frameworkerror.New(actionerror.Options{
+		Code:     ERROR_TOO_BUSY,
+		Template: "An instance of [ActorType] is too busy to process the call: [Reason]",
+		Parameters: map[string]any{
+			"ActorType":                              actorType,
+			"Reason":                                 reason,
+			invoke.FRAMEWORK_ERROR_PARAMETER_REFUSED: true,
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package inward
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	. "github.com/darlean-io/darlean.go/utils/variant"
+)
+
+// dispatchConcurrently dispatches one exclusive call per argument in parallel, and returns the
+// sorted results when all calls are finished.
+func dispatchConcurrently(container *StandardActorContainer, args ...string) []string {
+	var results []string
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, arg := range args {
+		wg.Add(1)
+		go container.Dispatch(&wire.ActorCallRequestIn{ActorId: []string{"123"}, ActionName: "Exclusive", Arguments: []Assignable{FromString(arg)}}, func(result any, err *actionerror.Error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				results = append(results, "ERR:"+err.Code)
+			} else {
+				results = append(results, "ok")
+			}
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	sort.Strings(results)
+	return results
+}
+
+func newLimitedContainer(limits QueueLimits) *StandardActorContainer {
+	container := NewStandardActorContainer(normalized.NormalizeActorType("TestActor"), false, GetTestActionDefs(), func(id []string) InstanceWrapper {
+		return &TestActorWrapper{}
+	}, nil)
+	container.SetQueueLimits(limits)
+	return container
+}
+
+func TestActorContainer_QueueLimit(t *testing.T) {
+	container := newLimitedContainer(QueueLimits{MaxInstanceQueue: 1})
+	results := dispatchConcurrently(container, "a", "b", "c", "d")
+	checks.Equal(t, []string{"ERR:TOO_BUSY", "ERR:TOO_BUSY", "ERR:TOO_BUSY", "ok"}, results, "Calls beyond the queue limit should be rejected")
+
+	metrics := container.QueueMetrics()
+	checks.Equal(t, 0, metrics.Waiting, "No calls should be waiting anymore")
+	checks.Equal(t, uint64(3), metrics.Rejected, "Rejected calls")
+	container.Stop()
+}
+
+func TestActorContainer_QueueTimeout(t *testing.T) {
+	container := newLimitedContainer(QueueLimits{QueueTimeout: SLEEP_BASIS + SLEEP_BASIS_HALF})
+	results := dispatchConcurrently(container, "a", "b")
+	checks.Equal(t, []string{"ERR:TOO_BUSY", "ok"}, results, "Call that waits too long should be rejected")
+	checks.Equal(t, uint64(1), container.QueueMetrics().TimedOut, "Timed out calls")
+	container.Stop()
+}
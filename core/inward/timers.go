@@ -0,0 +1,127 @@
+package inward
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+)
+
+// TimerHandler is invoked when a timer fires.
+type TimerHandler func() *actionerror.Error
+
+// Timers allows an actor instance to create volatile timers. Timers only live as long as the instance is
+// active: they are not persisted and are automatically cancelled when the instance deactivates. Use the
+// reminders package for timers that must survive deactivation.
+type Timers interface {
+	// NewTimer starts a timer that invokes handler after dueTime, and then every interval. A zero interval
+	// makes the timer fire only once. The handler is scheduled via the queues of the instance runner with the
+	// provided lock kind, so it never runs in parallel with calls that conflict with it.
+	NewTimer(handler TimerHandler, locking ActionLockKind, dueTime time.Duration, interval time.Duration) *Timer
+}
+
+// TimerAware is implemented by instance wrappers that want to use timers. The instance runner
+// invokes SetTimers when it is created.
+type TimerAware interface {
+	SetTimers(timers Timers)
+}
+
+// Timer is a volatile timer of an actor instance.
+type Timer struct {
+	runner    *DefaultInstanceRunner
+	handler   TimerHandler
+	locking   ActionLockKind
+	interval  time.Duration
+	timer     *time.Timer
+	mutex     sync.Mutex
+	pending   bool
+	cancelled bool
+}
+
+// Cancel stops the timer. A handler invocation that is already in progress or queued is not aborted.
+func (timer *Timer) Cancel() {
+	timer.mutex.Lock()
+	timer.cancelled = true
+	if timer.timer != nil {
+		timer.timer.Stop()
+	}
+	timer.mutex.Unlock()
+
+	timer.runner.timersLock.Lock()
+	delete(timer.runner.timers, timer)
+	timer.runner.timersLock.Unlock()
+}
+
+func (timer *Timer) fire() {
+	timer.mutex.Lock()
+	if timer.cancelled {
+		timer.mutex.Unlock()
+		return
+	}
+	if timer.interval > 0 {
+		timer.timer.Reset(timer.interval)
+	}
+	if timer.pending {
+		// The previous firing is not handled yet; skip this one instead of piling up invocations.
+		timer.mutex.Unlock()
+		return
+	}
+	timer.pending = true
+	timer.mutex.Unlock()
+
+	onFinished := func(result any, err *actionerror.Error) {
+		timer.mutex.Lock()
+		timer.pending = false
+		timer.mutex.Unlock()
+		if err != nil && err.Code != ERROR_DEACTIVATED {
+			fmt.Printf("instancerunner: timer for %s failed: %v\n", timer.runner.actorType, err.Message)
+		}
+	}
+
+	if err := timer.runner.push(callRec{kind: action_kind_timer, timer: timer, onFinished: onFinished}, timer.locking); err != nil {
+		timer.mutex.Lock()
+		timer.pending = false
+		timer.mutex.Unlock()
+		if err.Code == ERROR_DEACTIVATED {
+			timer.Cancel()
+		}
+	}
+}
+
+func (runner *DefaultInstanceRunner) NewTimer(handler TimerHandler, locking ActionLockKind, dueTime time.Duration, interval time.Duration) *Timer {
+	timer := &Timer{
+		runner:   runner,
+		handler:  handler,
+		locking:  locking,
+		interval: interval,
+	}
+
+	runner.timersLock.Lock()
+	defer runner.timersLock.Unlock()
+	if runner.timersStopped {
+		timer.cancelled = true
+		return timer
+	}
+	runner.timers[timer] = true
+
+	timer.mutex.Lock()
+	timer.timer = time.AfterFunc(dueTime, timer.fire)
+	timer.mutex.Unlock()
+	return timer
+}
+
+// stopTimers cancels all timers and prevents new timers from being started.
+func (runner *DefaultInstanceRunner) stopTimers() {
+	runner.timersLock.Lock()
+	runner.timersStopped = true
+	timers := make([]*Timer, 0, len(runner.timers))
+	for timer := range runner.timers {
+		timers = append(timers, timer)
+	}
+	runner.timersLock.Unlock()
+
+	for _, timer := range timers {
+		timer.Cancel()
+	}
+}
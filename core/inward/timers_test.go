@@ -0,0 +1,97 @@
+package inward
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+type timerActorWrapper struct {
+	timers   Timers
+	mutex    sync.Mutex
+	busy     bool
+	overlaps int
+	fired    int
+}
+
+func (wrapper *timerActorWrapper) SetTimers(timers Timers) {
+	wrapper.timers = timers
+}
+
+func (wrapper *timerActorWrapper) Create() *actionerror.Error  { return nil }
+func (wrapper *timerActorWrapper) Release() *actionerror.Error { return nil }
+
+func (wrapper *timerActorWrapper) Activate() *actionerror.Error {
+	wrapper.timers.NewTimer(func() *actionerror.Error {
+		wrapper.enter()
+		wrapper.mutex.Lock()
+		wrapper.fired++
+		wrapper.mutex.Unlock()
+		wrapper.leave()
+		return nil
+	}, ACTION_LOCK_EXCLUSIVE, 0, SLEEP_BASIS_TENTH)
+	return nil
+}
+
+func (wrapper *timerActorWrapper) Deactivate() *actionerror.Error {
+	return nil
+}
+
+func (wrapper *timerActorWrapper) Perform(actionName normalized.ActionName, args []variant.Assignable) (result any, err *actionerror.Error) {
+	wrapper.enter()
+	time.Sleep(SLEEP_BASIS)
+	wrapper.leave()
+	return nil, nil
+}
+
+func (wrapper *timerActorWrapper) enter() {
+	wrapper.mutex.Lock()
+	defer wrapper.mutex.Unlock()
+	if wrapper.busy {
+		wrapper.overlaps++
+	}
+	wrapper.busy = true
+}
+
+func (wrapper *timerActorWrapper) leave() {
+	wrapper.mutex.Lock()
+	defer wrapper.mutex.Unlock()
+	wrapper.busy = false
+}
+
+func (wrapper *timerActorWrapper) firedCount() int {
+	wrapper.mutex.Lock()
+	defer wrapper.mutex.Unlock()
+	return wrapper.fired
+}
+
+func TestInstanceRunner_Timers(t *testing.T) {
+	wrapper := &timerActorWrapper{}
+	runner := NewInstanceRunner(wrapper, normalized.NormalizeActorType("TimerActor"), []string{"123"}, false, GetTestActionDefs(), nil)
+
+	done := make(chan bool, 3)
+	for i := 0; i < 3; i++ {
+		runner.Invoke(&wire.ActorCallRequestIn{ActionName: "Exclusive"}, func(result any, err *actionerror.Error) {
+			done <- err == nil
+		})
+	}
+	for i := 0; i < 3; i++ {
+		checks.Equal(t, true, <-done, "Action should succeed")
+	}
+	time.Sleep(SLEEP_BASIS_HALF)
+
+	checks.Equal(t, 0, wrapper.overlaps, "Exclusive timer should not overlap with exclusive actions")
+	checks.Equal(t, true, wrapper.firedCount() >= 3, "Timer should fire repeatedly")
+
+	runner.TriggerDeactivate()
+	time.Sleep(SLEEP_BASIS)
+	fired := wrapper.firedCount()
+	time.Sleep(SLEEP_BASIS)
+	checks.Equal(t, fired, wrapper.firedCount(), "Timer should be cancelled after deactivation")
+}
@@ -0,0 +1,120 @@
+package localpersistence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/darlean-io/darlean.go/base/persistence"
+)
+
+// FileStore stores every item in a separate file within a directory. Items are written to a temporary
+// file first and then renamed, so that a crash never leaves a partially written item behind.
+// A directory must only be used by one FileStore at a time. Satisfies [persistence.Store].
+type FileStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+type fileRecord struct {
+	Key     persistence.Key `json:"key"`
+	Version string          `json:"version"`
+	Value   []byte          `json:"value"`
+}
+
+// NewFileStore returns a store that keeps its items in dir. The directory is created when it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (store *FileStore) Load(key persistence.Key) (*persistence.Item, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.read(key)
+}
+
+func (store *FileStore) Store(key persistence.Key, value []byte, expectedVersion string) (string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	current, err := store.read(key)
+	if err != nil {
+		return "", err
+	}
+	if !checkVersion(current, expectedVersion) {
+		return "", persistence.ErrVersionConflict
+	}
+	record := fileRecord{
+		Key:     key,
+		Version: nextVersion(current),
+		Value:   value,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	path := store.path(key)
+	temp := path + ".tmp"
+	err = os.WriteFile(temp, data, 0644)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(temp, path)
+	if err != nil {
+		return "", err
+	}
+	return record.Version, nil
+}
+
+func (store *FileStore) Delete(key persistence.Key, expectedVersion string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	current, err := store.read(key)
+	if err != nil {
+		return err
+	}
+	if !checkVersion(current, expectedVersion) {
+		return persistence.ErrVersionConflict
+	}
+	if current == nil {
+		return nil
+	}
+	return os.Remove(store.path(key))
+}
+
+func (store *FileStore) read(key persistence.Key) (*persistence.Item, error) {
+	data, err := os.ReadFile(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record fileRecord
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, err
+	}
+	return &persistence.Item{
+		Value:   record.Value,
+		Version: record.Version,
+	}, nil
+}
+
+// path returns the file name for key. The encoded key is hashed because it can contain characters that
+// are not allowed in file names.
+func (store *FileStore) path(key persistence.Key) string {
+	hash := sha256.Sum256([]byte(encodeKey(key)))
+	return filepath.Join(store.dir, hex.EncodeToString(hash[:])+".json")
+}
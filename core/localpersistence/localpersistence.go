@@ -0,0 +1,59 @@
+/*
+Package localpersistence provides local implementations of [persistence.Store]: an in-memory store
+(useful for tests) and a store that keeps every item in a separate file in a directory.
+*/
+package localpersistence
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/darlean-io/darlean.go/base/persistence"
+)
+
+// encodeKey returns a unique string representation of key. Every part is prefixed with its length
+// so that different keys can never result in the same encoding.
+func encodeKey(key persistence.Key) string {
+	var b strings.Builder
+	writePart := func(part string) {
+		b.WriteString(strconv.Itoa(len(part)))
+		b.WriteByte(':')
+		b.WriteString(part)
+	}
+	writeParts := func(parts []string) {
+		b.WriteString(strconv.Itoa(len(parts)))
+		b.WriteByte(';')
+		for _, part := range parts {
+			writePart(part)
+		}
+	}
+	writePart(key.ActorType)
+	writeParts(key.ActorId)
+	writeParts(key.PartitionKey)
+	writeParts(key.SortKey)
+	return b.String()
+}
+
+// checkVersion returns whether an item with currentVersion (or nil when the item does not exist)
+// may be modified by someone that expects expectedVersion.
+func checkVersion(current *persistence.Item, expectedVersion string) bool {
+	if expectedVersion == persistence.VERSION_ANY {
+		return true
+	}
+	if current == nil {
+		return expectedVersion == persistence.VERSION_NONE
+	}
+	return current.Version == expectedVersion
+}
+
+// nextVersion returns the version that follows on current.
+func nextVersion(current *persistence.Item) string {
+	if current == nil {
+		return "1"
+	}
+	n, err := strconv.ParseUint(current.Version, 10, 64)
+	if err != nil {
+		return "1"
+	}
+	return strconv.FormatUint(n+1, 10)
+}
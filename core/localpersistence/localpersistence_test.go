@@ -0,0 +1,65 @@
+package localpersistence
+
+import (
+	"testing"
+
+	"github.com/darlean-io/darlean.go/base/persistence"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func testStore(t *testing.T, store persistence.Store) {
+	key := persistence.Key{ActorType: "counter", ActorId: []string{"a"}}
+	otherKey := persistence.Key{ActorType: "counter", ActorId: []string{"a"}, SortKey: []string{"x"}}
+
+	item, err := store.Load(key)
+	checks.Equal(t, nil, err, "Load of unexisting item should not fail")
+	checks.Equal(t, true, item == nil, "Unexisting item should be nil")
+
+	v1, err := store.Store(key, []byte("one"), persistence.VERSION_NONE)
+	checks.Equal(t, nil, err, "Initial store should succeed")
+
+	_, err = store.Store(key, []byte("conflict"), persistence.VERSION_NONE)
+	checks.Equal(t, persistence.ErrVersionConflict, err, "Creating an existing item should conflict")
+
+	v2, err := store.Store(key, []byte("two"), v1)
+	checks.Equal(t, nil, err, "Store with current version should succeed")
+
+	_, err = store.Store(key, []byte("stale"), v1)
+	checks.Equal(t, persistence.ErrVersionConflict, err, "Store with stale version should conflict")
+
+	_, err = store.Store(otherKey, []byte("other"), persistence.VERSION_ANY)
+	checks.Equal(t, nil, err, "Store for other sort key should succeed")
+
+	item, _ = store.Load(key)
+	checks.Equal(t, "two", string(item.Value), "Loaded value")
+	checks.Equal(t, v2, item.Version, "Loaded version")
+
+	// Modifying a loaded value must not modify the stored item
+	item.Value[0] = 'T'
+	item, _ = store.Load(key)
+	checks.Equal(t, "two", string(item.Value), "Loaded value is a copy")
+
+	err = store.Delete(key, v1)
+	checks.Equal(t, persistence.ErrVersionConflict, err, "Delete with stale version should conflict")
+
+	err = store.Delete(key, v2)
+	checks.Equal(t, nil, err, "Delete with current version should succeed")
+
+	item, _ = store.Load(key)
+	checks.Equal(t, true, item == nil, "Deleted item should be nil")
+
+	item, _ = store.Load(otherKey)
+	checks.Equal(t, "other", string(item.Value), "Item for other sort key should be untouched")
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		panic(err)
+	}
+	testStore(t, store)
+}
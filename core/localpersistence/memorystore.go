@@ -0,0 +1,68 @@
+package localpersistence
+
+import (
+	"sync"
+
+	"github.com/darlean-io/darlean.go/base/persistence"
+)
+
+// MemoryStore keeps all items in memory. Satisfies [persistence.Store].
+type MemoryStore struct {
+	items map[string]persistence.Item
+	mutex sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]persistence.Item),
+	}
+}
+
+func (store *MemoryStore) Load(key persistence.Key) (*persistence.Item, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	item, has := store.items[encodeKey(key)]
+	if !has {
+		return nil, nil
+	}
+	// Copy the value, because deserialized state can refer to it (see jsonbinary.Deserialize) and
+	// must not be able to modify the stored item
+	item.Value = append([]byte(nil), item.Value...)
+	return &item, nil
+}
+
+func (store *MemoryStore) Store(key persistence.Key, value []byte, expectedVersion string) (string, error) {
+	k := encodeKey(key)
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	current := store.get(k)
+	if !checkVersion(current, expectedVersion) {
+		return "", persistence.ErrVersionConflict
+	}
+	version := nextVersion(current)
+	// Copy the value so that the caller can safely reuse its buffer
+	store.items[k] = persistence.Item{
+		Value:   append([]byte(nil), value...),
+		Version: version,
+	}
+	return version, nil
+}
+
+func (store *MemoryStore) Delete(key persistence.Key, expectedVersion string) error {
+	k := encodeKey(key)
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if !checkVersion(store.get(k), expectedVersion) {
+		return persistence.ErrVersionConflict
+	}
+	delete(store.items, k)
+	return nil
+}
+
+func (store *MemoryStore) get(k string) *persistence.Item {
+	item, has := store.items[k]
+	if !has {
+		return nil
+	}
+	return &item
+}
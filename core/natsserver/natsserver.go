@@ -0,0 +1,217 @@
+/*
+Package natsserver runs an embedded NATS server within the current process, so that a small Darlean
+cluster can run without an external NATS server. The application that hosts the server acts as the
+message bus for the other applications in the cluster (when the server listens on a port), or only
+for itself (when it runs in-process only).
+
+JetStream is always disabled; Darlean only needs core NATS.
+
+Applications that accept a NATS address from configuration can use [Open], so that the address [EMBEDDED]
+(optionally followed by a port) starts an embedded server instead of connecting to an external one.
+*/
+package natsserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darlean-io/darlean.go/core/natstransport"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+const DEFAULT_HOST = "127.0.0.1"
+const READY_TIMEOUT = 10 * time.Second
+
+// EMBEDDED is the NATS address for which [Open] starts an in-process only server. Use "embedded:<port>" or
+// "embedded:<host>:<port>" to let the server listen for other applications as well.
+const EMBEDDED = "embedded"
+
+// RANDOM_PORT can be used as port to let the server pick a free port.
+const RANDOM_PORT = server.RANDOM_PORT
+
+var ErrNotReady = errors.New("natsserver: server not ready for connections")
+
+type Options struct {
+	// Host to listen on for client connections. Defaults to [DEFAULT_HOST], so that only local
+	// applications can connect.
+	Host string
+	// Port to listen on for client connections. Zero means the NATS default (4222); use [RANDOM_PORT]
+	// to pick a free port.
+	Port int
+	// InProcessOnly prevents the server from listening for client connections at all. Only
+	// transports in the same process (see [Server.Connect]) can connect.
+	InProcessOnly bool
+
+	// Name of the cluster. Required when ClusterPort or Routes are set.
+	ClusterName string
+	// Host and port to listen on for connections from other servers in the cluster. The cluster
+	// listener is disabled when ClusterPort is zero.
+	ClusterHost string
+	ClusterPort int
+	// Routes to other servers in the cluster, like "nats-route://host:6222".
+	Routes []string
+
+	// Maximum time to wait until the server is ready for connections. Defaults to [READY_TIMEOUT].
+	ReadyTimeout time.Duration
+	// Log enables the logging of the NATS server to stderr.
+	Log bool
+}
+
+// Server is an embedded NATS server.
+type Server struct {
+	server *server.Server
+}
+
+// Start starts an embedded NATS server and waits until it is ready for connections.
+func Start(options Options) (*Server, error) {
+	opts, err := serverOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		return nil, err
+	}
+	if options.Log {
+		s.ConfigureLogger()
+	}
+
+	go s.Start()
+
+	timeout := options.ReadyTimeout
+	if timeout <= 0 {
+		timeout = READY_TIMEOUT
+	}
+	if !s.ReadyForConnections(timeout) {
+		s.Shutdown()
+		return nil, ErrNotReady
+	}
+	return &Server{server: s}, nil
+}
+
+func serverOptions(options Options) (*server.Options, error) {
+	host := options.Host
+	if host == "" {
+		host = DEFAULT_HOST
+	}
+	opts := server.Options{
+		Host:       host,
+		Port:       options.Port,
+		DontListen: options.InProcessOnly,
+		JetStream:  false,
+		NoSigs:     true,
+		NoLog:      !options.Log,
+	}
+
+	if options.ClusterPort != 0 || len(options.Routes) > 0 {
+		if options.ClusterName == "" {
+			return nil, errors.New("natsserver: cluster name required")
+		}
+		opts.Cluster = server.ClusterOpts{
+			Name: options.ClusterName,
+			Host: options.ClusterHost,
+			Port: options.ClusterPort,
+		}
+		if opts.Cluster.Host == "" {
+			opts.Cluster.Host = host
+		}
+		for _, route := range options.Routes {
+			u, err := url.Parse(route)
+			if err != nil {
+				return nil, fmt.Errorf("natsserver: invalid route %q: %w", route, err)
+			}
+			opts.Routes = append(opts.Routes, u)
+		}
+	}
+	return &opts, nil
+}
+
+// Address returns the url that other applications can use to connect to the server. Returns an empty
+// string when the server runs in-process only.
+func (s *Server) Address() string {
+	if s.server.Addr() == nil {
+		return ""
+	}
+	return s.server.ClientURL()
+}
+
+// InProcessConn returns a connection to the server that does not use the network. Satisfies
+// nats.InProcessConnProvider.
+func (s *Server) InProcessConn() (net.Conn, error) {
+	return s.server.InProcessConn()
+}
+
+// Connect creates a transport for appId that is connected in-process to the server. The other
+// fields of options are used as provided.
+func (s *Server) Connect(appId string, options natstransport.Options) (*natstransport.NatsTransport, error) {
+	options.AppId = appId
+	options.InProcessServer = s
+	return natstransport.NewWithOptions(options)
+}
+
+// Shutdown stops the server. Transports that are connected to it lose their connection.
+func (s *Server) Shutdown() {
+	s.server.Shutdown()
+	s.server.WaitForShutdown()
+}
+
+// ParseAddress returns the options for an embedded server when address is [EMBEDDED], "embedded:<port>"
+// or "embedded:<host>:<port>". Returns false when address refers to an external server.
+func ParseAddress(address string) (Options, bool, error) {
+	if address == EMBEDDED {
+		return Options{InProcessOnly: true}, true, nil
+	}
+	listen, found := strings.CutPrefix(address, EMBEDDED+":")
+	if !found {
+		return Options{}, false, nil
+	}
+	host := ""
+	portString := listen
+	if strings.Contains(listen, ":") {
+		var err error
+		host, portString, err = net.SplitHostPort(listen)
+		if err != nil {
+			return Options{}, true, fmt.Errorf("natsserver: invalid address %q: %w", address, err)
+		}
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return Options{}, true, fmt.Errorf("natsserver: invalid port in address %q", address)
+	}
+	return Options{Host: host, Port: port}, true, nil
+}
+
+// Open creates a transport for appId. When address refers to an embedded server (see [ParseAddress]), the
+// server is started and returned, and the transport connects to it in-process. Otherwise, the transport
+// connects to the external server at address and the returned server is nil. The caller must shut down the
+// server after stopping the transport.
+func Open(address string, appId string, options natstransport.Options) (*natstransport.NatsTransport, *Server, error) {
+	serverOptions, embedded, err := ParseAddress(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !embedded {
+		options.Address = address
+		options.AppId = appId
+		transport, err := natstransport.NewWithOptions(options)
+		return transport, nil, err
+	}
+
+	s, err := Start(serverOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	transport, err := s.Connect(appId, options)
+	if err != nil {
+		s.Shutdown()
+		return nil, nil, err
+	}
+	return transport, s, nil
+}
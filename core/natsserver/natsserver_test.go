@@ -0,0 +1,91 @@
+package natsserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/core/natstransport"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func roundtrip(t *testing.T, sender *natstransport.NatsTransport, receiver *natstransport.NatsTransport, receiverId string) {
+	tags := wire.TagsOut{}
+	tags.Transport_Receiver = receiverId
+	tags.Transport_Return = "sender"
+	tags.Remotecall_Kind = "call"
+	tags.Remotecall_Id = "1"
+	tags.ActorType = "actor"
+	tags.ActionName = "action"
+	err := sender.Send(tags)
+	checks.Equal(t, nil, err, "Send should succeed")
+
+	select {
+	case received := <-receiver.GetInputChannel():
+		checks.Equal(t, "actor", received.ActorType, "Received actor type")
+		checks.Equal(t, "sender", received.Transport_Return, "Received return address")
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+	}
+}
+
+func TestInProcess(t *testing.T) {
+	s, err := Start(Options{InProcessOnly: true})
+	checks.Equal(t, nil, err, "Start should succeed")
+	defer s.Shutdown()
+	checks.Equal(t, "", s.Address(), "No address when in-process only")
+
+	sender, err := s.Connect("sender", natstransport.Options{})
+	checks.Equal(t, nil, err, "Connect sender")
+	defer sender.Stop()
+	receiver, err := s.Connect("receiver", natstransport.Options{})
+	checks.Equal(t, nil, err, "Connect receiver")
+	defer receiver.Stop()
+
+	roundtrip(t, sender, receiver, "receiver")
+}
+
+func TestListening(t *testing.T) {
+	s, err := Start(Options{Port: RANDOM_PORT})
+	checks.Equal(t, nil, err, "Start should succeed")
+	defer s.Shutdown()
+	checks.Equal(t, true, s.Address() != "", "Address when listening")
+
+	sender, err := s.Connect("sender", natstransport.Options{})
+	checks.Equal(t, nil, err, "Connect sender")
+	defer sender.Stop()
+	receiver, err := natstransport.New(s.Address(), "receiver")
+	checks.Equal(t, nil, err, "Connect receiver over the network")
+	defer receiver.Stop()
+
+	roundtrip(t, sender, receiver, "receiver")
+}
+
+func TestOpen(t *testing.T) {
+	_, embedded, _ := ParseAddress("localhost:4222")
+	checks.Equal(t, false, embedded, "External address")
+	options, embedded, _ := ParseAddress("embedded:4500")
+	checks.Equal(t, true, embedded, "Embedded with port")
+	checks.Equal(t, 4500, options.Port, "Port")
+	options, _, _ = ParseAddress("embedded:0.0.0.0:4500")
+	checks.Equal(t, "0.0.0.0", options.Host, "Host")
+	_, _, err := ParseAddress("embedded:port")
+	checks.IsNotNil(t, err, "Invalid port")
+
+	sender, s, err := Open(EMBEDDED, "sender", natstransport.Options{})
+	checks.Equal(t, nil, err, "Open embedded")
+	defer s.Shutdown()
+	defer sender.Stop()
+	checks.Equal(t, "", s.Address(), "Embedded is in-process only")
+
+	receiver, err := s.Connect("receiver", natstransport.Options{})
+	checks.Equal(t, nil, err, "Connect receiver")
+	defer receiver.Stop()
+
+	roundtrip(t, sender, receiver, "receiver")
+}
+
+func TestClusterRequiresName(t *testing.T) {
+	_, err := Start(Options{InProcessOnly: true, ClusterPort: RANDOM_PORT})
+	checks.IsNotNil(t, err, "Cluster without name should fail")
+}
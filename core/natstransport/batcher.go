@@ -0,0 +1,166 @@
+package natstransport
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BatchOptions determine how outgoing messages for the same receiver are combined into one NATS message.
+// A batch is sent when it contains MaxMessages messages, when it contains at least MaxBytes bytes, or when
+// the oldest message in the batch waited for Linger, whichever comes first.
+type BatchOptions struct {
+	MaxMessages int
+	MaxBytes    int
+	// A zero linger time sends a batch as soon as possible. Messages are then only combined when they are
+	// sent at (almost) the same moment.
+	Linger time.Duration
+}
+
+// BATCH_NONE sends every message as a separate NATS message.
+var BATCH_NONE = BatchOptions{MaxMessages: 1}
+
+var BATCH_DEFAULT = BatchOptions{
+	MaxMessages: 64,
+	MaxBytes:    256 * 1024,
+	Linger:      0,
+}
+
+type pendingMessage struct {
+	data []byte
+	done chan error
+}
+
+// batcher collects the outgoing messages for one receiver. At most one batch per receiver is being sent
+// at any moment; messages that are added in the meantime are sent in the next batch. This keeps the
+// messages in order and combines messages of chatty actors without adding latency.
+type batcher struct {
+	receiver string
+	options  BatchOptions
+	send     func(receiver string, data []byte) error
+	mutex    sync.Mutex
+	pending  []pendingMessage
+	size     int
+	timer    *time.Timer
+	flushing bool
+}
+
+func newBatcher(receiver string, options BatchOptions, send func(receiver string, data []byte) error) *batcher {
+	return &batcher{
+		receiver: receiver,
+		options:  options,
+		send:     send,
+	}
+}
+
+// add adds data to the current batch and returns a channel that receives the result of sending the batch.
+func (b *batcher) add(data []byte) chan error {
+	done := make(chan error, 1)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending = append(b.pending, pendingMessage{data: data, done: done})
+	b.size += len(data)
+	if b.flushing {
+		return done
+	}
+	if b.full() {
+		b.startFlush()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.options.Linger, b.trigger)
+	}
+	return done
+}
+
+// full returns whether the pending messages fill at least one batch. Must be invoked with the mutex held.
+func (b *batcher) full() bool {
+	return len(b.pending) >= b.options.MaxMessages || (b.options.MaxBytes > 0 && b.size >= b.options.MaxBytes)
+}
+
+// startFlush starts sending the pending messages. Must be invoked with the mutex held.
+func (b *batcher) startFlush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.flushing = true
+	go b.flushLoop()
+}
+
+func (b *batcher) trigger() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.timer = nil
+	if !b.flushing && len(b.pending) > 0 {
+		b.startFlush()
+	}
+}
+
+// flushLoop sends batches until no messages are pending anymore.
+func (b *batcher) flushLoop() {
+	for {
+		b.mutex.Lock()
+		batch := b.take()
+		if len(batch) == 0 {
+			b.flushing = false
+			b.mutex.Unlock()
+			return
+		}
+		b.mutex.Unlock()
+
+		err := b.send(b.receiver, frame(batch))
+		for _, msg := range batch {
+			msg.done <- err
+		}
+	}
+}
+
+// take removes and returns the messages for the next batch. Must be invoked with the mutex held.
+func (b *batcher) take() []pendingMessage {
+	n := 0
+	size := 0
+	for n < len(b.pending) && (n == 0 || (n < b.options.MaxMessages && (b.options.MaxBytes <= 0 || size < b.options.MaxBytes))) {
+		size += len(b.pending[n].data)
+		n++
+	}
+	batch := b.pending[:n:n]
+	b.pending = b.pending[n:]
+	b.size -= size
+	return batch
+}
+
+// flush sends the pending messages immediately and waits until they are sent.
+func (b *batcher) flush() {
+	b.mutex.Lock()
+	if b.flushing || len(b.pending) == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.flushing = true
+	b.mutex.Unlock()
+	b.flushLoop()
+}
+
+// frame combines the messages into one buffer that starts with a line with the comma-separated
+// lengths of the messages, followed by the messages themselves.
+func frame(batch []pendingMessage) []byte {
+	buf := new(bytes.Buffer)
+	total := 0
+	for i, msg := range batch {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Itoa(len(msg.data)))
+		total += len(msg.data)
+	}
+	buf.WriteByte('\n')
+	buf.Grow(total)
+	for _, msg := range batch {
+		buf.Write(msg.data)
+	}
+	return buf.Bytes()
+}
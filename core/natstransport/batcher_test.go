@@ -0,0 +1,69 @@
+package natstransport
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+type recordingSender struct {
+	batches [][]string
+	delay   time.Duration
+	mutex   sync.Mutex
+}
+
+func (sender *recordingSender) send(receiver string, data []byte) error {
+	buf := bytes.NewBuffer(data)
+	line, _ := buf.ReadString('\n')
+	lengths, err := parseLengths(line)
+	if err != nil {
+		return err
+	}
+	var messages []string
+	for _, length := range lengths {
+		messages = append(messages, string(buf.Next(length)))
+	}
+	sender.mutex.Lock()
+	sender.batches = append(sender.batches, messages)
+	sender.mutex.Unlock()
+	time.Sleep(sender.delay)
+	return nil
+}
+
+func TestBatcher_Linger(t *testing.T) {
+	sender := recordingSender{}
+	b := newBatcher("receiver", BatchOptions{MaxMessages: 3, Linger: 50 * time.Millisecond}, sender.send)
+
+	start := time.Now()
+	done1 := b.add([]byte("a"))
+	done2 := b.add([]byte("bb"))
+	checks.Equal(t, nil, <-done1, "Send should succeed")
+	checks.Equal(t, nil, <-done2, "Send should succeed")
+	checks.Equal(t, true, time.Since(start) >= 50*time.Millisecond, "Batch should wait for the linger time")
+
+	done3 := b.add([]byte("c"))
+	b.add([]byte("d"))
+	b.add([]byte("e"))
+	<-done3
+	checks.Equal(t, [][]string{{"a", "bb"}, {"c", "d", "e"}}, sender.batches, "Full batch should be sent immediately")
+}
+
+func TestBatcher_InFlight(t *testing.T) {
+	sender := recordingSender{delay: 30 * time.Millisecond}
+	b := newBatcher("receiver", BatchOptions{MaxMessages: 3, MaxBytes: 1000}, sender.send)
+
+	first := b.add([]byte("1"))
+	time.Sleep(10 * time.Millisecond)
+	var dones []chan error
+	for _, msg := range []string{"2", "3", "4", "5", "6"} {
+		dones = append(dones, b.add([]byte(msg)))
+	}
+	<-first
+	for _, done := range dones {
+		checks.Equal(t, nil, <-done, "Send should succeed")
+	}
+	checks.Equal(t, [][]string{{"1"}, {"2", "3", "4"}, {"5", "6"}}, sender.batches, "Messages added during a send should be combined in order")
+}
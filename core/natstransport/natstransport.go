@@ -2,8 +2,11 @@ package natstransport
 
 import (
 	"bytes"
+	"crypto/tls"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/darlean-io/darlean.go/core/wire"
@@ -11,23 +14,98 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+// Options contains the settings for a [NatsTransport]. Only Address and AppId are required.
+type Options struct {
+	// Address (url) of the NATS server, like "localhost:4222". Multiple addresses can be separated by commas.
+	Address string
+	// AppId is the name under which the application receives messages.
+	AppId string
+	// SubjectPrefix is prepended to application ids to obtain the NATS subjects. Allows multiple clusters
+	// to share one NATS server.
+	SubjectPrefix string
+	// Name of the connection, as shown in NATS monitoring. Defaults to AppId.
+	Name string
+	// InProcessServer, when not nil, is used to connect to a NATS server that runs in the same process
+	// (see package natsserver). Address is ignored in that case.
+	InProcessServer nats.InProcessConnProvider
+
+	// Credentials. All optional.
+	CredentialsFile string
+	Token           string
+	User            string
+	Password        string
+	// TLS configuration. When not nil, the connection is secured with TLS.
+	TLS *tls.Config
+
+	// Maximum number of reconnect attempts. A negative value means unlimited; zero means the NATS default.
+	MaxReconnects int
+	// Time between reconnect attempts. Zero means the NATS default.
+	ReconnectWait time.Duration
+	// Number of bytes of outgoing messages that are buffered while the connection is being re-established.
+	// Zero means the NATS default; a negative value disables buffering, so that sends fail while disconnected.
+	ReconnectBufferSize int
+
+	// FlowControl makes every send wait until the NATS server has processed the message, which limits the
+	// number of messages in flight. Without flow control, messages are published asynchronously.
+	FlowControl bool
+	// Maximum time to wait for the NATS server when FlowControl is enabled. Defaults to [FLOW_CONTROL_TIMEOUT].
+	FlowControlTimeout time.Duration
+
+	// Batch determines how outgoing messages are combined into NATS messages. Defaults to [BATCH_DEFAULT].
+	Batch *BatchOptions
+
+	// Compression of outgoing messages. Only applied to receivers that announced that they support
+	// compression (see [wire.Compression]). By default, messages are not compressed.
+	Compression *wire.Compression
+}
+
+const FLOW_CONTROL_TIMEOUT = 10 * time.Second
+
 type NatsTransport struct {
 	connection   *nats.Conn
 	rawinput     chan *nats.Msg
 	tagsinput    chan *wire.TagsIn
 	subscription *nats.Subscription
+	options      Options
+	batchOptions BatchOptions
+	batchers     map[string]*batcher
+	batchersLock sync.Mutex
+	peers        *wire.PeerVersions
 }
 
+// New connects to the NATS server at address and receives the messages for appId.
 func New(address string, appId string) (*NatsTransport, error) {
-	nc, err := nats.Connect(address)
+	return NewWithOptions(Options{
+		Address: address,
+		AppId:   appId,
+	})
+}
+
+// NewWithOptions connects to the NATS server with the provided options.
+func NewWithOptions(options Options) (*NatsTransport, error) {
+	if options.Compression != nil {
+		err := options.Compression.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nc, err := nats.Connect(options.Address, connectOptions(options)...)
 	if err != nil {
 		return nil, err
 	}
 
 	input := make(chan *nats.Msg, 16)
 
-	subscription, err := nc.ChanSubscribe(appId, input)
+	subscription, err := nc.ChanSubscribe(options.SubjectPrefix+options.AppId, input)
 	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	// Make sure the server knows the subscription before messages for us are sent.
+	err = nc.Flush()
+	if err != nil {
+		nc.Close()
 		return nil, err
 	}
 
@@ -38,11 +116,23 @@ func New(address string, appId string) (*NatsTransport, error) {
 		close(input)
 	})
 
+	batchOptions := BATCH_DEFAULT
+	if options.Batch != nil {
+		batchOptions = *options.Batch
+	}
+	if options.FlowControlTimeout <= 0 {
+		options.FlowControlTimeout = FLOW_CONTROL_TIMEOUT
+	}
+
 	t := NatsTransport{
 		connection:   nc,
 		subscription: subscription,
 		rawinput:     input,
 		tagsinput:    input2,
+		options:      options,
+		batchOptions: batchOptions,
+		batchers:     make(map[string]*batcher),
+		peers:        wire.NewPeerVersions(),
 	}
 
 	go t.listen(input, input2)
@@ -50,57 +140,164 @@ func New(address string, appId string) (*NatsTransport, error) {
 	return &t, nil
 }
 
+func connectOptions(options Options) []nats.Option {
+	name := options.Name
+	if name == "" {
+		name = options.AppId
+	}
+	result := []nats.Option{nats.Name(name)}
+	if options.InProcessServer != nil {
+		result = append(result, nats.InProcessServer(options.InProcessServer))
+	}
+	if options.CredentialsFile != "" {
+		result = append(result, nats.UserCredentials(options.CredentialsFile))
+	}
+	if options.Token != "" {
+		result = append(result, nats.Token(options.Token))
+	}
+	if options.User != "" {
+		result = append(result, nats.UserInfo(options.User, options.Password))
+	}
+	if options.TLS != nil {
+		result = append(result, nats.Secure(options.TLS))
+	}
+	if options.MaxReconnects != 0 {
+		result = append(result, nats.MaxReconnects(options.MaxReconnects))
+	}
+	if options.ReconnectWait > 0 {
+		result = append(result, nats.ReconnectWait(options.ReconnectWait))
+	}
+	if options.ReconnectBufferSize != 0 {
+		result = append(result, nats.ReconnectBufSize(options.ReconnectBufferSize))
+	}
+	result = append(result, nats.DisconnectErrHandler(func(c *nats.Conn, err error) {
+		if err != nil {
+			fmt.Printf("natstransport: disconnected: %v\n", err)
+		}
+	}))
+	return result
+}
+
+// IsConnected returns whether the transport is currently connected to the NATS server.
+func (transport *NatsTransport) IsConnected() bool {
+	return transport.connection.IsConnected()
+}
+
+// SetBatchOptions sets how outgoing messages are combined into NATS messages. The default is [BATCH_DEFAULT].
+// Must be invoked before the first message is sent.
+func (transport *NatsTransport) SetBatchOptions(options BatchOptions) {
+	transport.batchersLock.Lock()
+	defer transport.batchersLock.Unlock()
+	transport.batchOptions = options
+}
+
+// Send sends tags to the receiver in tags. The message may be combined with other messages for the same
+// receiver (see [BatchOptions]). Send returns when the NATS message that contains the message is published
+// (or, with flow control, processed by the NATS server).
 func (transport *NatsTransport) Send(tags wire.TagsOut) error {
 	buf := new(bytes.Buffer)
-	err := wire.Serialize(buf, tags)
+	err := wire.SerializeWithOptions(buf, tags, wire.SerializeOptions{
+		PeerVersion: transport.peers.Get(tags.Transport_Receiver),
+		Compression: transport.options.Compression,
+	})
 	if err != nil {
 		return err
 	}
-	header := strconv.FormatInt(int64(buf.Len()), 10) + "\n"
-	buf2 := new(bytes.Buffer)
-	buf2.WriteString(header)
-	buf2.Write(buf.Bytes())
+	return <-transport.getBatcher(tags.Transport_Receiver).add(buf.Bytes())
+}
+
+func (transport *NatsTransport) getBatcher(receiver string) *batcher {
+	transport.batchersLock.Lock()
+	defer transport.batchersLock.Unlock()
+	b, has := transport.batchers[receiver]
+	if !has {
+		b = newBatcher(receiver, transport.batchOptions, transport.sendRaw)
+		transport.batchers[receiver] = b
+	}
+	return b
+}
 
-	_, err = transport.connection.Request(tags.Transport_Receiver, buf2.Bytes(), 10*time.Second)
+// sendRaw publishes data to receiver. The response to a call arrives as a separate message, so no
+// NATS-level reply is needed.
+func (transport *NatsTransport) sendRaw(receiver string, data []byte) error {
+	err := transport.connection.Publish(transport.options.SubjectPrefix+receiver, data)
+	if err != nil || !transport.options.FlowControl {
+		return err
+	}
+	return transport.connection.FlushTimeout(transport.options.FlowControlTimeout)
+}
 
-	return err
+// parseLengths parses the line with comma-separated message lengths at the start of a NATS message.
+func parseLengths(line string) ([]int, error) {
+	parts := strings.Split(strings.TrimSpace(line), ",")
+	lengths := make([]int, len(parts))
+	for i, part := range parts {
+		length, err := strconv.Atoi(part)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("natstransport: invalid message length: %q", part)
+		}
+		lengths[i] = length
+	}
+	return lengths, nil
 }
 
-// Listens to nats.Msg on input and forwards them as wire.Tags messages to output.
+// Listens to nats.Msg on input and forwards them as wire.Tags messages to output. Invalid messages
+// are logged and dropped, so that a misbehaving peer can not bring down the application.
 func (transport *NatsTransport) listen(input chan *nats.Msg, output chan *wire.TagsIn) {
 	defer close(output)
 
 	for msg := range input {
-		msg.Ack()
+		if msg.Reply != "" {
+			// Senders that still use request-reply expect an (empty) reply.
+			msg.Respond(nil)
+		}
 		buf := bytes.NewBuffer(msg.Data)
 		lengthsString, err := buf.ReadString('\n')
 		if err != nil {
-			panic(err)
+			fmt.Printf("natstransport: message without lengths on %s\n", msg.Subject)
+			continue
+		}
+		lengths, err := parseLengths(lengthsString)
+		if err != nil {
+			fmt.Printf("natstransport: invalid message on %s: %v\n", msg.Subject, err)
+			continue
 		}
-		lengths := strings.Split(lengthsString, ",")
-		for range lengths {
+		for _, length := range lengths {
+			if length > buf.Len() {
+				fmt.Printf("natstransport: message on %s is shorter than announced\n", msg.Subject)
+				break
+			}
 			tags := wire.TagsIn{}
-			err := wire.Deserialize(buf, &tags)
+			err := wire.Deserialize(bytes.NewBuffer(buf.Next(length)), &tags)
 			if err != nil {
-				panic(err)
+				// The lengths are known, so the other messages of the batch can still be delivered
+				fmt.Printf("natstransport: invalid message on %s: %v\n", msg.Subject, err)
+				continue
 			}
+			transport.peers.Observe(&tags)
 			output <- &tags
 		}
 	}
 }
 
-func (transport *NatsTransport) Stop() {
-	err := transport.subscription.Drain()
-	if err != nil {
-		panic(err)
+// Stop drains the subscription and the connection. Incoming messages that are already
+// received are still delivered to the input channel. Returns the first error that occurred.
+func (transport *NatsTransport) Stop() error {
+	transport.batchersLock.Lock()
+	for _, b := range transport.batchers {
+		b.flush()
 	}
-	err = transport.connection.Drain()
+	transport.batchersLock.Unlock()
+
+	err := transport.subscription.Drain()
+	err2 := transport.connection.Drain()
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return err2
 }
 
 // Returns the channel to which incoming messages are emitted.
-func (transport NatsTransport) GetInputChannel() chan *wire.TagsIn {
+func (transport *NatsTransport) GetInputChannel() chan *wire.TagsIn {
 	return transport.tagsinput
 }
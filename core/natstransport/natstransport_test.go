@@ -0,0 +1,78 @@
+package natstransport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/nats-io/nats.go"
+)
+
+func TestConnectOptions(t *testing.T) {
+	apply := func(options Options) nats.Options {
+		result := nats.GetDefaultOptions()
+		for _, option := range connectOptions(options) {
+			option(&result)
+		}
+		return result
+	}
+
+	defaults := nats.GetDefaultOptions()
+	result := apply(Options{AppId: "app"})
+	checks.Equal(t, "app", result.Name, "Name defaults to app id")
+	checks.Equal(t, defaults.MaxReconnect, result.MaxReconnect, "Default max reconnects")
+	checks.Equal(t, defaults.ReconnectBufSize, result.ReconnectBufSize, "Default reconnect buffer size")
+	checks.Equal(t, false, result.Secure, "No TLS by default")
+
+	result = apply(Options{
+		AppId:               "app",
+		Name:                "conn",
+		Token:               "token",
+		User:                "user",
+		Password:            "secret",
+		TLS:                 &tls.Config{},
+		MaxReconnects:       -1,
+		ReconnectWait:       3 * time.Second,
+		ReconnectBufferSize: 1024,
+	})
+	checks.Equal(t, "conn", result.Name, "Explicit name")
+	checks.Equal(t, "token", result.Token, "Token")
+	checks.Equal(t, "user", result.User, "User")
+	checks.Equal(t, "secret", result.Password, "Password")
+	checks.Equal(t, true, result.Secure, "TLS")
+	checks.Equal(t, -1, result.MaxReconnect, "Unlimited reconnects")
+	checks.Equal(t, 3*time.Second, result.ReconnectWait, "Reconnect wait")
+	checks.Equal(t, 1024, result.ReconnectBufSize, "Reconnect buffer size")
+}
+
+func TestListen_InvalidMessages(t *testing.T) {
+	var valid bytes.Buffer
+	tags := wire.TagsOut{}
+	tags.Transport_Receiver = "app"
+	tags.ActorType = "actor"
+	wire.Serialize(&valid, tags)
+	invalid := []byte("9garbage")
+
+	input := make(chan *nats.Msg, 8)
+	output := make(chan *wire.TagsIn, 8)
+	input <- &nats.Msg{Subject: "app", Data: []byte("no lengths")}
+	input <- &nats.Msg{Subject: "app", Data: []byte("x,1\n")}
+	input <- &nats.Msg{Subject: "app", Data: []byte("1000\n")}
+	batch := append([]byte(fmt.Sprintf("%d,%d\n", len(invalid), valid.Len())), invalid...)
+	input <- &nats.Msg{Subject: "app", Data: append(batch, valid.Bytes()...)}
+	close(input)
+
+	transport := NatsTransport{peers: wire.NewPeerVersions()}
+	transport.listen(input, output)
+
+	received := []*wire.TagsIn{}
+	for tags := range output {
+		received = append(received, tags)
+	}
+	checks.Equal(t, 1, len(received), "Only the valid message is delivered")
+	checks.Equal(t, "actor", received[0].ActorType, "Valid message")
+}
@@ -0,0 +1,329 @@
+/*
+Package pubsub provides a publish/subscribe event bus between actors.
+
+Actors subscribe to topics by means of durable [Subscription]s. Every event that is published to a topic
+is delivered to the matching subscribers by invoking the action of the subscription via a regular
+[invoker.Invoker] (typically an invoke.DynamicInvoker), with the [Event] as the only parameter.
+
+Delivery is at least once: a delivery is stored in a [Store] before it is performed, and only removed
+after the action succeeded. Failed deliveries are retried with an increasing delay (up to [MAX_RETRY_DELAY])
+until they succeed or the subscription is removed. Deliveries for the
+same subscription are performed one at a time, in the order in which the events were published.
+
+In addition, Go code within the application can subscribe non-durably via [Bus.SubscribeFunc]. Such
+subscriptions are not persisted and their events are delivered at most once.
+
+Only one bus should be running per store at any moment.
+*/
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/google/uuid"
+)
+
+// Delay before the first retry of a failed delivery. The delay doubles for every next retry, up to [MAX_RETRY_DELAY].
+const RETRY_DELAY = 500 * time.Millisecond
+
+const MAX_RETRY_DELAY = time.Minute
+
+// Handler is invoked for events of non-durable subscriptions.
+type Handler func(event Event)
+
+type funcSubscription struct {
+	topic   string
+	handler Handler
+}
+
+// Bus publishes events and delivers them to subscribers.
+type Bus struct {
+	store         Store
+	invoker       invoker.Invoker
+	subscriptions map[string]*Subscription
+	handlers      map[string]funcSubscription
+	// Pending deliveries per subscription id, ordered by sequence.
+	queues   map[string][]*Delivery
+	running  map[string]bool
+	sequence uint64
+	mutex    sync.Mutex
+	wakeup   chan struct{}
+	stop     chan bool
+	wg       sync.WaitGroup
+}
+
+func NewBus(store Store, invoker invoker.Invoker) *Bus {
+	return &Bus{
+		store:         store,
+		invoker:       invoker,
+		subscriptions: make(map[string]*Subscription),
+		handlers:      make(map[string]funcSubscription),
+		queues:        make(map[string][]*Delivery),
+		running:       make(map[string]bool),
+		wakeup:        make(chan struct{}, 1),
+	}
+}
+
+// Start loads the subscriptions and pending deliveries from the store and starts delivering events.
+func (bus *Bus) Start() error {
+	subscriptions, err := bus.store.ListSubscriptions()
+	if err != nil {
+		return err
+	}
+	deliveries, err := bus.store.ListDeliveries()
+	if err != nil {
+		return err
+	}
+
+	bus.mutex.Lock()
+	for _, subscription := range subscriptions {
+		s := subscription
+		bus.subscriptions[s.Id] = &s
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Sequence < deliveries[j].Sequence
+	})
+	for _, delivery := range deliveries {
+		d := delivery
+		if bus.subscriptions[d.SubscriptionId] == nil {
+			bus.store.DeleteDelivery(d.Id)
+			continue
+		}
+		bus.queues[d.SubscriptionId] = append(bus.queues[d.SubscriptionId], &d)
+		if d.Sequence > bus.sequence {
+			bus.sequence = d.Sequence
+		}
+	}
+	bus.mutex.Unlock()
+
+	bus.stop = make(chan bool)
+	bus.wg.Add(1)
+	go bus.loop(bus.stop)
+	return nil
+}
+
+// Stop stops delivering events and waits for the deliveries that are in progress to finish. Pending
+// deliveries remain in the store and are performed after the next start.
+func (bus *Bus) Stop() {
+	if bus.stop != nil {
+		stop := bus.stop
+		bus.stop = nil
+		stop <- true
+	}
+	bus.wg.Wait()
+}
+
+// Subscribe stores subscription and returns its id. A subscription with the same id as an existing
+// subscription replaces the existing subscription.
+func (bus *Bus) Subscribe(subscription Subscription) (string, error) {
+	if subscription.Id == "" {
+		subscription.Id = uuid.NewString()
+	}
+	err := bus.store.PutSubscription(subscription)
+	if err != nil {
+		return "", err
+	}
+	bus.mutex.Lock()
+	bus.subscriptions[subscription.Id] = &subscription
+	bus.mutex.Unlock()
+	return subscription.Id, nil
+}
+
+// SubscribeFunc subscribes handler non-durably to topic and returns the id of the subscription.
+func (bus *Bus) SubscribeFunc(topic string, handler Handler) string {
+	id := uuid.NewString()
+	bus.mutex.Lock()
+	bus.handlers[id] = funcSubscription{topic: topic, handler: handler}
+	bus.mutex.Unlock()
+	return id
+}
+
+// Unsubscribe removes the (durable or non-durable) subscription with the provided id, together with its
+// pending deliveries. A delivery that is already in progress is not aborted.
+func (bus *Bus) Unsubscribe(id string) error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if _, has := bus.handlers[id]; has {
+		delete(bus.handlers, id)
+		return nil
+	}
+
+	for _, delivery := range bus.queues[id] {
+		err := bus.store.DeleteDelivery(delivery.Id)
+		if err != nil {
+			return err
+		}
+	}
+	delete(bus.queues, id)
+	err := bus.store.DeleteSubscription(id)
+	if err != nil {
+		return err
+	}
+	delete(bus.subscriptions, id)
+	return nil
+}
+
+// Publish publishes an event with payload to topic. When Publish returns without error, the event is stored
+// for all matching durable subscriptions and will be delivered to them at least once.
+//
+// When Publish returns an error, the event may already be stored (and delivered) for some of the subscriptions.
+// Callers that retry a failed Publish must therefore tolerate duplicate events. Subscribers can use [Event.Id]
+// to recognize them, as it is the same for all deliveries of one event.
+func (bus *Bus) Publish(topic string, payload any) error {
+	event := Event{
+		Id:          uuid.NewString(),
+		Topic:       topic,
+		Payload:     payload,
+		PublishedAt: time.Now(),
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for _, subscription := range bus.handlers {
+		if Matches(subscription.topic, topic) {
+			go subscription.handler(event)
+		}
+	}
+
+	for id, subscription := range bus.subscriptions {
+		if !Matches(subscription.Topic, topic) {
+			continue
+		}
+		bus.sequence++
+		delivery := Delivery{
+			Id:             uuid.NewString(),
+			SubscriptionId: id,
+			Sequence:       bus.sequence,
+			Event:          event,
+			NextAttempt:    event.PublishedAt,
+		}
+		err := bus.store.PutDelivery(delivery)
+		if err != nil {
+			return err
+		}
+		bus.queues[id] = append(bus.queues[id], &delivery)
+	}
+	bus.notify()
+	return nil
+}
+
+// Matches returns whether a subscription for pattern matches topic. A pattern that ends with "*" matches
+// all topics that start with the part before the "*"; other patterns must be equal to topic.
+func Matches(pattern string, topic string) bool {
+	if prefix, found := strings.CutSuffix(pattern, "*"); found {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return pattern == topic
+}
+
+func (bus *Bus) notify() {
+	select {
+	case bus.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (bus *Bus) loop(stop <-chan bool) {
+	defer bus.wg.Done()
+	for {
+		next := bus.deliverDue()
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			timer = time.After(time.Until(next))
+		}
+		select {
+		case <-stop:
+			return
+		case <-bus.wakeup:
+		case <-timer:
+		}
+	}
+}
+
+// deliverDue starts the first pending delivery of every subscription for which no delivery is in progress
+// and returns the earliest moment at which a delivery that is not due yet must be attempted (or the zero
+// time when there are none).
+func (bus *Bus) deliverDue() time.Time {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	now := time.Now()
+	var next time.Time
+	for id, queue := range bus.queues {
+		if len(queue) == 0 || bus.running[id] {
+			continue
+		}
+		delivery := queue[0]
+		if !delivery.NextAttempt.After(now) {
+			bus.running[id] = true
+			bus.wg.Add(1)
+			go bus.deliver(*bus.subscriptions[id], *delivery)
+			continue
+		}
+		if next.IsZero() || delivery.NextAttempt.Before(next) {
+			next = delivery.NextAttempt
+		}
+	}
+	return next
+}
+
+func (bus *Bus) deliver(subscription Subscription, delivery Delivery) {
+	defer bus.wg.Done()
+
+	_, e := bus.invoker.Invoke(&invoker.Request{
+		ActorType:  subscription.ActorType,
+		ActorId:    subscription.ActorId,
+		ActionName: subscription.ActionName,
+		Parameters: []any{delivery.Event},
+	})
+
+	bus.mutex.Lock()
+	defer func() {
+		delete(bus.running, subscription.Id)
+		bus.mutex.Unlock()
+		bus.notify()
+	}()
+
+	queue := bus.queues[subscription.Id]
+	if len(queue) == 0 || queue[0].Id != delivery.Id {
+		// The subscription was removed while the delivery was in progress.
+		return
+	}
+
+	if e != nil {
+		queue[0].Attempts++
+		queue[0].NextAttempt = time.Now().Add(retryDelay(queue[0].Attempts))
+		if queue[0].Attempts == 1 || retryDelay(queue[0].Attempts) == MAX_RETRY_DELAY {
+			fmt.Printf("pubsub: delivery of event %s to %s failed (attempt %d): %v\n", delivery.Event.Id, subscription.ActorType, queue[0].Attempts, e.Message)
+		}
+		err := bus.store.PutDelivery(*queue[0])
+		if err != nil {
+			fmt.Printf("pubsub: unable to update delivery %s in store: %v\n", delivery.Id, err)
+		}
+		return
+	}
+
+	err := bus.store.DeleteDelivery(delivery.Id)
+	if err != nil {
+		fmt.Printf("pubsub: unable to remove delivery %s from store: %v\n", delivery.Id, err)
+	}
+	bus.queues[subscription.Id] = queue[1:]
+	if len(bus.queues[subscription.Id]) == 0 {
+		delete(bus.queues, subscription.Id)
+	}
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := RETRY_DELAY
+	for i := 1; i < attempts && delay < MAX_RETRY_DELAY; i++ {
+		delay *= 2
+	}
+	return min(delay, MAX_RETRY_DELAY)
+}
@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+type recordingInvoker struct {
+	received map[string][]string
+	failures int
+	mutex    sync.Mutex
+}
+
+func (inv *recordingInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	if inv.failures > 0 {
+		inv.failures--
+		return nil, actionerror.New(actionerror.Options{Code: "FAILED"})
+	}
+	event := request.Parameters[0].(Event)
+	inv.received[request.ActorId[0]] = append(inv.received[request.ActorId[0]], event.Payload.(string))
+	return nil, nil
+}
+
+func (inv *recordingInvoker) get(id string) []string {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	return inv.received[id]
+}
+
+func TestBus(t *testing.T) {
+	inv := &recordingInvoker{received: make(map[string][]string), failures: 1}
+	bus := NewBus(NewMemoryStore(), inv)
+	checks.Equal(t, nil, bus.Start(), "Start should succeed")
+
+	bus.Subscribe(Subscription{Topic: "orders.*", ActorType: "a", ActorId: []string{"all"}, ActionName: "on"})
+	createdId, _ := bus.Subscribe(Subscription{Topic: "orders.created", ActorType: "a", ActorId: []string{"created"}, ActionName: "on"})
+
+	var local []string
+	var localMutex sync.Mutex
+	bus.SubscribeFunc("orders.created", func(event Event) {
+		localMutex.Lock()
+		local = append(local, event.Payload.(string))
+		localMutex.Unlock()
+	})
+
+	bus.Publish("orders.created", "1")
+	bus.Publish("orders.deleted", "2")
+	bus.Publish("orders.created", "3")
+	bus.Publish("customers.created", "4")
+
+	time.Sleep(RETRY_DELAY + 300*time.Millisecond)
+	checks.Equal(t, []string{"1", "2", "3"}, inv.get("all"), "Wildcard subscriber should receive all events in order, despite a failure")
+	checks.Equal(t, []string{"1", "3"}, inv.get("created"), "Exact subscriber should receive matching events in order")
+	localMutex.Lock()
+	checks.Equal(t, 2, len(local), "Func subscriber should receive matching events")
+	localMutex.Unlock()
+
+	checks.Equal(t, nil, bus.Unsubscribe(createdId), "Unsubscribe should succeed")
+	bus.Publish("orders.created", "5")
+	time.Sleep(100 * time.Millisecond)
+	checks.Equal(t, []string{"1", "3"}, inv.get("created"), "Unsubscribed subscriber should not receive events")
+	checks.Equal(t, []string{"1", "2", "3", "5"}, inv.get("all"), "Subscriber should receive new events")
+	bus.Stop()
+}
+
+func TestBus_Restart(t *testing.T) {
+	store := NewMemoryStore()
+	failing := &recordingInvoker{received: make(map[string][]string), failures: 1000}
+	bus := NewBus(store, failing)
+	bus.Start()
+	bus.Subscribe(Subscription{Topic: "t", ActorType: "a", ActorId: []string{"s"}, ActionName: "on"})
+	bus.Publish("t", "1")
+	bus.Publish("t", "2")
+	time.Sleep(50 * time.Millisecond)
+	bus.Stop()
+
+	deliveries, _ := store.ListDeliveries()
+	checks.Equal(t, 2, len(deliveries), "Undelivered events should remain in the store")
+
+	inv := &recordingInvoker{received: make(map[string][]string)}
+	bus = NewBus(store, inv)
+	bus.Start()
+	time.Sleep(RETRY_DELAY + 300*time.Millisecond)
+	bus.Stop()
+	checks.Equal(t, []string{"1", "2"}, inv.get("s"), "Pending deliveries should be performed after restart")
+	deliveries, _ = store.ListDeliveries()
+	checks.Equal(t, 0, len(deliveries), "Performed deliveries should be removed from the store")
+}
+
+func TestBus_KeepRetrying(t *testing.T) {
+	store := NewMemoryStore()
+	store.PutSubscription(Subscription{Id: "sub", Topic: "t", ActorType: "a", ActorId: []string{"s"}, ActionName: "on"})
+	store.PutDelivery(Delivery{Id: "d", SubscriptionId: "sub", Sequence: 1, Event: Event{Id: "e", Topic: "t", Payload: "1"}, Attempts: 50, NextAttempt: time.Now()})
+
+	failing := &recordingInvoker{received: make(map[string][]string), failures: 1000}
+	bus := NewBus(store, failing)
+	bus.Start()
+	time.Sleep(50 * time.Millisecond)
+	bus.Stop()
+
+	deliveries, _ := store.ListDeliveries()
+	checks.Equal(t, 1, len(deliveries), "Delivery should not be dropped after many attempts")
+	checks.Equal(t, 51, deliveries[0].Attempts, "Attempt should be counted")
+	checks.Equal(t, true, time.Until(deliveries[0].NextAttempt) > MAX_RETRY_DELAY-time.Second, "Delivery should be retried at the maximum delay")
+}
@@ -0,0 +1,66 @@
+package pubsub
+
+import "sync"
+
+// MemoryStore keeps subscriptions and deliveries in memory. They do not survive restarts; intended for tests
+// and local development. Satisfies [Store].
+type MemoryStore struct {
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+	mutex         sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string]Delivery),
+	}
+}
+
+func (store *MemoryStore) PutSubscription(subscription Subscription) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.subscriptions[subscription.Id] = subscription
+	return nil
+}
+
+func (store *MemoryStore) DeleteSubscription(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.subscriptions, id)
+	return nil
+}
+
+func (store *MemoryStore) ListSubscriptions() ([]Subscription, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	result := make([]Subscription, 0, len(store.subscriptions))
+	for _, subscription := range store.subscriptions {
+		result = append(result, subscription)
+	}
+	return result, nil
+}
+
+func (store *MemoryStore) PutDelivery(delivery Delivery) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.deliveries[delivery.Id] = delivery
+	return nil
+}
+
+func (store *MemoryStore) DeleteDelivery(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.deliveries, id)
+	return nil
+}
+
+func (store *MemoryStore) ListDeliveries() ([]Delivery, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	result := make([]Delivery, 0, len(store.deliveries))
+	for _, delivery := range store.deliveries {
+		result = append(result, delivery)
+	}
+	return result, nil
+}
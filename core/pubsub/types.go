@@ -0,0 +1,47 @@
+package pubsub
+
+import "time"
+
+// Event is passed as the only parameter to the action of a subscriber.
+type Event struct {
+	Id          string    `json:"id"`
+	Topic       string    `json:"topic"`
+	Payload     any       `json:"payload"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+// Subscription is a durable subscription of an actor to a topic. Events for the topic are delivered by
+// invoking ActionName on the actor.
+type Subscription struct {
+	// Id of the subscription. Generated by [Bus.Subscribe] when empty.
+	Id string `json:"id"`
+	// Topic to subscribe to. A topic that ends with "*" matches all topics that start with the part before the "*".
+	Topic      string   `json:"topic"`
+	ActorType  string   `json:"actorType"`
+	ActorId    []string `json:"actorId"`
+	ActionName string   `json:"actionName"`
+}
+
+// Delivery is an event that still has to be delivered to a subscription.
+type Delivery struct {
+	Id             string `json:"id"`
+	SubscriptionId string `json:"subscriptionId"`
+	// Sequence determines the order in which deliveries for the same subscription are performed.
+	Sequence    uint64    `json:"sequence"`
+	Event       Event     `json:"event"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+// Store persists subscriptions and pending deliveries, so that events are delivered at least once, even
+// when the application restarts in between.
+type Store interface {
+	PutSubscription(subscription Subscription) error
+	// DeleteSubscription removes a subscription. Deleting an unexisting subscription is not an error.
+	DeleteSubscription(id string) error
+	ListSubscriptions() ([]Subscription, error)
+	PutDelivery(delivery Delivery) error
+	// DeleteDelivery removes a delivery. Deleting an unexisting delivery is not an error.
+	DeleteDelivery(id string) error
+	ListDeliveries() ([]Delivery, error)
+}
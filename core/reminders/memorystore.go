@@ -0,0 +1,39 @@
+package reminders
+
+import "sync"
+
+// MemoryStore keeps reminders in memory. Reminders do not survive restarts; intended for tests. Satisfies [Store].
+type MemoryStore struct {
+	reminders map[string]Reminder
+	mutex     sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		reminders: make(map[string]Reminder),
+	}
+}
+
+func (store *MemoryStore) Put(reminder Reminder) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.reminders[reminder.Id] = reminder
+	return nil
+}
+
+func (store *MemoryStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.reminders, id)
+	return nil
+}
+
+func (store *MemoryStore) List() ([]Reminder, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	result := make([]Reminder, 0, len(store.reminders))
+	for _, reminder := range store.reminders {
+		result = append(result, reminder)
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package reminders
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/darlean-io/darlean.go/base/persistence"
+)
+
+const PERSISTENCE_ACTOR_TYPE = "io.darlean.reminders"
+
+// PersistenceStore keeps all reminders of one scheduler as a single item in a [persistence.Store].
+// Satisfies [Store].
+type PersistenceStore struct {
+	store persistence.Store
+	key   persistence.Key
+	mutex sync.Mutex
+}
+
+// NewPersistenceStore returns a store that keeps the reminders under name in store. Different schedulers
+// that use the same persistence store must use different names.
+func NewPersistenceStore(store persistence.Store, name string) *PersistenceStore {
+	return &PersistenceStore{
+		store: store,
+		key: persistence.Key{
+			ActorType:    PERSISTENCE_ACTOR_TYPE,
+			PartitionKey: []string{name},
+		},
+	}
+}
+
+func (store *PersistenceStore) Put(reminder Reminder) error {
+	return store.update(func(reminders map[string]Reminder) {
+		reminders[reminder.Id] = reminder
+	})
+}
+
+func (store *PersistenceStore) Delete(id string) error {
+	return store.update(func(reminders map[string]Reminder) {
+		delete(reminders, id)
+	})
+}
+
+func (store *PersistenceStore) List() ([]Reminder, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	reminders, _, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Reminder, 0, len(reminders))
+	for _, reminder := range reminders {
+		result = append(result, reminder)
+	}
+	return result, nil
+}
+
+func (store *PersistenceStore) load() (map[string]Reminder, string, error) {
+	reminders := make(map[string]Reminder)
+	item, err := store.store.Load(store.key)
+	if err != nil {
+		return nil, "", err
+	}
+	if item == nil {
+		return reminders, persistence.VERSION_NONE, nil
+	}
+	err = json.Unmarshal(item.Value, &reminders)
+	return reminders, item.Version, err
+}
+
+// update applies modify to the stored reminders. Retries when the item was modified concurrently.
+func (store *PersistenceStore) update(modify func(reminders map[string]Reminder)) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for {
+		reminders, version, err := store.load()
+		if err != nil {
+			return err
+		}
+		modify(reminders)
+		data, err := json.Marshal(reminders)
+		if err != nil {
+			return err
+		}
+		_, err = store.store.Store(store.key, data, version)
+		if !errors.Is(err, persistence.ErrVersionConflict) {
+			return err
+		}
+	}
+}
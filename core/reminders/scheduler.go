@@ -0,0 +1,246 @@
+/*
+Package reminders provides persistent timers ("reminders") for actors.
+
+A reminder invokes an action on an actor at a certain due time, and optionally repeatedly after that. Because
+the action is invoked via a regular [invoker.Invoker] (typically an invoke.DynamicInvoker), the actor does
+not have to be active when the reminder fires: it is activated on demand. Reminders are kept in a [Store] so
+that they survive restarts of the application.
+
+Only one scheduler should be running per store at any moment.
+*/
+package reminders
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/google/uuid"
+)
+
+// Delay before the invocation of a reminder is retried after it failed.
+const RETRY_DELAY = 5 * time.Second
+
+// Maximum number of attempts to invoke the action for one due time. After that, the due time is skipped
+// (for repeating reminders) or the reminder is removed (for one-shot reminders).
+const MAX_ATTEMPTS = 5
+
+// Scheduler invokes the actions of reminders when they are due.
+type Scheduler struct {
+	store     Store
+	invoker   invoker.Invoker
+	reminders map[string]*Reminder
+	// Generation of every reminder. Incremented by every Schedule, so that fire can detect that
+	// the reminder was replaced while it was being invoked.
+	generations map[string]uint64
+	generation  uint64
+	running     map[string]bool
+	mutex       sync.Mutex
+	wakeup      chan struct{}
+	stop        chan bool
+	wg          sync.WaitGroup
+}
+
+func NewScheduler(store Store, invoker invoker.Invoker) *Scheduler {
+	return &Scheduler{
+		store:       store,
+		invoker:     invoker,
+		reminders:   make(map[string]*Reminder),
+		generations: make(map[string]uint64),
+		running:     make(map[string]bool),
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+// Start loads the reminders from the store and starts firing them when they are due. Reminders that
+// became due while the scheduler was not running are fired immediately.
+func (scheduler *Scheduler) Start() error {
+	reminders, err := scheduler.store.List()
+	if err != nil {
+		return err
+	}
+	scheduler.mutex.Lock()
+	for _, reminder := range reminders {
+		r := reminder
+		scheduler.reminders[r.Id] = &r
+		scheduler.generation++
+		scheduler.generations[r.Id] = scheduler.generation
+	}
+	scheduler.mutex.Unlock()
+
+	scheduler.stop = make(chan bool)
+	scheduler.wg.Add(1)
+	go scheduler.loop(scheduler.stop)
+	return nil
+}
+
+// Stop stops firing reminders and waits for the invocations that are in progress to finish.
+func (scheduler *Scheduler) Stop() {
+	if scheduler.stop != nil {
+		stop := scheduler.stop
+		scheduler.stop = nil
+		stop <- true
+	}
+	scheduler.wg.Wait()
+}
+
+// Schedule stores reminder and returns its id. A reminder with the same id as an existing reminder
+// replaces the existing reminder.
+func (scheduler *Scheduler) Schedule(reminder Reminder) (string, error) {
+	if reminder.Id == "" {
+		reminder.Id = uuid.NewString()
+	}
+	reminder.Attempts = 0
+	err := scheduler.store.Put(reminder)
+	if err != nil {
+		return "", err
+	}
+	scheduler.mutex.Lock()
+	scheduler.reminders[reminder.Id] = &reminder
+	scheduler.generation++
+	scheduler.generations[reminder.Id] = scheduler.generation
+	scheduler.mutex.Unlock()
+	scheduler.notify()
+	return reminder.Id, nil
+}
+
+// Cancel removes the reminder with the provided id. An invocation that is already in progress is not aborted.
+func (scheduler *Scheduler) Cancel(id string) error {
+	err := scheduler.store.Delete(id)
+	if err != nil {
+		return err
+	}
+	scheduler.mutex.Lock()
+	delete(scheduler.reminders, id)
+	delete(scheduler.generations, id)
+	scheduler.mutex.Unlock()
+	scheduler.notify()
+	return nil
+}
+
+// Get returns a copy of the reminder with the provided id, or nil when it does not exist.
+func (scheduler *Scheduler) Get(id string) *Reminder {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	reminder, found := scheduler.reminders[id]
+	if !found {
+		return nil
+	}
+	r := *reminder
+	return &r
+}
+
+func (scheduler *Scheduler) notify() {
+	select {
+	case scheduler.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (scheduler *Scheduler) loop(stop <-chan bool) {
+	defer scheduler.wg.Done()
+	for {
+		next := scheduler.fireDue(stop)
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			timer = time.After(time.Until(next))
+		}
+		select {
+		case <-stop:
+			return
+		case <-scheduler.wakeup:
+		case <-timer:
+		}
+	}
+}
+
+// fireDue starts the invocation of all reminders that are due, and returns the earliest due time of the
+// remaining reminders (or the zero time when there are none).
+func (scheduler *Scheduler) fireDue(stop <-chan bool) time.Time {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	now := time.Now()
+	var next time.Time
+	for id, reminder := range scheduler.reminders {
+		if scheduler.running[id] {
+			continue
+		}
+		if !reminder.DueTime.After(now) {
+			scheduler.running[id] = true
+			scheduler.wg.Add(1)
+			go scheduler.fire(*reminder, scheduler.generations[id])
+			continue
+		}
+		if next.IsZero() || reminder.DueTime.Before(next) {
+			next = reminder.DueTime
+		}
+	}
+	return next
+}
+
+func (scheduler *Scheduler) fire(reminder Reminder, generation uint64) {
+	defer scheduler.wg.Done()
+
+	_, e := scheduler.invoker.Invoke(&invoker.Request{
+		ActorType:  reminder.ActorType,
+		ActorId:    reminder.ActorId,
+		ActionName: reminder.ActionName,
+		Parameters: reminder.Arguments,
+	})
+
+	updated := reminder
+	remove := false
+	if e != nil {
+		updated.Attempts++
+		if updated.Attempts < MAX_ATTEMPTS {
+			updated.DueTime = time.Now().Add(RETRY_DELAY)
+		} else {
+			fmt.Printf("reminders: giving up on reminder %s for %s after %d attempts: %v\n", reminder.Id, reminder.ActorType, updated.Attempts, e.Message)
+			remove = !scheduler.advance(&updated)
+		}
+	} else {
+		remove = !scheduler.advance(&updated)
+	}
+
+	scheduler.mutex.Lock()
+	defer func() {
+		delete(scheduler.running, reminder.Id)
+		scheduler.mutex.Unlock()
+		scheduler.notify()
+	}()
+
+	current, found := scheduler.generations[reminder.Id]
+	if !found || current != generation {
+		// The reminder was cancelled or replaced while the action was being invoked.
+		return
+	}
+
+	var err error
+	if remove {
+		err = scheduler.store.Delete(reminder.Id)
+		delete(scheduler.reminders, reminder.Id)
+		delete(scheduler.generations, reminder.Id)
+	} else {
+		err = scheduler.store.Put(updated)
+		scheduler.reminders[reminder.Id] = &updated
+	}
+	if err != nil {
+		fmt.Printf("reminders: unable to update reminder %s in store: %v\n", reminder.Id, err)
+	}
+}
+
+// advance moves the due time of a repeating reminder to the first moment in the future. Returns false
+// for one-shot reminders.
+func (scheduler *Scheduler) advance(reminder *Reminder) bool {
+	if reminder.Interval <= 0 {
+		return false
+	}
+	now := time.Now()
+	reminder.Attempts = 0
+	for !reminder.DueTime.After(now) {
+		reminder.DueTime = reminder.DueTime.Add(reminder.Interval)
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package reminders
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/core/localpersistence"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+type recordingInvoker struct {
+	calls map[string]int
+	mutex sync.Mutex
+}
+
+func (inv *recordingInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	inv.calls[request.ActorId[0]+"."+request.ActionName]++
+	return nil, nil
+}
+
+func (inv *recordingInvoker) count(key string) int {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	return inv.calls[key]
+}
+
+func TestScheduler(t *testing.T) {
+	inv := &recordingInvoker{calls: make(map[string]int)}
+	store := NewPersistenceStore(localpersistence.NewMemoryStore(), "test")
+	scheduler := NewScheduler(store, inv)
+	checks.Equal(t, nil, scheduler.Start(), "Start should succeed")
+
+	now := time.Now()
+	_, err := scheduler.Schedule(Reminder{ActorType: "a", ActorId: []string{"once"}, ActionName: "fire", DueTime: now.Add(20 * time.Millisecond)})
+	checks.Equal(t, nil, err, "Schedule should succeed")
+	repeatId, _ := scheduler.Schedule(Reminder{ActorType: "a", ActorId: []string{"repeat"}, ActionName: "fire", DueTime: now, Interval: 50 * time.Millisecond})
+	cancelId, _ := scheduler.Schedule(Reminder{ActorType: "a", ActorId: []string{"cancel"}, ActionName: "fire", DueTime: now.Add(200 * time.Millisecond)})
+	checks.Equal(t, nil, scheduler.Cancel(cancelId), "Cancel should succeed")
+
+	time.Sleep(280 * time.Millisecond)
+	checks.Equal(t, 1, inv.count("once.fire"), "One-shot reminder should fire once")
+	checks.EqualOneOf(t, []any{5, 6, 7}, inv.count("repeat.fire"), "Repeating reminder should fire repeatedly")
+	checks.Equal(t, 0, inv.count("cancel.fire"), "Cancelled reminder should not fire")
+	scheduler.Stop()
+
+	reminders, _ := store.List()
+	checks.Equal(t, 1, len(reminders), "Only the repeating reminder should remain in the store")
+	checks.Equal(t, repeatId, reminders[0].Id, "Remaining reminder should be the repeating reminder")
+
+	// A new scheduler on the same store continues with the persisted reminders
+	inv2 := &recordingInvoker{calls: make(map[string]int)}
+	scheduler2 := NewScheduler(store, inv2)
+	scheduler2.Start()
+	time.Sleep(120 * time.Millisecond)
+	scheduler2.Stop()
+	checks.EqualOneOf(t, []any{1, 2, 3}, inv2.count("repeat.fire"), "Persisted reminder should fire after restart")
+}
+
+type blockingInvoker struct {
+	recordingInvoker
+	started chan struct{}
+	release chan struct{}
+}
+
+func (inv *blockingInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.started <- struct{}{}
+	<-inv.release
+	return inv.recordingInvoker.Invoke(request)
+}
+
+func TestScheduler_ReplaceWhileFiring(t *testing.T) {
+	inv := &blockingInvoker{
+		recordingInvoker: recordingInvoker{calls: make(map[string]int)},
+		started:          make(chan struct{}, 2),
+		release:          make(chan struct{}, 2),
+	}
+	scheduler := NewScheduler(NewPersistenceStore(localpersistence.NewMemoryStore(), "test"), inv)
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	reminder := Reminder{Id: "r", ActorType: "a", ActorId: []string{"r"}, ActionName: "fire", DueTime: time.Now()}
+	scheduler.Schedule(reminder)
+	<-inv.started
+
+	// Schedule the same reminder again (with the same due time) while it is firing
+	scheduler.Schedule(reminder)
+	inv.release <- struct{}{}
+	inv.release <- struct{}{}
+
+	select {
+	case <-inv.started:
+	case <-time.After(time.Second):
+		t.Fatal("Replaced reminder should fire")
+	}
+	time.Sleep(50 * time.Millisecond)
+	checks.Equal(t, 2, inv.count("r.fire"), "Both the original and the replaced reminder should fire")
+	checks.Equal(t, true, scheduler.Get("r") == nil, "One-shot reminder should be removed after firing")
+}
@@ -0,0 +1,29 @@
+package reminders
+
+import "time"
+
+// Reminder describes an action that must be invoked on an actor at a certain moment, and optionally repeatedly
+// after that.
+type Reminder struct {
+	// Id of the reminder. Generated by [Scheduler.Schedule] when empty.
+	Id         string    `json:"id"`
+	ActorType  string    `json:"actorType"`
+	ActorId    []string  `json:"actorId"`
+	ActionName string    `json:"actionName"`
+	Arguments  []any     `json:"arguments"`
+	DueTime    time.Time `json:"dueTime"`
+	// Interval between repetitions. A zero interval makes the reminder a one-shot reminder.
+	Interval time.Duration `json:"interval"`
+	// Number of failed attempts to invoke the action for the current due time.
+	Attempts int `json:"attempts"`
+}
+
+// Store persists reminders so that they survive restarts of the application.
+type Store interface {
+	// Put adds or replaces a reminder.
+	Put(reminder Reminder) error
+	// Delete removes the reminder with the provided id. Deleting an unexisting reminder is not an error.
+	Delete(id string) error
+	// List returns all reminders.
+	List() ([]Reminder, error)
+}
@@ -0,0 +1,127 @@
+package remoteactorregistry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrNoHostsAvailable = errors.New("remoteactorregistry: no registry hosts available")
+
+const HOST_BACKOFF_BASE = 500 * time.Millisecond
+const HOST_BACKOFF_MAX = 30 * time.Second
+
+type hostState struct {
+	name     string
+	failures int
+	retryAt  time.Time
+}
+
+// hostSet keeps track of the health of a list of registry hosts. Healthy hosts are
+// returned in a rotating order so that load is spread; failing hosts are skipped until
+// their exponential backoff has expired.
+type hostSet struct {
+	hosts []*hostState
+	next  int
+	mutex sync.Mutex
+}
+
+func newHostSet(hosts []string) *hostSet {
+	set := hostSet{
+		hosts: make([]*hostState, len(hosts)),
+	}
+	for i, host := range hosts {
+		set.hosts[i] = &hostState{name: host}
+	}
+	return &set
+}
+
+// candidates returns the hosts that can currently be tried, in the order in which
+// they should be tried. When all hosts are backing off, the host that is
+// first allowed to retry is returned, so that we never end up with nothing to try.
+func (set *hostSet) candidates() []string {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	n := len(set.hosts)
+	if n == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	result := make([]string, 0, n)
+	var earliest *hostState
+	for i := 0; i < n; i++ {
+		host := set.hosts[(set.next+i)%n]
+		if !now.Before(host.retryAt) {
+			result = append(result, host.name)
+		} else if earliest == nil || host.retryAt.Before(earliest.retryAt) {
+			earliest = host
+		}
+	}
+	set.next = (set.next + 1) % n
+
+	if len(result) == 0 && earliest != nil {
+		result = append(result, earliest.name)
+	}
+	return result
+}
+
+func (set *hostSet) succeeded(name string) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	host := set.find(name)
+	if host == nil {
+		return
+	}
+	host.failures = 0
+	host.retryAt = time.Time{}
+}
+
+func (set *hostSet) failed(name string) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	host := set.find(name)
+	if host == nil {
+		return
+	}
+	host.failures++
+	delay := HOST_BACKOFF_BASE
+	for i := 1; i < host.failures && delay < HOST_BACKOFF_MAX; i++ {
+		delay *= 2
+	}
+	if delay > HOST_BACKOFF_MAX {
+		delay = HOST_BACKOFF_MAX
+	}
+	host.retryAt = time.Now().Add(delay)
+}
+
+func (set *hostSet) find(name string) *hostState {
+	for _, host := range set.hosts {
+		if host.name == name {
+			return host
+		}
+	}
+	return nil
+}
+
+// HostHealth describes the health of one registry host as seen by a fetcher.
+type HostHealth struct {
+	Host     string
+	Failures int
+	RetryAt  time.Time
+}
+
+func (set *hostSet) health() []HostHealth {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	result := make([]HostHealth, len(set.hosts))
+	for i, host := range set.hosts {
+		result[i] = HostHealth{
+			Host:     host.name,
+			Failures: host.failures,
+			RetryAt:  host.retryAt,
+		}
+	}
+	return result
+}
@@ -1,63 +1,174 @@
 package remoteactorregistry
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/darlean-io/darlean.go/core/invoke"
+	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/normalized"
 
 	"github.com/darlean-io/darlean.go/utils/variant"
 
+	"github.com/darlean-io/darlean.go/base/actionerror"
 	"github.com/darlean-io/darlean.go/base/invoker"
 	"github.com/darlean-io/darlean.go/base/services/actorregistry"
 )
 
+// Obtain fetches the actor info from the registry service on one of the hosts. Hosts are tried
+// in order. Returns an error when none of the hosts provided the info.
 func Obtain(inv invoke.TransportInvoker, hosts []string) (*ObtainResponse, error) {
+	err := ErrNoHostsAvailable
 	for _, host := range hosts {
-		req := invoke.TransportHandlerInvokeRequest{
-			Receiver: host,
-			Request: invoker.Request{
-				ActorType:  SERVICE,
-				ActorId:    []string{},
-				ActionName: ACTION_OBTAIN,
-				Parameters: []any{ObtainRequest{}},
-			},
+		var value *ObtainResponse
+		value, err = obtainFromHost(inv, host)
+		if err == nil {
+			return value, nil
 		}
-		resp := inv.Invoke(&req)
-		if resp.Value != nil {
-			var value ObtainResponse
-			err := variant.Assign(resp.Value, &value)
-			if err != nil {
-				return nil, err
-			}
-			return &value, nil
+	}
+	return nil, err
+}
+
+func obtainFromHost(inv invoke.TransportInvoker, host string) (*ObtainResponse, error) {
+	req := invoke.TransportHandlerInvokeRequest{
+		Receiver: host,
+		Request: invoker.Request{
+			ActorType:  SERVICE,
+			ActorId:    []string{},
+			ActionName: ACTION_OBTAIN,
+			Parameters: []any{ObtainRequest{}},
+		},
+	}
+	resp := inv.Invoke(&req)
+	if resp.Error != nil {
+		return nil, toError(resp.Error)
+	}
+	if resp.Value == nil {
+		return nil, fmt.Errorf("remoteactorregistry: no actor info received from %s", host)
+	}
+	var value ObtainResponse
+	err := variant.Assign(resp.Value, &value)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// Subscribe asks the registry service on one of the hosts to notify the subscriber actor
+// of appId whenever the nonce changes. Returns an error when none of the hosts accepted
+// the subscription (for example, because they do not support subscriptions).
+func Subscribe(inv invoke.TransportInvoker, hosts []string, appId string) (*SubscribeResponse, error) {
+	err := ErrNoHostsAvailable
+	for _, host := range hosts {
+		var value *SubscribeResponse
+		value, err = subscribeAtHost(inv, host, appId)
+		if err == nil {
+			return value, nil
 		}
 	}
-	return nil, nil
+	return nil, err
 }
 
-type RemoteActorRegistryFetcher struct {
-	hosts     []string
-	actors    map[string](actorregistry.ActorInfo)
-	nonce     string
-	invoker   invoke.TransportInvoker
-	mutex     *sync.RWMutex
-	stop      chan bool
-	force     chan bool
-	lastFetch time.Time
+func subscribeAtHost(inv invoke.TransportInvoker, host string, appId string) (*SubscribeResponse, error) {
+	req := invoke.TransportHandlerInvokeRequest{
+		Receiver: host,
+		Request: invoker.Request{
+			ActorType:  SERVICE,
+			ActorId:    []string{},
+			ActionName: ACTION_SUBSCRIBE,
+			Parameters: []any{SubscribeRequest{
+				Application: appId,
+				ActorType:   SUBSCRIBER,
+				ActionName:  ACTION_NOTIFY,
+			}},
+		},
+	}
+	resp := inv.Invoke(&req)
+	if resp.Error != nil {
+		return nil, toError(resp.Error)
+	}
+	var value SubscribeResponse
+	if resp.Value != nil {
+		err := variant.Assign(resp.Value, &value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &value, nil
 }
 
-func (registry *RemoteActorRegistryFetcher) fetch() {
-	info, err := Obtain(registry.invoker, registry.hosts)
+func toError(value variant.Assignable) error {
+	var e actionerror.Error
+	err := value.AssignTo(&e)
 	if err != nil {
-		return
+		return err
 	}
+	return &e
+}
+
+// Maximum time between subscribe attempts when subscribing fails, for example because the registry
+// service does not support subscriptions.
+const SUBSCRIBE_BACKOFF_MAX = 10 * time.Minute
 
-	if info == nil {
+type RemoteActorRegistryFetcher struct {
+	hosts         *hostSet
+	actors        map[string](actorregistry.ActorInfo)
+	nonce         string
+	invoker       invoke.TransportInvoker
+	mutex         *sync.RWMutex
+	stop          chan bool
+	force         chan struct{}
+	lastFetch     time.Time
+	appId         string
+	lastSubscribe time.Time
+	// Number of subsequent subscribe attempts that failed
+	subscribeFailures int
+	staleSince        time.Time
+	ready             chan struct{}
+	readyOnce         sync.Once
+}
+
+// obtain fetches the actor info from the first healthy host and administers the
+// outcome in the host health.
+func (registry *RemoteActorRegistryFetcher) obtain() (*ObtainResponse, error) {
+	err := ErrNoHostsAvailable
+	for _, host := range registry.hosts.candidates() {
+		var info *ObtainResponse
+		info, err = obtainFromHost(registry.invoker, host)
+		if err == nil {
+			registry.hosts.succeeded(host)
+			return info, nil
+		}
+		registry.hosts.failed(host)
+	}
+	return nil, err
+}
+
+func (registry *RemoteActorRegistryFetcher) setStale(stale bool) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if !stale {
+		registry.staleSince = time.Time{}
+	} else if registry.staleSince.IsZero() {
+		registry.staleSince = time.Now()
+	}
+}
+
+func (registry *RemoteActorRegistryFetcher) fetch() {
+	registry.lastFetch = time.Now()
+
+	info, err := registry.obtain()
+	if err != nil {
+		registry.setStale(true)
 		return
 	}
+	registry.setStale(false)
+	registry.readyOnce.Do(func() {
+		close(registry.ready)
+	})
 
-	if info.Nonce == registry.nonce {
+	if info.Nonce == registry.getNonce() {
 		return
 	}
 
@@ -83,8 +194,91 @@ func (registry *RemoteActorRegistryFetcher) fetch() {
 	registry.actors = newMap
 	registry.nonce = info.Nonce
 	registry.mutex.Unlock()
+}
 
-	registry.lastFetch = time.Now()
+func (registry *RemoteActorRegistryFetcher) subscribe(interval time.Duration) {
+	if registry.appId == "" {
+		return
+	}
+	// Subscriptions are leases at the registry service. Renew them well before they expire,
+	// which is after a few poll intervals. After failures, wait longer before trying again.
+	now := time.Now()
+	if now.Sub(registry.lastSubscribe) < subscribeDelay(interval, registry.subscribeFailures) {
+		return
+	}
+	registry.lastSubscribe = now
+
+	info, err := registry.subscribeAtHosts()
+	if err != nil {
+		registry.subscribeFailures++
+		return
+	}
+	registry.subscribeFailures = 0
+
+	if info.Nonce != "" && info.Nonce != registry.getNonce() {
+		registry.fetch()
+	}
+}
+
+// subscribeAtHosts subscribes at the first healthy host that accepts the subscription and administers
+// the outcome in the host health. A host that does not support subscriptions is not counted as failed,
+// as it still serves the actor info.
+func (registry *RemoteActorRegistryFetcher) subscribeAtHosts() (*SubscribeResponse, error) {
+	err := ErrNoHostsAvailable
+	for _, host := range registry.hosts.candidates() {
+		var info *SubscribeResponse
+		info, err = subscribeAtHost(registry.invoker, host, registry.appId)
+		if err == nil {
+			registry.hosts.succeeded(host)
+			return info, nil
+		}
+		if !isUnsupported(err) {
+			registry.hosts.failed(host)
+		}
+	}
+	return nil, err
+}
+
+// isUnsupported returns whether err indicates that the registry service does not support subscriptions.
+func isUnsupported(err error) bool {
+	e, ok := err.(*actionerror.Error)
+	if !ok {
+		return false
+	}
+	return e.Code == inward.ERROR_UNKNOWN_ACTION || e.Code == inward.ERROR_ACTOR_TYPE_NOT_REGISTERED
+}
+
+// subscribeDelay returns the minimum time between subscribe attempts. It doubles for every subsequent
+// failure, up to [SUBSCRIBE_BACKOFF_MAX] (but never less than interval).
+func subscribeDelay(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < SUBSCRIBE_BACKOFF_MAX; i++ {
+		delay *= 2
+	}
+	return max(interval, min(delay, SUBSCRIBE_BACKOFF_MAX))
+}
+
+func (registry *RemoteActorRegistryFetcher) getNonce() string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return registry.nonce
+}
+
+// notify is invoked when the registry service informs us about a new nonce.
+func (registry *RemoteActorRegistryFetcher) notify(nonce string) {
+	if nonce != "" && nonce == registry.getNonce() {
+		return
+	}
+	registry.triggerFetch()
+}
+
+// triggerFetch makes the loop fetch as soon as possible. Does not block: when a fetch is already
+// pending, there is no need for another one.
+func (registry *RemoteActorRegistryFetcher) triggerFetch() {
+	select {
+	case registry.force <- struct{}{}:
+	default:
+	}
 }
 
 func (registry *RemoteActorRegistryFetcher) forceFetch() {
@@ -94,16 +288,16 @@ func (registry *RemoteActorRegistryFetcher) forceFetch() {
 	}
 }
 
-func (registry *RemoteActorRegistryFetcher) loop(stop <-chan bool, force <-chan bool, interval time.Duration) {
+func (registry *RemoteActorRegistryFetcher) loop(stop <-chan bool, force <-chan struct{}, interval time.Duration) {
 	for {
 		registry.fetch()
+		registry.subscribe(interval)
 		select {
 		case <-stop:
-			break
+			return
 		case <-force:
 			registry.forceFetch()
 		case <-time.After(interval):
-			registry.fetch()
 		}
 	}
 }
@@ -114,14 +308,62 @@ func (registry *RemoteActorRegistryFetcher) Get(actorType string) *actorregistry
 	registry.mutex.RUnlock()
 
 	if !has {
-		go func() {
-			registry.force <- true
-		}()
+		registry.triggerFetch()
 	}
 
 	return &info
 }
 
+// StaleSince returns the moment since which the fetcher was not able to obtain the actor info
+// from any of the registry hosts, or nil when the most recent fetch succeeded. Before the first
+// successful fetch, the fetcher is stale since its creation.
+func (registry *RemoteActorRegistryFetcher) StaleSince() *time.Time {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	if registry.staleSince.IsZero() {
+		return nil
+	}
+	staleSince := registry.staleSince
+	return &staleSince
+}
+
+// Ready returns a channel that is closed as soon as the first fetch succeeded. It can be
+// passed to [inward.Dispatcher.SetReadinessGate] to prevent an application from serving
+// actions before it knows where to find other actors.
+func (registry *RemoteActorRegistryFetcher) Ready() <-chan struct{} {
+	return registry.ready
+}
+
+// WaitReady waits until the first fetch succeeded or the timeout expires. Returns whether the
+// fetcher is ready.
+func (registry *RemoteActorRegistryFetcher) WaitReady(timeout time.Duration) bool {
+	select {
+	case <-registry.ready:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// HostHealth returns the health of the registry hosts as seen by the fetcher.
+func (registry *RemoteActorRegistryFetcher) HostHealth() []HostHealth {
+	return registry.hosts.health()
+}
+
+// EnableSubscription registers a private subscriber actor for appId on the dispatcher and
+// makes the fetcher subscribe to nonce changes at the registry service. Changes
+// are then picked up immediately instead of on the next poll. Polling remains active as a fallback
+// for when notifications are lost or the registry service does not support subscriptions.
+// Must be invoked before [RemoteActorRegistryFetcher.Start].
+func (registry *RemoteActorRegistryFetcher) EnableSubscription(appId string, dispatcher *inward.Dispatcher) {
+	registry.appId = appId
+	dispatcher.RegisterActorType(inward.ActorInfo{
+		ActorType: normalized.NormalizeActorType(SUBSCRIBER),
+		Container: &subscriberContainer{fetcher: registry},
+		Private:   true,
+	})
+}
+
 func (registry *RemoteActorRegistryFetcher) Start() {
 	registry.stop = make(chan bool)
 	go registry.loop(registry.stop, registry.force, 10*time.Second)
@@ -136,17 +378,19 @@ func (registry *RemoteActorRegistryFetcher) Stop() {
 }
 
 func NewFetcher(hosts []string, invoker invoke.TransportInvoker) *RemoteActorRegistryFetcher {
-	force := make(chan bool)
+	force := make(chan struct{}, 1)
 
 	var mutex sync.RWMutex
 
 	registry := RemoteActorRegistryFetcher{
-		hosts:   hosts,
-		actors:  make(map[string]actorregistry.ActorInfo),
-		nonce:   "",
-		invoker: invoker,
-		mutex:   &mutex,
-		force:   force,
+		hosts:      newHostSet(hosts),
+		actors:     make(map[string]actorregistry.ActorInfo),
+		nonce:      "",
+		invoker:    invoker,
+		mutex:      &mutex,
+		force:      force,
+		staleSince: time.Now(),
+		ready:      make(chan struct{}),
 	}
 
 	return &registry
@@ -0,0 +1,191 @@
+package remoteactorregistry
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/core/invoke"
+	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/jsonvariant"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+// toAssignable converts value into an assignable the same way it would arrive via the wire.
+func toAssignable(value any) variant.Assignable {
+	data, err := jsonbinary.Serialize(value, nil)
+	if err != nil {
+		panic(err)
+	}
+	return jsonvariant.FromJson(data)
+}
+
+// fakeRegistryService mimics the registry service for the actions used by the fetcher.
+type fakeRegistryService struct {
+	mutex       sync.Mutex
+	nonce       string
+	actorTypes  []string
+	subscribers []string
+	pushes      []PushRequest
+	down        map[string]bool
+	// When set, the service responds to subscribe requests like a service that does not support them
+	unsupported bool
+	attempts    int
+}
+
+func (service *fakeRegistryService) Invoke(req *invoke.TransportHandlerInvokeRequest) *invoker.Response {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if service.down[req.Receiver] {
+		return &invoker.Response{Error: toAssignable(map[string]any{"kind": "framework", "code": "NO_RECEIVERS_AVAILABLE"})}
+	}
+
+	switch req.ActionName {
+	case ACTION_OBTAIN:
+		info := map[string]any{}
+		for _, actorType := range service.actorTypes {
+			info[actorType] = map[string]any{
+				"applications": []any{map[string]any{"name": "app"}},
+			}
+		}
+		return &invoker.Response{Value: toAssignable(map[string]any{
+			"nonce":     service.nonce,
+			"actorInfo": info,
+		})}
+	case ACTION_PUSH:
+		var request PushRequest
+		variant.Assign(req.Parameters[0], &request)
+		service.pushes = append(service.pushes, request)
+		return &invoker.Response{}
+	case ACTION_SUBSCRIBE:
+		service.attempts++
+		if service.unsupported {
+			return &invoker.Response{Error: toAssignable(map[string]any{"kind": "framework", "code": inward.ERROR_UNKNOWN_ACTION})}
+		}
+		var request SubscribeRequest
+		variant.Assign(req.Parameters[0], &request)
+		service.subscribers = append(service.subscribers, request.Application)
+		return &invoker.Response{Value: toAssignable(map[string]any{
+			"nonce": service.nonce,
+		})}
+	}
+	return &invoker.Response{Error: toAssignable(map[string]any{"kind": "framework"})}
+}
+
+func (service *fakeRegistryService) getSubscribers() []string {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	return append([]string{}, service.subscribers...)
+}
+
+func (service *fakeRegistryService) getPushes() []PushRequest {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	return append([]PushRequest{}, service.pushes...)
+}
+
+func (service *fakeRegistryService) change(nonce string, actorTypes ...string) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.nonce = nonce
+	service.actorTypes = actorTypes
+}
+
+func TestFetcher_Notify(t *testing.T) {
+	service := fakeRegistryService{nonce: "1", actorTypes: []string{"a"}}
+	fetcher := NewFetcher([]string{"registry"}, &service)
+	dispatcher := inward.NewDispatcher(nil)
+	fetcher.EnableSubscription("client", dispatcher)
+	fetcher.Start()
+	defer fetcher.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	checks.Equal(t, 1, len(fetcher.Get("a").Applications), "Initial actor type should be known")
+	checks.Equal(t, []string{"client"}, service.getSubscribers(), "Fetcher should have subscribed")
+
+	service.change("2", "a", "b")
+	// Wait long enough for the force-fetch rate limiter to allow a new fetch
+	time.Sleep(150 * time.Millisecond)
+
+	var results []string
+	dispatcher.Dispatch(&wire.ActorCallRequestIn{
+		ActorType:  SUBSCRIBER,
+		ActionName: ACTION_NOTIFY,
+		Arguments:  []variant.Assignable{toAssignable(map[string]any{"nonce": "2"})},
+	}, func(response *wire.ActorCallResponseOut) {
+		if response.Error != nil {
+			results = append(results, "error")
+		} else {
+			results = append(results, "ok")
+		}
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	checks.Equal(t, []string{"ok"}, results, "Notification should be accepted")
+	checks.Equal(t, 1, len(fetcher.Get("b").Applications), "New actor type should be known after notification")
+}
+
+func TestFetcher_Failover(t *testing.T) {
+	service := fakeRegistryService{nonce: "1", actorTypes: []string{"a"}, down: map[string]bool{"registry1": true}}
+	fetcher := NewFetcher([]string{"registry1", "registry2"}, &service)
+	checks.Equal(t, true, fetcher.StaleSince() != nil, "Fetcher should be stale before the first fetch")
+
+	fetcher.Start()
+	defer fetcher.Stop()
+
+	checks.Equal(t, true, fetcher.WaitReady(time.Second), "Fetcher should become ready via the second host")
+	checks.Equal(t, true, fetcher.StaleSince() == nil, "Fetcher should not be stale after a successful fetch")
+	checks.Equal(t, 1, len(fetcher.Get("a").Applications), "Actor type should be known")
+
+	health := fetcher.HostHealth()
+	failures := 0
+	for _, host := range health {
+		if host.Host == "registry1" {
+			failures = host.Failures
+		}
+	}
+	checks.Equal(t, 1, failures, "Failing host should be backing off instead of being retried continuously")
+}
+
+func TestHostSet_Backoff(t *testing.T) {
+	set := newHostSet([]string{"a", "b"})
+	checks.Equal(t, 2, len(set.candidates()), "All hosts are healthy initially")
+
+	set.failed("a")
+	checks.Equal(t, []string{"b"}, set.candidates(), "Failing host should be skipped")
+
+	set.failed("b")
+	checks.Equal(t, []string{"a"}, set.candidates(), "Host that may retry first is returned when all are failing")
+
+	set.succeeded("a")
+	checks.Equal(t, []string{"a"}, set.candidates(), "Recovered host should be returned again")
+}
+
+func TestFetcher_SubscribeBackoff(t *testing.T) {
+	service := fakeRegistryService{nonce: "1", unsupported: true}
+	fetcher := NewFetcher([]string{"registry"}, &service)
+	fetcher.appId = "client"
+
+	fetcher.subscribe(time.Second)
+	fetcher.subscribe(time.Second)
+	checks.Equal(t, 1, service.attempts, "Failed subscribe should not be retried immediately")
+	checks.Equal(t, 0, fetcher.HostHealth()[0].Failures, "Unsupported subscribe should not count as host failure")
+	checks.Equal(t, 2*time.Second, subscribeDelay(time.Second, fetcher.subscribeFailures), "Delay should increase after a failure")
+
+	fetcher.lastSubscribe = time.Time{}
+	service.down = map[string]bool{"registry": true}
+	fetcher.subscribe(time.Second)
+	checks.Equal(t, 1, fetcher.HostHealth()[0].Failures, "Unreachable host should count as host failure")
+
+	fetcher.lastSubscribe = time.Time{}
+	service.down = nil
+	service.unsupported = false
+	fetcher.subscribe(time.Second)
+	checks.Equal(t, 0, fetcher.subscribeFailures, "Successful subscribe should reset the backoff")
+	checks.Equal(t, SUBSCRIBE_BACKOFF_MAX, subscribeDelay(time.Second, 20), "Delay should be capped")
+}
@@ -1,6 +1,7 @@
 package remoteactorregistry
 
 import (
+	"sync"
 	"time"
 
 	"github.com/darlean-io/darlean.go/core/invoke"
@@ -26,13 +27,15 @@ func Push(inv invoke.TransportInvoker, hosts []string, request PushRequest) erro
 			return nil
 		}
 	}
-	return nil
+	return ErrNoHostsAvailable
 }
 
 type RemoteActorRegistryPusher struct {
 	appId    string
 	hosts    []string
 	info     map[string]ActorPushInfo
+	extra    map[string]ActorPushInfo
+	mutex    sync.Mutex
 	invoker  invoke.TransportInvoker
 	stop     chan bool
 	force    chan bool
@@ -42,13 +45,27 @@ type RemoteActorRegistryPusher struct {
 func (registry *RemoteActorRegistryPusher) push() {
 	registry.lastPush = time.Now()
 
-	if registry.info == nil {
+	registry.mutex.Lock()
+	info := registry.info
+	extra := registry.extra
+	registry.mutex.Unlock()
+	if info == nil && extra == nil {
 		return
 	}
+	if extra != nil {
+		merged := map[string]ActorPushInfo{}
+		for key, value := range info {
+			merged[key] = value
+		}
+		for key, value := range extra {
+			merged[key] = value
+		}
+		info = merged
+	}
 
 	Push(registry.invoker, registry.hosts, PushRequest{
 		Application: registry.appId,
-		ActorInfo:   registry.info,
+		ActorInfo:   info,
 	})
 }
 
@@ -64,26 +81,55 @@ func (registry *RemoteActorRegistryPusher) loop(stop <-chan bool, force <-chan b
 		registry.push()
 		select {
 		case <-stop:
-			break
+			return
 		case <-force:
 			registry.forcePush()
 		case <-time.After(interval):
-			registry.push()
 		}
 	}
 }
 
+// Set replaces the info that is pushed and makes the loop push it as soon as possible. The info is
+// replaced as a whole and never modified afterwards, so that a push in progress can keep using the old info.
 func (registry *RemoteActorRegistryPusher) Set(info map[string]actorregistry.ActorPushInfo) {
-	registry.info = map[string]ActorPushInfo{}
+	pushInfo := map[string]ActorPushInfo{}
 	for key, value := range info {
-		registry.info[key] = ActorPushInfo{
+		pushInfo[key] = ActorPushInfo{
 			Placement:        ActorPlacement(value.Placement),
 			MigrationVersion: value.MigrationVersion,
 		}
 	}
-	go func() {
-		registry.force <- true
-	}()
+	registry.mutex.Lock()
+	registry.info = pushInfo
+	registry.mutex.Unlock()
+	registry.triggerPush()
+}
+
+// Publish adds info for actorType to every push, in addition to the info that is provided via [Set].
+// Intended for pseudo actor types via which an application publishes information about itself to
+// other applications, like the address on which it can be reached.
+func (registry *RemoteActorRegistryPusher) Publish(actorType string, info actorregistry.ActorPushInfo) {
+	registry.mutex.Lock()
+	extra := map[string]ActorPushInfo{}
+	for key, value := range registry.extra {
+		extra[key] = value
+	}
+	extra[actorType] = ActorPushInfo{
+		Placement:        ActorPlacement(info.Placement),
+		MigrationVersion: info.MigrationVersion,
+	}
+	registry.extra = extra
+	registry.mutex.Unlock()
+	registry.triggerPush()
+}
+
+// triggerPush makes the loop push as soon as possible. Does not block: when a push is already
+// pending (or the loop is not running), there is no need for another one.
+func (registry *RemoteActorRegistryPusher) triggerPush() {
+	select {
+	case registry.force <- true:
+	default:
+	}
 }
 
 func (registry *RemoteActorRegistryPusher) Start() {
@@ -99,8 +145,23 @@ func (registry *RemoteActorRegistryPusher) Stop() {
 	}
 }
 
+// Unregister stops the periodic pushing and informs the registry service that this application
+// no longer hosts any actor types, so that other applications stop sending calls to us.
+func (registry *RemoteActorRegistryPusher) Unregister() error {
+	registry.Stop()
+	info := map[string]ActorPushInfo{}
+	registry.mutex.Lock()
+	registry.info = info
+	registry.extra = nil
+	registry.mutex.Unlock()
+	return Push(registry.invoker, registry.hosts, PushRequest{
+		Application: registry.appId,
+		ActorInfo:   info,
+	})
+}
+
 func NewPusher(hosts []string, appId string, invoker invoke.TransportInvoker) *RemoteActorRegistryPusher {
-	force := make(chan bool)
+	force := make(chan bool, 1)
 
 	registry := RemoteActorRegistryPusher{
 		hosts:   hosts,
@@ -0,0 +1,54 @@
+package remoteactorregistry
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func TestPusher(t *testing.T) {
+	service := &fakeRegistryService{}
+	pusher := NewPusher([]string{"registry"}, "app", service)
+	pusher.Start()
+
+	// Set while the loop is pushing
+	for i := 0; i < 100; i++ {
+		pusher.Set(map[string]actorregistry.ActorPushInfo{fmt.Sprintf("actor%d", i): {}})
+	}
+	time.Sleep(200 * time.Millisecond)
+	pushes := service.getPushes()
+	checks.Equal(t, true, len(pushes) >= 2, "Pushed initially and after Set")
+	_, has := pushes[len(pushes)-1].ActorInfo["actor99"]
+	checks.Equal(t, true, has, "Most recent info is pushed")
+
+	checks.Equal(t, nil, pusher.Unregister(), "Unregister")
+	checks.Equal(t, 0, len(service.getPushes()[len(service.getPushes())-1].ActorInfo), "Unregister pushes empty info")
+
+	// Set after the loop is stopped does not leave goroutines behind
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		pusher.Set(map[string]actorregistry.ActorPushInfo{"actor": {}})
+	}
+	checks.Equal(t, true, runtime.NumGoroutine() <= before, "No goroutine per Set")
+}
+
+func TestPusher_Publish(t *testing.T) {
+	service := &fakeRegistryService{}
+	pusher := NewPusher([]string{"registry"}, "app", service)
+	pusher.Publish("address", actorregistry.ActorPushInfo{MigrationVersion: "127.0.0.1:1234"})
+	pusher.Set(map[string]actorregistry.ActorPushInfo{"actor": {}})
+	pusher.Start()
+	time.Sleep(50 * time.Millisecond)
+	pusher.Stop()
+
+	pushes := service.getPushes()
+	checks.Equal(t, true, len(pushes) >= 1, "Pushed")
+	last := pushes[len(pushes)-1]
+	checks.Equal(t, "127.0.0.1:1234", last.ActorInfo["address"].MigrationVersion, "Published info is pushed")
+	_, has := last.ActorInfo["actor"]
+	checks.Equal(t, true, has, "Info from Set is pushed as well")
+}
@@ -0,0 +1,40 @@
+package remoteactorregistry
+
+import (
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/core/wire"
+)
+
+// subscriberContainer receives the nonce change notifications from the registry service
+// and forwards them to the fetcher. Satisfies [inward.ActorContainer].
+type subscriberContainer struct {
+	fetcher *RemoteActorRegistryFetcher
+}
+
+func (container *subscriberContainer) Dispatch(call *wire.ActorCallRequestIn, onFinished inward.FinishedHandler) {
+	if normalized.NormalizeActionName(call.ActionName) != normalized.NormalizeActionName(ACTION_NOTIFY) {
+		onFinished(nil, frameworkerror.New(actionerror.Options{
+			Code:     inward.ERROR_UNKNOWN_ACTION,
+			Template: "Unknown action [Action] on actor [ActorType]",
+			Parameters: map[string]any{
+				"Action":    call.ActionName,
+				"ActorType": call.ActorType,
+			},
+		}))
+		return
+	}
+
+	var request NotifyRequest
+	if len(call.Arguments) > 0 && call.Arguments[0] != nil {
+		err := call.Arguments[0].AssignTo(&request)
+		if err != nil {
+			onFinished(nil, frameworkerror.FromError(err))
+			return
+		}
+	}
+	container.fetcher.notify(request.Nonce)
+	onFinished(nil, nil)
+}
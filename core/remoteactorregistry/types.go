@@ -35,5 +35,24 @@ type PushRequest struct {
 	ActorInfo   map[string]ActorPushInfo `json:"actorInfo"`
 }
 
+type SubscribeRequest struct {
+	Application string `json:"application"`
+	ActorType   string `json:"actorType"`
+	ActionName  string `json:"actionName"`
+}
+
+type SubscribeResponse struct {
+	Nonce string `json:"nonce"`
+}
+
+type NotifyRequest struct {
+	Nonce string `json:"nonce"`
+}
+
 const ACTION_OBTAIN = "obtain"
 const ACTION_PUSH = "push"
+const ACTION_SUBSCRIBE = "subscribe"
+
+// Actor type and action name on which the registry service notifies subscribers about nonce changes.
+const SUBSCRIBER = "io.darlean.actorregistrysubscriber"
+const ACTION_NOTIFY = "notify"
@@ -0,0 +1,81 @@
+/*
+Package shutdown orchestrates the graceful shutdown of an application.
+
+A graceful shutdown consists of the following steps, performed in order:
+ 1. Unregister the application from the actor registry, so that other applications stop sending new calls.
+ 2. Reject new calls with a refusal framework error, so that callers retry them on other applications.
+ 3. Wait (until a deadline) for the calls that are already in flight to finish.
+ 4. Deactivate all actor instances by stopping the actor containers.
+ 5. Stop the remaining components (like the registry fetcher) and close the transport.
+*/
+package shutdown
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/darlean-io/darlean.go/core/inward"
+)
+
+// Unregisterer is satisfied by registry pushers that can remove the application from the registry.
+type Unregisterer interface {
+	Unregister() error
+}
+
+// Stopper is satisfied by components that must be stopped as part of the shutdown.
+type Stopper interface {
+	Stop()
+}
+
+// Closer is satisfied by transports that can report errors when being stopped.
+type Closer interface {
+	Stop() error
+}
+
+type Options struct {
+	// Pusher is used to unregister the application. Optional.
+	Pusher Unregisterer
+	// Dispatcher that must be drained. Optional.
+	Dispatcher *inward.Dispatcher
+	// Components that are stopped after the dispatcher is drained, in order. Optional.
+	Components []Stopper
+	// Transport that is closed as final step. Optional.
+	Transport Closer
+	// Timeout is the maximum amount of time to wait for in-flight calls to finish.
+	Timeout time.Duration
+}
+
+// Graceful performs an orchestrated shutdown as described in the package documentation. All steps
+// are always performed; errors that occur along the way are combined into the returned error.
+func Graceful(options Options) error {
+	var errs []error
+
+	if options.Pusher != nil {
+		err := options.Pusher.Unregister()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shutdown: unregister: %w", err))
+		}
+	}
+
+	if options.Dispatcher != nil {
+		remaining := options.Dispatcher.WaitIdle(options.Timeout)
+		if remaining > 0 {
+			errs = append(errs, fmt.Errorf("shutdown: %d calls still in flight after %v", remaining, options.Timeout))
+		}
+		options.Dispatcher.StopContainers()
+	}
+
+	for _, component := range options.Components {
+		component.Stop()
+	}
+
+	if options.Transport != nil {
+		err := options.Transport.Stop()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shutdown: transport: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package staticactorregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the structure of a placement file. Example (JSON):
+//
+//	{
+//	  "actors": {
+//	    "EchoActor": {
+//	      "applications": [{"name": "server"}, {"name": "client", "migrationVersion": "2"}],
+//	      "placement": {"appBindIdx": -1, "sticky": true}
+//	    }
+//	  }
+//	}
+//
+// The same structure can be expressed in YAML when the file has a `.yaml` or `.yml` extension.
+type File struct {
+	Actors map[string]FileActorInfo `json:"actors" yaml:"actors"`
+}
+
+type FileApplicationInfo struct {
+	Name             string  `json:"name" yaml:"name"`
+	MigrationVersion *string `json:"migrationVersion" yaml:"migrationVersion"`
+}
+
+type FileActorPlacement struct {
+	AppBindIdx *int  `json:"appBindIdx" yaml:"appBindIdx"`
+	Sticky     *bool `json:"sticky" yaml:"sticky"`
+}
+
+type FileActorInfo struct {
+	Applications []FileApplicationInfo `json:"applications" yaml:"applications"`
+	Placement    FileActorPlacement    `json:"placement" yaml:"placement"`
+}
+
+// FileActorRegistryFetcher returns actor info from a JSON or YAML file. The file is watched
+// for changes and reloaded while the fetcher is started. Satisfies [actorregistry.ActorRegistryFetcher].
+type FileActorRegistryFetcher struct {
+	*StaticActorRegistryFetcher
+	path     string
+	modTime  time.Time
+	size     int64
+	lastErr  error
+	errMutex sync.Mutex
+	stop     chan bool
+}
+
+// NewFileFetcher returns a fetcher that reads the placements from the file at path. Returns an error
+// when the file cannot be loaded initially.
+func NewFileFetcher(path string) (*FileActorRegistryFetcher, error) {
+	fetcher := FileActorRegistryFetcher{
+		StaticActorRegistryFetcher: NewFetcher(nil),
+		path:                       path,
+	}
+	err := fetcher.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return &fetcher, nil
+}
+
+// Reload reads the file and replaces the actor info. When the file is invalid, the
+// previous actor info is retained and the error is returned.
+func (fetcher *FileActorRegistryFetcher) Reload() error {
+	stat, err := os.Stat(fetcher.path)
+	if err == nil {
+		fetcher.modTime = stat.ModTime()
+		fetcher.size = stat.Size()
+		var actors map[string]actorregistry.ActorInfo
+		actors, err = Load(fetcher.path)
+		if err == nil {
+			fetcher.Set(actors)
+		}
+	}
+	fetcher.errMutex.Lock()
+	fetcher.lastErr = err
+	fetcher.errMutex.Unlock()
+	return err
+}
+
+// LastError returns the error of the most recent (re)load, or nil when it succeeded.
+func (fetcher *FileActorRegistryFetcher) LastError() error {
+	fetcher.errMutex.Lock()
+	defer fetcher.errMutex.Unlock()
+	return fetcher.lastErr
+}
+
+func (fetcher *FileActorRegistryFetcher) changed() bool {
+	stat, err := os.Stat(fetcher.path)
+	if err != nil {
+		return false
+	}
+	return !stat.ModTime().Equal(fetcher.modTime) || stat.Size() != fetcher.size
+}
+
+func (fetcher *FileActorRegistryFetcher) loop(stop <-chan bool, interval time.Duration) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+			if fetcher.changed() {
+				fetcher.Reload()
+			}
+		}
+	}
+}
+
+// Start starts watching the file for changes. Changes are checked for every interval.
+func (fetcher *FileActorRegistryFetcher) Start(interval time.Duration) {
+	fetcher.stop = make(chan bool)
+	go fetcher.loop(fetcher.stop, interval)
+}
+
+func (fetcher *FileActorRegistryFetcher) Stop() {
+	if fetcher.stop != nil {
+		stop := fetcher.stop
+		fetcher.stop = nil
+		stop <- true
+	}
+}
+
+// Load reads a placement file and returns the contained actor info. The file is parsed
+// as YAML when the extension is `.yaml` or `.yml`, and as JSON otherwise.
+func Load(path string) (map[string]actorregistry.ActorInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("staticactorregistry: unable to parse %s: %w", path, err)
+	}
+
+	actors := make(map[string]actorregistry.ActorInfo, len(file.Actors))
+	for key, value := range file.Actors {
+		applications := make([]actorregistry.ApplicationInfo, len(value.Applications))
+		for i, app := range value.Applications {
+			applications[i] = actorregistry.ApplicationInfo{
+				Name:             app.Name,
+				MigrationVersion: app.MigrationVersion,
+			}
+		}
+		actors[key] = actorregistry.ActorInfo{
+			Applications: applications,
+			Placement: actorregistry.ActorPlacement{
+				AppBindIdx: value.Placement.AppBindIdx,
+				Sticky:     value.Placement.Sticky,
+			},
+		}
+	}
+	return actors, nil
+}
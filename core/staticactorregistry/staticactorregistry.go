@@ -0,0 +1,48 @@
+/*
+Package staticactorregistry provides implementations of [actorregistry.ActorRegistryFetcher] for deployments
+without an actor registry service. The placement of actors is either defined in code ([NewFetcher]) or
+in a JSON or YAML file that is reloaded when it changes ([NewFileFetcher]).
+*/
+package staticactorregistry
+
+import (
+	"sync"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/core/normalized"
+)
+
+// StaticActorRegistryFetcher returns actor info from an in-memory map. Satisfies [actorregistry.ActorRegistryFetcher].
+type StaticActorRegistryFetcher struct {
+	actors map[normalized.ActorType]actorregistry.ActorInfo
+	mutex  *sync.RWMutex
+}
+
+// NewFetcher returns a fetcher for the provided map from actor type to actor info. The actor types
+// are normalized, so "EchoActor" and "echoactor" refer to the same type.
+func NewFetcher(actors map[string]actorregistry.ActorInfo) *StaticActorRegistryFetcher {
+	var mutex sync.RWMutex
+	fetcher := StaticActorRegistryFetcher{
+		mutex: &mutex,
+	}
+	fetcher.Set(actors)
+	return &fetcher
+}
+
+func (fetcher *StaticActorRegistryFetcher) Get(actorType string) *actorregistry.ActorInfo {
+	fetcher.mutex.RLock()
+	info := fetcher.actors[normalized.NormalizeActorType(actorType)]
+	fetcher.mutex.RUnlock()
+	return &info
+}
+
+// Set replaces all actor info of the fetcher.
+func (fetcher *StaticActorRegistryFetcher) Set(actors map[string]actorregistry.ActorInfo) {
+	newMap := make(map[normalized.ActorType]actorregistry.ActorInfo, len(actors))
+	for key, value := range actors {
+		newMap[normalized.NormalizeActorType(key)] = value
+	}
+	fetcher.mutex.Lock()
+	fetcher.actors = newMap
+	fetcher.mutex.Unlock()
+}
@@ -0,0 +1,92 @@
+package staticactorregistry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func TestStaticFetcher(t *testing.T) {
+	bindIdx := -1
+	fetcher := NewFetcher(map[string]actorregistry.ActorInfo{
+		"EchoActor": {
+			Applications: []actorregistry.ApplicationInfo{{Name: "server"}},
+			Placement:    actorregistry.ActorPlacement{AppBindIdx: &bindIdx},
+		},
+	})
+
+	info := fetcher.Get("echoactor")
+	checks.Equal(t, "server", info.Applications[0].Name, "Application")
+	checks.Equal(t, -1, *info.Placement.AppBindIdx, "AppBindIdx")
+	checks.Equal(t, 0, len(fetcher.Get("unknown").Applications), "Unknown actor type")
+}
+
+func TestFileFetcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "placement.yaml")
+	err := os.WriteFile(path, []byte(`
+actors:
+  EchoActor:
+    applications:
+      - name: server
+    placement:
+      sticky: true
+`), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fetcher, err := NewFileFetcher(path)
+	if err != nil {
+		panic(err)
+	}
+	fetcher.Start(10 * time.Millisecond)
+	defer fetcher.Stop()
+
+	info := fetcher.Get("EchoActor")
+	checks.Equal(t, "server", info.Applications[0].Name, "Application")
+	checks.Equal(t, true, *info.Placement.Sticky, "Sticky")
+
+	err = os.WriteFile(path, []byte(`
+actors:
+  EchoActor:
+    applications:
+      - name: server
+      - name: client
+        migrationVersion: "2"
+`), 0644)
+	if err != nil {
+		panic(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	info = fetcher.Get("EchoActor")
+	checks.Equal(t, 2, len(info.Applications), "Applications after reload")
+	checks.Equal(t, "2", *info.Applications[1].MigrationVersion, "Migration version after reload")
+
+	err = os.WriteFile(path, []byte("actors: [invalid"), 0644)
+	if err != nil {
+		panic(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	checks.IsNotNil(t, fetcher.LastError(), "Invalid file should result in an error")
+	checks.Equal(t, 2, len(fetcher.Get("EchoActor").Applications), "Previous placements are retained for invalid file")
+}
+
+func TestLoad_Json(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "placement.json")
+	err := os.WriteFile(path, []byte(`{"actors": {"EchoActor": {"applications": [{"name": "server"}], "placement": {"appBindIdx": 0}}}}`), 0644)
+	if err != nil {
+		panic(err)
+	}
+	actors, err := Load(path)
+	if err != nil {
+		panic(err)
+	}
+	checks.Equal(t, "server", actors["EchoActor"].Applications[0].Name, "Application")
+	checks.Equal(t, 0, *actors["EchoActor"].Placement.AppBindIdx, "AppBindIdx")
+}
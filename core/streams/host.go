@@ -0,0 +1,197 @@
+/*
+Package streams transfers large binary data between actors as a sequence of wire messages, so that the data
+does not have to fit in one message.
+
+The application that produces the data hosts the stream: it creates a [Writer] via [Host.NewWriter] and
+passes the [Handle] of the stream to the consumer, either as result of an action or as argument of an action
+it invokes. The consumer creates a [Reader] for the handle, which pulls the chunks from the host by invoking
+actions on the [SERVICE] actor type. Pulling provides flow control: the writer blocks when the reader falls
+behind. Either side can cancel the stream by closing its end.
+*/
+package streams
+
+import (
+	"sync"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/core/internal/frameworkerror"
+	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/normalized"
+	"github.com/darlean-io/darlean.go/core/wire"
+
+	"github.com/google/uuid"
+)
+
+// Host hosts the streams that are written by an application. Satisfies [inward.ActorContainer].
+type Host struct {
+	appId       string
+	chunkSize   int
+	window      int
+	idleTimeout time.Duration
+	streams     map[string]*Writer
+	stopped     bool
+	mutex       sync.Mutex
+}
+
+func NewHost(appId string) *Host {
+	return &Host{
+		appId:       appId,
+		chunkSize:   DEFAULT_CHUNK_SIZE,
+		window:      DEFAULT_WINDOW,
+		idleTimeout: DEFAULT_IDLE_TIMEOUT,
+		streams:     make(map[string]*Writer),
+	}
+}
+
+// SetChunkSize sets the maximum size of a chunk for writers that are created afterwards.
+func (host *Host) SetChunkSize(size int) {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	host.chunkSize = size
+}
+
+// SetWindow sets the number of chunks writers that are created afterwards can be ahead of their reader.
+func (host *Host) SetWindow(window int) {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	host.window = window
+}
+
+// SetIdleTimeout sets how long streams that are created afterwards may go without being read before they
+// are cancelled.
+func (host *Host) SetIdleTimeout(timeout time.Duration) {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	host.idleTimeout = timeout
+}
+
+// ActorInfo returns the registration of the host for [inward.Dispatcher.RegisterActorType]. Calls are
+// routed to the application that is the first part of the actor id.
+func (host *Host) ActorInfo() inward.ActorInfo {
+	appBindIdx := 0
+	return inward.ActorInfo{
+		ActorType: normalized.NormalizeActorType(SERVICE),
+		Container: host,
+		Placement: actorregistry.ActorPlacement{
+			AppBindIdx: &appBindIdx,
+		},
+	}
+}
+
+// NewWriter creates a new stream and returns its handle and writer.
+func (host *Host) NewWriter() (Handle, *Writer) {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+
+	writer := &Writer{
+		host:        host,
+		id:          uuid.NewString(),
+		chunkSize:   host.chunkSize,
+		idleTimeout: host.idleTimeout,
+		chunks:      make(chan []byte, host.window),
+		cancelled:   make(chan struct{}),
+	}
+	if host.stopped {
+		writer.cancelOnce.Do(func() { close(writer.cancelled) })
+	} else {
+		host.streams[writer.id] = writer
+		writer.idle = time.AfterFunc(writer.idleTimeout, writer.cancel)
+	}
+	return Handle{App: host.appId, Id: writer.id}, writer
+}
+
+func (host *Host) get(id string) *Writer {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	return host.streams[id]
+}
+
+func (host *Host) remove(id string) {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	delete(host.streams, id)
+}
+
+// Dispatch handles the read and cancel actions. Reads can wait for data, so every call is handled
+// in its own goroutine.
+func (host *Host) Dispatch(call *wire.ActorCallRequestIn, onFinished inward.FinishedHandler) {
+	go func() {
+		result, err := host.perform(call)
+		onFinished(result, err)
+	}()
+}
+
+func (host *Host) perform(call *wire.ActorCallRequestIn) (any, *actionerror.Error) {
+	if len(call.Arguments) != 1 {
+		return nil, frameworkerror.New(actionerror.Options{
+			Code:     "INVALID_ARGUMENTS",
+			Template: "Stream actions require exactly one argument",
+		})
+	}
+
+	switch normalized.NormalizeActionName(call.ActionName) {
+	case normalized.NormalizeActionName(ACTION_READ):
+		var request ReadRequest
+		err := call.Arguments[0].AssignTo(&request)
+		if err != nil {
+			return nil, actionerror.FromError(err)
+		}
+		writer := host.get(request.Id)
+		if writer == nil {
+			return nil, unknownStream(request.Id)
+		}
+		response, err := writer.read(request.Sequence, READ_WAIT)
+		if err == ErrCancelled {
+			return nil, unknownStream(request.Id)
+		}
+		if err != nil {
+			return nil, frameworkerror.New(actionerror.Options{
+				Code:       ERROR_INVALID_SEQUENCE,
+				Template:   "Chunk [Sequence] of stream [Id] is not available",
+				Parameters: map[string]any{"Id": request.Id, "Sequence": request.Sequence},
+			})
+		}
+		return response, nil
+	case normalized.NormalizeActionName(ACTION_CANCEL):
+		var request CancelRequest
+		err := call.Arguments[0].AssignTo(&request)
+		if err != nil {
+			return nil, actionerror.FromError(err)
+		}
+		writer := host.get(request.Id)
+		if writer != nil {
+			writer.cancel()
+		}
+		return nil, nil
+	}
+	return nil, frameworkerror.New(actionerror.Options{
+		Code:       inward.ERROR_UNKNOWN_ACTION,
+		Template:   "Action [ActionName] is not supported by streams",
+		Parameters: map[string]any{"ActionName": call.ActionName},
+	})
+}
+
+func unknownStream(id string) *actionerror.Error {
+	return frameworkerror.New(actionerror.Options{
+		Code:       ERROR_UNKNOWN_STREAM,
+		Template:   "Stream [Id] does not exist or is cancelled",
+		Parameters: map[string]any{"Id": id},
+	})
+}
+
+// Stop cancels all streams.
+func (host *Host) Stop() {
+	host.mutex.Lock()
+	host.stopped = true
+	writers := make([]*Writer, 0, len(host.streams))
+	for _, writer := range host.streams {
+		writers = append(writers, writer)
+	}
+	host.mutex.Unlock()
+
+	for _, writer := range writers {
+		writer.cancel()
+	}
+}
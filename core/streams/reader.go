@@ -0,0 +1,98 @@
+package streams
+
+import (
+	"errors"
+	"io"
+
+	"github.com/darlean-io/darlean.go/base/invoker"
+)
+
+// Reader is the consuming side of a stream. Pulls the chunks of the stream with handle from the
+// application that hosts it.
+type Reader struct {
+	invoker  invoker.Invoker
+	handle   Handle
+	sequence int64
+	current  []byte
+	eof      bool
+	err      error
+}
+
+func NewReader(inv invoker.Invoker, handle Handle) *Reader {
+	return &Reader{
+		invoker: inv,
+		handle:  handle,
+	}
+}
+
+// Read reads data from the stream. Returns io.EOF at the end of the stream, or the error with which the
+// writer closed the stream.
+func (reader *Reader) Read(p []byte) (int, error) {
+	for len(reader.current) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		reader.fetch()
+	}
+	n := copy(p, reader.current)
+	reader.current = reader.current[n:]
+	return n, nil
+}
+
+// fetch pulls the next chunk. Asks again for the same chunk when none was available in time.
+func (reader *Reader) fetch() {
+	sequence := reader.sequence + 1
+	result, err := reader.invoker.Invoke(&invoker.Request{
+		ActorType:  SERVICE,
+		ActorId:    []string{reader.handle.App, reader.handle.Id},
+		ActionName: ACTION_READ,
+		Parameters: []any{ReadRequest{Id: reader.handle.Id, Sequence: sequence}},
+	})
+	if err != nil {
+		reader.err = err
+		return
+	}
+
+	var response ReadResponse
+	if result != nil {
+		e := result.AssignTo(&response)
+		if e != nil {
+			reader.err = e
+			return
+		}
+	}
+	if response.Sequence == 0 {
+		return
+	}
+	reader.sequence = sequence
+	reader.current = response.Data.Bytes()
+	if response.Eof {
+		reader.eof = true
+		reader.err = io.EOF
+		if response.Error != "" {
+			reader.err = errors.New(response.Error)
+		}
+	}
+}
+
+// Close cancels the stream when it is not yet completely read, so that the writer stops writing.
+func (reader *Reader) Close() error {
+	if reader.eof {
+		return nil
+	}
+	reader.eof = true
+	reader.current = nil
+	if reader.err == nil {
+		reader.err = ErrCancelled
+	}
+	_, err := reader.invoker.Invoke(&invoker.Request{
+		ActorType:  SERVICE,
+		ActorId:    []string{reader.handle.App, reader.handle.Id},
+		ActionName: ACTION_CANCEL,
+		Parameters: []any{CancelRequest{Id: reader.handle.Id}},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
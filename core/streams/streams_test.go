@@ -0,0 +1,220 @@
+package streams
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/actionerror"
+	"github.com/darlean-io/darlean.go/base/invoker"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/jsonbinary"
+	"github.com/darlean-io/darlean.go/utils/jsonvariant"
+	"github.com/darlean-io/darlean.go/utils/variant"
+)
+
+// wireInvoker passes requests through the wire format to the host of the application in the actor id.
+type wireInvoker struct {
+	hosts map[string]*Host
+	calls atomic.Int64
+}
+
+func (inv *wireInvoker) Invoke(request *invoker.Request) (variant.Assignable, *actionerror.Error) {
+	inv.calls.Add(1)
+	tags := wire.TagsOut{}
+	tags.ActorType = request.ActorType
+	tags.ActorId = request.ActorId
+	tags.ActionName = request.ActionName
+	tags.Arguments = request.Parameters
+	var buf bytes.Buffer
+	err := wire.Serialize(&buf, tags)
+	if err != nil {
+		panic(err)
+	}
+	var tagsIn wire.TagsIn
+	err = wire.Deserialize(&buf, &tagsIn)
+	if err != nil {
+		panic(err)
+	}
+
+	done := make(chan struct{})
+	var result variant.Assignable
+	var resultErr *actionerror.Error
+	inv.hosts[request.ActorId[0]].Dispatch(&tagsIn.ActorCallRequestIn, func(value any, err *actionerror.Error) {
+		if value != nil {
+			data, _ := jsonbinary.Serialize(value, nil)
+			result = jsonvariant.FromJson(data)
+		}
+		resultErr = err
+		close(done)
+	})
+	<-done
+	return result, resultErr
+}
+
+func newTest() (*Host, *wireInvoker) {
+	host := NewHost("producer")
+	host.SetChunkSize(1000)
+	host.SetWindow(2)
+	return host, &wireInvoker{hosts: map[string]*Host{"producer": host}}
+}
+
+func TestStream(t *testing.T) {
+	host, inv := newTest()
+	data := make([]byte, 100500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	handle, writer := host.NewWriter()
+	go func() {
+		writer.Write(data[:500])
+		writer.Write(data[500:])
+		writer.Close()
+	}()
+
+	received, err := io.ReadAll(NewReader(inv, handle))
+	checks.Equal(t, nil, err, "Read all")
+	checks.Equal(t, data, received, "Received data")
+	checks.Equal(t, int64(102), inv.calls.Load(), "One call per chunk plus end of stream")
+}
+
+func TestFlowControl(t *testing.T) {
+	host, inv := newTest()
+	handle, writer := host.NewWriter()
+
+	var written atomic.Int64
+	go func() {
+		for i := 0; i < 10; i++ {
+			writer.Write(make([]byte, 1000))
+			written.Add(1)
+		}
+		writer.Close()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	checks.Equal(t, int64(2), written.Load(), "Writer blocks when the window is full")
+
+	reader := NewReader(inv, handle)
+	reader.Read(make([]byte, 1000))
+	time.Sleep(50 * time.Millisecond)
+	checks.Equal(t, int64(3), written.Load(), "Writer continues after a chunk is read")
+
+	io.Copy(io.Discard, reader)
+	checks.Equal(t, int64(10), written.Load(), "All chunks written")
+}
+
+func TestCancel(t *testing.T) {
+	host, inv := newTest()
+	handle, writer := host.NewWriter()
+
+	result := make(chan error)
+	go func() {
+		for {
+			_, err := writer.Write(make([]byte, 1000))
+			if err != nil {
+				result <- err
+				return
+			}
+		}
+	}()
+
+	reader := NewReader(inv, handle)
+	reader.Read(make([]byte, 10))
+	err := reader.Close()
+	checks.Equal(t, nil, err, "Close reader")
+	checks.Equal(t, ErrCancelled, <-result, "Writer is cancelled")
+	checks.Equal(t, true, host.get(handle.Id) == nil, "Stream is removed")
+
+	_, err = reader.Read(make([]byte, 10))
+	checks.Equal(t, ErrCancelled, err, "Read after close")
+}
+
+func TestCloseWithError(t *testing.T) {
+	host, inv := newTest()
+	handle, writer := host.NewWriter()
+	go func() {
+		writer.Write([]byte("partial"))
+		writer.CloseWithError(errors.New("disk failure"))
+	}()
+
+	received, err := io.ReadAll(NewReader(inv, handle))
+	checks.Equal(t, "partial", string(received), "Data before the error")
+	checks.Equal(t, "disk failure", err.Error(), "Error of the writer")
+}
+
+func TestRetransmit(t *testing.T) {
+	host, _ := newTest()
+	_, writer := host.NewWriter()
+	go func() {
+		writer.Write([]byte("one"))
+		writer.Flush()
+		writer.Write([]byte("two"))
+		writer.Close()
+	}()
+
+	first, _ := writer.read(1, time.Second)
+	again, _ := writer.read(1, time.Second)
+	checks.Equal(t, "one", string(first.Data.Bytes()), "First chunk")
+	checks.Equal(t, "one", string(again.Data.Bytes()), "Retransmitted chunk")
+	_, err := writer.read(3, time.Second)
+	checks.IsNotNil(t, err, "Chunk out of sequence")
+	second, _ := writer.read(2, time.Second)
+	checks.Equal(t, "two", string(second.Data.Bytes()), "Second chunk")
+}
+
+func TestIdleTimeout(t *testing.T) {
+	host, _ := newTest()
+	host.SetIdleTimeout(50 * time.Millisecond)
+	handle, writer := host.NewWriter()
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		_, err = writer.Write(make([]byte, 1000))
+	}
+	checks.Equal(t, ErrCancelled, err, "Writer without reader is cancelled")
+	checks.Equal(t, true, host.get(handle.Id) == nil, "Stream is removed")
+}
+
+func TestIdleTimeoutWithoutReader(t *testing.T) {
+	host, inv := newTest()
+	host.SetIdleTimeout(50 * time.Millisecond)
+
+	// Stays within the window, so the writer never blocks
+	handle, writer := host.NewWriter()
+	writer.Write([]byte("unread"))
+	writer.Close()
+	time.Sleep(100 * time.Millisecond)
+	checks.Equal(t, true, host.get(handle.Id) == nil, "Stream that is never read is removed")
+
+	// Reads keep the stream alive
+	handle, writer = host.NewWriter()
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(30 * time.Millisecond)
+			writer.Write([]byte("x"))
+			writer.Flush()
+		}
+		writer.Close()
+	}()
+	received, err := io.ReadAll(NewReader(inv, handle))
+	checks.Equal(t, nil, err, "Read all")
+	checks.Equal(t, "xxxxx", string(received), "Received data")
+	time.Sleep(100 * time.Millisecond)
+	checks.Equal(t, true, host.get(handle.Id) == nil, "Stream is removed after the end is read")
+}
+
+func TestFrameworkErrors(t *testing.T) {
+	_, inv := newTest()
+	_, err := inv.Invoke(&invoker.Request{ActorType: SERVICE, ActorId: []string{"producer"}, ActionName: ACTION_READ, Parameters: []any{ReadRequest{Id: "unknown", Sequence: 1}}})
+	checks.Equal(t, ERROR_UNKNOWN_STREAM, err.Code, "Code for unknown stream")
+	checks.Equal(t, actionerror.ERROR_KIND_FRAMEWORK, err.Kind, "Unknown stream is a framework error")
+
+	_, err = inv.Invoke(&invoker.Request{ActorType: SERVICE, ActorId: []string{"producer"}, ActionName: "other", Parameters: []any{nil}})
+	checks.Equal(t, "UNKNOWN_ACTION", err.Code, "Code for unknown action")
+	checks.Equal(t, actionerror.ERROR_KIND_FRAMEWORK, err.Kind, "Unknown action is a framework error")
+}
@@ -0,0 +1,62 @@
+package streams
+
+import (
+	"errors"
+	"time"
+
+	"github.com/darlean-io/darlean.go/utils/binary"
+)
+
+// SERVICE is the actor type via which streams are read. The first part of the actor id is the
+// application that hosts the stream, the second part is the id of the stream.
+const SERVICE = "io.darlean.streams"
+
+const ACTION_READ = "read"
+const ACTION_CANCEL = "cancel"
+
+const ERROR_UNKNOWN_STREAM = "UNKNOWN_STREAM"
+const ERROR_INVALID_SEQUENCE = "INVALID_SEQUENCE"
+
+// DEFAULT_CHUNK_SIZE is the maximum number of bytes per chunk (and hence per wire message).
+const DEFAULT_CHUNK_SIZE = 64 * 1024
+
+// DEFAULT_WINDOW is the number of chunks a writer can be ahead of the reader before Write blocks.
+const DEFAULT_WINDOW = 4
+
+// READ_WAIT is how long a read waits for a chunk before it returns empty-handed, so that the reader
+// asks again.
+const READ_WAIT = 5 * time.Second
+
+// DEFAULT_IDLE_TIMEOUT is how long a stream may go without being read before it is cancelled.
+const DEFAULT_IDLE_TIMEOUT = time.Minute
+
+var ErrCancelled = errors.New("streams: stream cancelled")
+
+// Handle identifies a stream. Handles can be passed as action arguments and returned as action results.
+type Handle struct {
+	App string `json:"app"`
+	Id  string `json:"id"`
+}
+
+// ReadRequest asks for chunk Sequence of a stream. Chunks are numbered from 1. Asking for chunk n
+// acknowledges chunk n-1; asking for chunk n again (for example after a lost response) returns the
+// same chunk.
+type ReadRequest struct {
+	Id       string `json:"id"`
+	Sequence int64  `json:"sequence"`
+}
+
+type ReadResponse struct {
+	// Sequence of the returned chunk. 0 when no chunk was available in time; the reader must then ask
+	// for the same chunk again.
+	Sequence int64         `json:"sequence"`
+	Data     binary.Binary `json:"data"`
+	// Eof indicates the end of the stream. Error is the message of the error with which the writer
+	// closed the stream, if any.
+	Eof   bool   `json:"eof"`
+	Error string `json:"error"`
+}
+
+type CancelRequest struct {
+	Id string `json:"id"`
+}
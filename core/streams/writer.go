@@ -0,0 +1,162 @@
+package streams
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Writer is the producing side of a stream. Data is split into chunks that the reader pulls. Write blocks
+// when the reader is [DEFAULT_WINDOW] chunks behind. Created by [Host.NewWriter].
+type Writer struct {
+	host        *Host
+	id          string
+	chunkSize   int
+	idleTimeout time.Duration
+	chunks      chan []byte
+	pending     []byte
+	closed      bool
+	err         error
+	writeLock   sync.Mutex
+
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+	// Cancels the stream when it is not read for idleTimeout
+	idle *time.Timer
+
+	// Reading state, protected by readLock
+	readLock     sync.Mutex
+	lastSequence int64
+	last         *ReadResponse
+}
+
+// Write appends p to the stream. Data is sent in chunks of the chunk size; use [Writer.Flush] to send a
+// partial chunk. Returns [ErrCancelled] when the reader cancelled the stream or did not read for too long
+// (see [Host.SetIdleTimeout]).
+func (writer *Writer) Write(p []byte) (int, error) {
+	writer.writeLock.Lock()
+	defer writer.writeLock.Unlock()
+	if writer.closed {
+		return 0, errors.New("streams: write to closed stream")
+	}
+
+	n := 0
+	for len(p) > 0 {
+		room := writer.chunkSize - len(writer.pending)
+		part := p[:min(room, len(p))]
+		writer.pending = append(writer.pending, part...)
+		p = p[len(part):]
+		if len(writer.pending) == writer.chunkSize {
+			err := writer.push()
+			if err != nil {
+				return n, err
+			}
+		}
+		n += len(part)
+	}
+	return n, nil
+}
+
+// Flush sends the data that is not yet sent as a (partial) chunk.
+func (writer *Writer) Flush() error {
+	writer.writeLock.Lock()
+	defer writer.writeLock.Unlock()
+	if len(writer.pending) == 0 {
+		return nil
+	}
+	return writer.push()
+}
+
+// Close flushes the remaining data and marks the end of the stream.
+func (writer *Writer) Close() error {
+	return writer.CloseWithError(nil)
+}
+
+// CloseWithError flushes the remaining data and marks the end of the stream. When err is not nil, the
+// reader receives err (instead of io.EOF) after the data.
+func (writer *Writer) CloseWithError(err error) error {
+	writer.writeLock.Lock()
+	defer writer.writeLock.Unlock()
+	if writer.closed {
+		return nil
+	}
+	var flushErr error
+	if len(writer.pending) > 0 {
+		flushErr = writer.push()
+	}
+	writer.closed = true
+	writer.err = err
+	close(writer.chunks)
+	return flushErr
+}
+
+// Done returns a channel that is closed when the stream is cancelled.
+func (writer *Writer) Done() <-chan struct{} {
+	return writer.cancelled
+}
+
+// push sends the pending data as chunk. Must be invoked with writeLock held.
+func (writer *Writer) push() error {
+	chunk := writer.pending
+	writer.pending = nil
+
+	select {
+	case writer.chunks <- chunk:
+		return nil
+	case <-writer.cancelled:
+		return ErrCancelled
+	}
+}
+
+func (writer *Writer) cancel() {
+	writer.cancelOnce.Do(func() {
+		close(writer.cancelled)
+		writer.host.remove(writer.id)
+	})
+}
+
+// read returns the chunk with the requested sequence number. Waits at most wait for a chunk to
+// become available.
+func (writer *Writer) read(sequence int64, wait time.Duration) (*ReadResponse, error) {
+	writer.readLock.Lock()
+	defer writer.readLock.Unlock()
+
+	// The stream is not idle while it is being read. When the timer already expired, the stream is
+	// cancelled, which is detected below.
+	writer.idle.Stop()
+	defer writer.idle.Reset(writer.idleTimeout)
+
+	if sequence == writer.lastSequence && writer.last != nil {
+		return writer.last, nil
+	}
+	if sequence != writer.lastSequence+1 {
+		return nil, errors.New(ERROR_INVALID_SEQUENCE)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var response ReadResponse
+	select {
+	case chunk, ok := <-writer.chunks:
+		response.Sequence = sequence
+		if ok {
+			response.Data.SetBytes(chunk)
+		} else {
+			response.Eof = true
+			writer.writeLock.Lock()
+			if writer.err != nil {
+				response.Error = writer.err.Error()
+			}
+			writer.writeLock.Unlock()
+			// The stream is kept until it is idle, so that the end of the stream can be retransmitted.
+		}
+	case <-writer.cancelled:
+		return nil, ErrCancelled
+	case <-timer.C:
+		return &ReadResponse{}, nil
+	}
+
+	writer.lastSequence = sequence
+	writer.last = &response
+	return &response, nil
+}
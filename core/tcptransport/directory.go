@@ -0,0 +1,66 @@
+package tcptransport
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Directory resolves the name of a receiver (application id) to the tcp address on which that
+// application listens.
+type Directory interface {
+	Lookup(receiver string) (string, error)
+}
+
+// StaticDirectory is a [Directory] with a fixed mapping from receiver name to address, typically
+// obtained from configuration.
+type StaticDirectory map[string]string
+
+func (directory StaticDirectory) Lookup(receiver string) (string, error) {
+	address, has := directory[receiver]
+	if !has {
+		return "", fmt.Errorf("tcptransport: unknown receiver: %s", receiver)
+	}
+	return address, nil
+}
+
+// DirectoryFunc adapts a function to a [Directory]. To obtain the addresses from the actor registry, use
+// [RegistryDirectory] instead.
+type DirectoryFunc func(receiver string) (string, error)
+
+func (f DirectoryFunc) Lookup(receiver string) (string, error) {
+	return f(receiver)
+}
+
+// DynamicDirectory is a [Directory] whose entries can be changed at runtime, for example when
+// applications register themselves. Safe for concurrent use.
+type DynamicDirectory struct {
+	addresses map[string]string
+	mutex     sync.RWMutex
+}
+
+func NewDynamicDirectory() *DynamicDirectory {
+	return &DynamicDirectory{
+		addresses: make(map[string]string),
+	}
+}
+
+// Set sets the address of receiver. An empty address removes the receiver.
+func (directory *DynamicDirectory) Set(receiver string, address string) {
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+	if address == "" {
+		delete(directory.addresses, receiver)
+		return
+	}
+	directory.addresses[receiver] = address
+}
+
+func (directory *DynamicDirectory) Lookup(receiver string) (string, error) {
+	directory.mutex.RLock()
+	defer directory.mutex.RUnlock()
+	address, has := directory.addresses[receiver]
+	if !has {
+		return "", fmt.Errorf("tcptransport: unknown receiver: %s", receiver)
+	}
+	return address, nil
+}
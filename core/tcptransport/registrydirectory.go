@@ -0,0 +1,50 @@
+package tcptransport
+
+import (
+	"fmt"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+)
+
+// ADDRESS_ACTOR_TYPE is the pseudo actor type via which applications publish their tcp address in the
+// actor registry. It is never hosted; the address is stored as the migration version of the application,
+// which the registry returns per application.
+const ADDRESS_ACTOR_TYPE = "io.darlean.tcptransport.address"
+
+// AddressPushInfo returns the push info that publishes address for [ADDRESS_ACTOR_TYPE]. Typically passed
+// to the Publish method of the registry pusher together with [TcpTransport.Address].
+func AddressPushInfo(address string) actorregistry.ActorPushInfo {
+	return actorregistry.ActorPushInfo{
+		MigrationVersion: address,
+	}
+}
+
+// RegistryDirectory is a [Directory] that resolves receivers to the addresses that they published in the
+// actor registry. The addresses of the registry hosts themselves are taken from the seeds, which are
+// typically obtained from configuration.
+type RegistryDirectory struct {
+	fetcher actorregistry.ActorRegistryFetcher
+	seeds   map[string]string
+}
+
+func NewRegistryDirectory(fetcher actorregistry.ActorRegistryFetcher, seeds map[string]string) *RegistryDirectory {
+	return &RegistryDirectory{
+		fetcher: fetcher,
+		seeds:   seeds,
+	}
+}
+
+func (directory *RegistryDirectory) Lookup(receiver string) (string, error) {
+	if address, has := directory.seeds[receiver]; has {
+		return address, nil
+	}
+	info := directory.fetcher.Get(ADDRESS_ACTOR_TYPE)
+	if info != nil {
+		for _, app := range info.Applications {
+			if app.Name == receiver && app.MigrationVersion != nil && *app.MigrationVersion != "" {
+				return *app.MigrationVersion, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("tcptransport: unknown receiver: %s", receiver)
+}
@@ -0,0 +1,406 @@
+/*
+Package tcptransport implements a [core.Transport] over plain tcp connections, so that applications can
+communicate peer-to-peer without a message broker. The same implementation also works over Unix domain
+sockets (see package unixtransport).
+
+Every application listens on its own address. Messages are sent over outgoing connections to the address
+of the receiver, which is resolved via a [Directory]: from configuration (see [StaticDirectory]) or from the
+actor registry (see [RegistryDirectory]). Responses travel the same way in the opposite direction, so
+connections are only used for writing by the side that opened them.
+
+Every message is sent as a frame that consists of the length of the serialized message as 4-byte big-endian
+unsigned integer, followed by the message itself (see package wire).
+*/
+package tcptransport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/darlean-io/darlean.go/core/wire"
+)
+
+const DEFAULT_LISTEN_ADDRESS = "127.0.0.1:0"
+const DEFAULT_POOL_SIZE = 1
+const DEFAULT_DIAL_TIMEOUT = 5 * time.Second
+const DEFAULT_MAX_FRAME_SIZE = 64 * 1024 * 1024
+
+const FRAME_HEADER_SIZE = 4
+
+var ErrStopped = errors.New("tcptransport: transport stopped")
+
+type Options struct {
+	// AppId is the name of this application. Only used for diagnostics; the return address of messages
+	// is part of the messages itself.
+	AppId string
+	// Network is "tcp" (the default) or "unix". For "unix", addresses are socket paths.
+	Network string
+	// Address to listen on for incoming connections. Defaults to [DEFAULT_LISTEN_ADDRESS]. Use
+	// [TcpTransport.Address] to obtain the actual address when the port is 0.
+	ListenAddress string
+	// Directory that resolves receivers to addresses.
+	Directory Directory
+	// Maximum number of connections per remote address. Defaults to [DEFAULT_POOL_SIZE].
+	PoolSize int
+	// Maximum time to establish a connection. Defaults to [DEFAULT_DIAL_TIMEOUT].
+	DialTimeout time.Duration
+	// Maximum size of an incoming or outgoing message. Defaults to [DEFAULT_MAX_FRAME_SIZE].
+	MaxFrameSize int
+	// Compression of outgoing messages. Only applied to receivers that announced that they support
+	// compression (see [wire.Compression]). By default, messages are not compressed.
+	Compression *wire.Compression
+}
+
+type connection struct {
+	conn   net.Conn
+	writer *bufio.Writer
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (c *connection) write(frame []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	_, err := c.writer.Write(frame)
+	if err == nil {
+		err = c.writer.Flush()
+	}
+	return err
+}
+
+func (c *connection) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.conn.Close()
+	}
+}
+
+func (c *connection) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// pool contains the outgoing connections to one address.
+type pool struct {
+	connections []*connection
+	next        int
+	// Number of connections that are being established
+	dialing int
+	mutex   sync.Mutex
+}
+
+// nextConnection returns the existing connections round-robin. Must be invoked with the lock held.
+func (p *pool) nextConnection() *connection {
+	p.next = (p.next + 1) % len(p.connections)
+	return p.connections[p.next]
+}
+
+type TcpTransport struct {
+	options  Options
+	listener net.Listener
+	input    chan *wire.TagsIn
+	pools    map[string]*pool
+	inbound  map[net.Conn]struct{}
+	stopped  bool
+	done     chan struct{}
+	mutex    sync.Mutex
+	readers  sync.WaitGroup
+	peers    *wire.PeerVersions
+}
+
+// New creates a transport that listens on options.ListenAddress.
+func New(options Options) (*TcpTransport, error) {
+	if options.Directory == nil {
+		return nil, errors.New("tcptransport: directory required")
+	}
+	if options.Compression != nil {
+		err := options.Compression.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.Network == "" {
+		options.Network = "tcp"
+	}
+	if options.ListenAddress == "" {
+		options.ListenAddress = DEFAULT_LISTEN_ADDRESS
+	}
+	if options.PoolSize <= 0 {
+		options.PoolSize = DEFAULT_POOL_SIZE
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DEFAULT_DIAL_TIMEOUT
+	}
+	if options.MaxFrameSize <= 0 {
+		options.MaxFrameSize = DEFAULT_MAX_FRAME_SIZE
+	}
+
+	listener, err := net.Listen(options.Network, options.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := TcpTransport{
+		options:  options,
+		listener: listener,
+		input:    make(chan *wire.TagsIn, 16),
+		pools:    make(map[string]*pool),
+		inbound:  make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+		peers:    wire.NewPeerVersions(),
+	}
+
+	transport.readers.Add(1)
+	go transport.accept()
+
+	return &transport, nil
+}
+
+// Address returns the address on which the transport listens for incoming connections.
+func (transport *TcpTransport) Address() string {
+	return transport.listener.Addr().String()
+}
+
+// Send sends tags to the receiver in tags. Returns when the message is written to the connection.
+// When writing to an existing connection fails, the message is sent once more over a new connection.
+// Like with any transport without acknowledgements, a message that is written just before the remote side
+// closes the connection can get lost.
+func (transport *TcpTransport) Send(tags wire.TagsOut) error {
+	address, err := transport.options.Directory.Lookup(tags.Transport_Receiver)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, FRAME_HEADER_SIZE))
+	err = wire.SerializeWithOptions(buf, tags, wire.SerializeOptions{
+		PeerVersion: transport.peers.Get(tags.Transport_Receiver),
+		Compression: transport.options.Compression,
+	})
+	if err != nil {
+		return err
+	}
+	frame := buf.Bytes()
+	size := len(frame) - FRAME_HEADER_SIZE
+	if size > transport.options.MaxFrameSize {
+		return fmt.Errorf("tcptransport: message too large: %d bytes", size)
+	}
+	binary.BigEndian.PutUint32(frame, uint32(size))
+
+	for attempt := 0; ; attempt++ {
+		c, reused, err := transport.connect(address)
+		if err != nil {
+			return err
+		}
+		err = c.write(frame)
+		if err == nil {
+			return nil
+		}
+		c.close()
+		if !reused || attempt > 0 {
+			return err
+		}
+	}
+}
+
+// connect returns a connection to address from the pool. New connections are established until the
+// pool is full; after that, the existing connections are used round-robin. Returns whether the
+// connection already existed.
+func (transport *TcpTransport) connect(address string) (*connection, bool, error) {
+	transport.mutex.Lock()
+	if transport.stopped {
+		transport.mutex.Unlock()
+		return nil, false, ErrStopped
+	}
+	p, has := transport.pools[address]
+	if !has {
+		p = &pool{}
+		transport.pools[address] = p
+	}
+	transport.mutex.Unlock()
+
+	p.mutex.Lock()
+
+	// Remove connections that were closed by the remote side or after a write error
+	alive := p.connections[:0]
+	for _, c := range p.connections {
+		if !c.isClosed() {
+			alive = append(alive, c)
+		}
+	}
+	p.connections = alive
+
+	if len(p.connections) > 0 && len(p.connections)+p.dialing >= transport.options.PoolSize {
+		c := p.nextConnection()
+		p.mutex.Unlock()
+		return c, true, nil
+	}
+
+	// Dial without holding the lock, so that a slow dial does not block sends over the existing connections
+	// of the pool or Stop.
+	p.dialing++
+	p.mutex.Unlock()
+	conn, err := net.DialTimeout(transport.options.Network, address, transport.options.DialTimeout)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.dialing--
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Stop closes the connections of the pools after setting stopped, so checking it while holding the
+	// pool lock ensures that the connection is either closed here or by Stop.
+	transport.mutex.Lock()
+	stopped := transport.stopped
+	transport.mutex.Unlock()
+	if stopped {
+		conn.Close()
+		return nil, false, ErrStopped
+	}
+
+	if len(p.connections) >= transport.options.PoolSize {
+		// Concurrent dials filled the pool in the meantime
+		conn.Close()
+		return p.nextConnection(), true, nil
+	}
+
+	c := &connection{
+		conn:   conn,
+		writer: bufio.NewWriter(conn),
+	}
+	p.connections = append(p.connections, c)
+
+	// The remote side never writes to this connection. Reading detects when it closes the connection,
+	// so that a new connection is established for the next message.
+	go func() {
+		io.Copy(io.Discard, conn)
+		c.close()
+	}()
+
+	return c, false, nil
+}
+
+func (transport *TcpTransport) accept() {
+	defer transport.readers.Done()
+
+	for {
+		conn, err := transport.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("tcptransport: accept failed for %s: %v\n", transport.options.AppId, err)
+			}
+			return
+		}
+
+		transport.mutex.Lock()
+		if transport.stopped {
+			transport.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		transport.inbound[conn] = struct{}{}
+		transport.readers.Add(1)
+		transport.mutex.Unlock()
+
+		go transport.read(conn)
+	}
+}
+
+// read reads frames from conn and forwards the messages to the input channel.
+func (transport *TcpTransport) read(conn net.Conn) {
+	defer transport.readers.Done()
+	defer func() {
+		conn.Close()
+		transport.mutex.Lock()
+		delete(transport.inbound, conn)
+		transport.mutex.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	limited := &io.LimitedReader{R: reader}
+	frame := bufio.NewReader(limited)
+	header := make([]byte, FRAME_HEADER_SIZE)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if uint64(size) > uint64(transport.options.MaxFrameSize) {
+			fmt.Printf("tcptransport: frame of %d bytes from %s exceeds maximum size\n", size, conn.RemoteAddr())
+			return
+		}
+		// Decode directly from the stream, without buffering the entire frame first. The limit
+		// prevents an invalid message from reading into the next frame.
+		limited.N = int64(size)
+		frame.Reset(limited)
+		tags := wire.TagsIn{}
+		err = wire.Deserialize(frame, &tags)
+		if err != nil {
+			fmt.Printf("tcptransport: invalid message from %s: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+		_, err = io.Copy(io.Discard, frame)
+		if err != nil {
+			return
+		}
+		transport.peers.Observe(&tags)
+		// Nobody may consume the input channel anymore after Stop, so do not block on it.
+		select {
+		case transport.input <- &tags:
+		case <-transport.done:
+			return
+		}
+	}
+}
+
+// Stop closes the listener and all connections. Messages that were read but not yet delivered to
+// the input channel are dropped. The input channel is closed when all readers have finished.
+func (transport *TcpTransport) Stop() error {
+	transport.mutex.Lock()
+	if transport.stopped {
+		transport.mutex.Unlock()
+		return nil
+	}
+	transport.stopped = true
+	close(transport.done)
+	pools := transport.pools
+	for conn := range transport.inbound {
+		conn.Close()
+	}
+	transport.mutex.Unlock()
+
+	err := transport.listener.Close()
+
+	for _, p := range pools {
+		p.mutex.Lock()
+		for _, c := range p.connections {
+			c.close()
+		}
+		p.mutex.Unlock()
+	}
+
+	go func() {
+		transport.readers.Wait()
+		close(transport.input)
+	}()
+
+	return err
+}
+
+// Returns the channel to which incoming messages are emitted.
+func (transport *TcpTransport) GetInputChannel() chan *wire.TagsIn {
+	return transport.input
+}
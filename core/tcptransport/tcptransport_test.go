@@ -0,0 +1,169 @@
+package tcptransport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/base/services/actorregistry"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func message(receiver string, actionName string) wire.TagsOut {
+	tags := wire.TagsOut{}
+	tags.Transport_Receiver = receiver
+	tags.Transport_Return = "a"
+	tags.Remotecall_Kind = "call"
+	tags.ActorType = "actor"
+	tags.ActionName = actionName
+	return tags
+}
+
+func receive(t *testing.T, transport *TcpTransport) *wire.TagsIn {
+	select {
+	case tags := <-transport.GetInputChannel():
+		return tags
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+		return nil
+	}
+}
+
+func TestTcpTransport(t *testing.T) {
+	directory := NewDynamicDirectory()
+	a, err := New(Options{AppId: "a", Directory: directory})
+	checks.Equal(t, nil, err, "Create a")
+	defer a.Stop()
+	b, err := New(Options{AppId: "b", Directory: directory, PoolSize: 2})
+	checks.Equal(t, nil, err, "Create b")
+	directory.Set("a", a.Address())
+	directory.Set("b", b.Address())
+
+	for _, action := range []string{"one", "two", "three"} {
+		err = a.Send(message("b", action))
+		checks.Equal(t, nil, err, "Send to b")
+		checks.Equal(t, action, receive(t, b).ActionName, "Received by b in order")
+	}
+
+	err = b.Send(message("a", "back"))
+	checks.Equal(t, nil, err, "Send to a")
+	checks.Equal(t, "back", receive(t, a).ActionName, "Received by a")
+
+	err = a.Send(message("c", "unknown"))
+	checks.IsNotNil(t, err, "Send to unknown receiver")
+
+	// Restart b on the same address; a must reconnect.
+	address := b.Address()
+	b.Stop()
+	for range b.GetInputChannel() {
+	}
+	// Give a the opportunity to notice that b closed the connection
+	time.Sleep(100 * time.Millisecond)
+	b, err = New(Options{AppId: "b", ListenAddress: address, Directory: directory})
+	checks.Equal(t, nil, err, "Restart b")
+	defer b.Stop()
+
+	err = a.Send(message("b", "again"))
+	checks.Equal(t, nil, err, "Send to restarted b")
+	checks.Equal(t, "again", receive(t, b).ActionName, "Received by restarted b")
+
+	a.Stop()
+	err = a.Send(message("b", "stopped"))
+	checks.Equal(t, ErrStopped, err, "Send after stop")
+}
+
+func TestMaxFrameSize(t *testing.T) {
+	directory := NewDynamicDirectory()
+	a, _ := New(Options{Directory: directory, MaxFrameSize: 10})
+	defer a.Stop()
+	directory.Set("a", a.Address())
+	err := a.Send(message("a", "this-message-is-too-large"))
+	checks.IsNotNil(t, err, "Message larger than maximum frame size")
+}
+
+func TestCompression(t *testing.T) {
+	directory := NewDynamicDirectory()
+	compression := &wire.Compression{Algorithm: wire.COMPRESSION_ZSTD, Threshold: 10}
+	a, err := New(Options{Directory: directory, Compression: compression})
+	checks.Equal(t, nil, err, "Create with compression")
+	defer a.Stop()
+	directory.Set("a", a.Address())
+	tags := message("a", "first")
+	tags.Arguments = []any{strings.Repeat("darlean ", 100)}
+	checks.Equal(t, nil, a.Send(tags), "Send before version is known")
+	received := receive(t, a)
+	checks.Equal(t, "first", received.ActionName, "Received first message")
+	checks.Equal(t, int(wire.CHAR_CODE_VERSION_MINOR_SUPPORTED), received.SenderVersion, "Not compressed before the receiver announced support")
+
+	tags.ActionName = "compressed"
+	checks.Equal(t, nil, a.Send(tags), "Send compressed")
+	received = receive(t, a)
+	checks.Equal(t, "compressed", received.ActionName, "Received compressed message")
+	checks.Equal(t, int(wire.CHAR_CODE_VERSION_MINOR_COMPRESSION), received.SenderVersion, "Compressed after the receiver announced support")
+
+	_, err = New(Options{Directory: directory, Compression: &wire.Compression{Algorithm: 'x'}})
+	checks.IsNotNil(t, err, "Unsupported compression")
+}
+
+func TestStaticDirectory(t *testing.T) {
+	directory := StaticDirectory{"a": "127.0.0.1:1234"}
+	address, err := directory.Lookup("a")
+	checks.Equal(t, "127.0.0.1:1234", address, "Static address")
+	checks.Equal(t, nil, err, "Static lookup")
+	_, err = directory.Lookup("b")
+	checks.IsNotNil(t, err, "Unknown receiver")
+}
+
+func TestStopWithoutConsumer(t *testing.T) {
+	directory := NewDynamicDirectory()
+	a, _ := New(Options{AppId: "a", Directory: directory})
+	defer a.Stop()
+	b, _ := New(Options{AppId: "b", Directory: directory})
+	directory.Set("b", b.Address())
+
+	// More messages than fit in the input channel of b, which nobody consumes
+	for i := 0; i < 2*cap(b.GetInputChannel()); i++ {
+		checks.Equal(t, nil, a.Send(message("b", "action")), "Send to b")
+	}
+	time.Sleep(100 * time.Millisecond)
+	b.Stop()
+
+	finished := make(chan struct{})
+	go func() {
+		b.readers.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Readers did not finish after Stop")
+	}
+}
+
+type fakeFetcher map[string]*actorregistry.ActorInfo
+
+func (fetcher fakeFetcher) Get(actorType string) *actorregistry.ActorInfo {
+	return fetcher[actorType]
+}
+
+func TestRegistryDirectory(t *testing.T) {
+	address := "127.0.0.1:1234"
+	fetcher := fakeFetcher{}
+	directory := NewRegistryDirectory(fetcher, map[string]string{"registry": "127.0.0.1:1000"})
+
+	found, err := directory.Lookup("registry")
+	checks.Equal(t, nil, err, "Seed should be found")
+	checks.Equal(t, "127.0.0.1:1000", found, "Seed address")
+
+	_, err = directory.Lookup("a")
+	checks.IsNotNil(t, err, "Unknown receiver")
+
+	fetcher[ADDRESS_ACTOR_TYPE] = &actorregistry.ActorInfo{
+		Applications: []actorregistry.ApplicationInfo{{Name: "a", MigrationVersion: &address}},
+	}
+	found, err = directory.Lookup("a")
+	checks.Equal(t, nil, err, "Published receiver should be found")
+	checks.Equal(t, address, found, "Published address")
+	checks.Equal(t, address, AddressPushInfo(address).MigrationVersion, "Push info contains the address")
+}
@@ -119,6 +119,7 @@ func (handler *TransportHandler) Invoke(req *invoke.TransportHandlerInvokeReques
 	tags.ActorId = req.ActorId
 	tags.ActionName = req.ActionName
 	tags.Arguments = req.Parameters
+	tags.CallChain = req.CallChain
 
 	response := make(chan *invoker.Response)
 
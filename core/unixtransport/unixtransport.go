@@ -0,0 +1,75 @@
+/*
+Package unixtransport provides a [core.Transport] over Unix domain sockets, for applications that run on
+the same host (like sidecars). It uses the same framing as package tcptransport.
+
+By default, all applications share one socket directory in which every application listens on the
+socket "<app-id>.sock". No further configuration is required to find other applications.
+*/
+package unixtransport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/darlean-io/darlean.go/core/tcptransport"
+)
+
+const SOCKET_EXTENSION = ".sock"
+
+// SocketPath returns the path of the socket for appId within dir.
+func SocketPath(dir string, appId string) string {
+	return filepath.Join(dir, appId+SOCKET_EXTENSION)
+}
+
+// SocketDirectory is a [tcptransport.Directory] that resolves receivers to sockets within a directory.
+type SocketDirectory string
+
+func (dir SocketDirectory) Lookup(receiver string) (string, error) {
+	return SocketPath(string(dir), receiver), nil
+}
+
+// New creates a transport for appId that listens on a socket within dir and finds other applications
+// within the same dir.
+func New(appId string, dir string) (*tcptransport.TcpTransport, error) {
+	return NewWithOptions(tcptransport.Options{
+		AppId:         appId,
+		ListenAddress: SocketPath(dir, appId),
+		Directory:     SocketDirectory(dir),
+	})
+}
+
+// NewWithOptions creates a transport that listens on the socket path in options.ListenAddress.
+// A socket file that is left behind by a process that did not stop properly is removed.
+func NewWithOptions(options tcptransport.Options) (*tcptransport.TcpTransport, error) {
+	if options.ListenAddress == "" {
+		return nil, errors.New("unixtransport: socket path required")
+	}
+	options.Network = "unix"
+	err := removeStaleSocket(options.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	return tcptransport.New(options)
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unixtransport: %s exists and is not a socket", path)
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unixtransport: socket %s is in use", path)
+	}
+	return os.Remove(path)
+}
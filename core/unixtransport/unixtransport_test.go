@@ -0,0 +1,53 @@
+package unixtransport
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/darlean-io/darlean.go/core/tcptransport"
+	"github.com/darlean-io/darlean.go/core/wire"
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func TestUnixTransport(t *testing.T) {
+	dir, err := os.MkdirTemp("", "darlean")
+	checks.Equal(t, nil, err, "Create socket dir")
+	defer os.RemoveAll(dir)
+
+	// A socket file left behind by a crashed process
+	stale, err := net.Listen("unix", SocketPath(dir, "b"))
+	checks.Equal(t, nil, err, "Create stale socket")
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	a, err := New("a", dir)
+	checks.Equal(t, nil, err, "Create a")
+	defer a.Stop()
+	b, err := New("b", dir)
+	checks.Equal(t, nil, err, "Create b over stale socket")
+	defer b.Stop()
+
+	_, err = New("b", dir)
+	checks.IsNotNil(t, err, "Socket in use")
+
+	tags := wire.TagsOut{}
+	tags.Transport_Receiver = "b"
+	tags.Transport_Return = "a"
+	tags.Remotecall_Kind = "call"
+	tags.ActionName = "action"
+	err = a.Send(tags)
+	checks.Equal(t, nil, err, "Send to b")
+
+	select {
+	case received := <-b.GetInputChannel():
+		checks.Equal(t, "action", received.ActionName, "Received by b")
+		checks.Equal(t, "a", received.Transport_Return, "Return address")
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+	}
+
+	_, err = NewWithOptions(tcptransport.Options{Directory: SocketDirectory(dir)})
+	checks.IsNotNil(t, err, "Socket path required")
+}
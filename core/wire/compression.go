@@ -0,0 +1,90 @@
+package wire
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms. The algorithm is stored as flag in the header of messages with minor
+// version [CHAR_CODE_VERSION_MINOR_COMPRESSION] or higher.
+const (
+	COMPRESSION_NONE   = 'n'
+	COMPRESSION_ZSTD   = 'z'
+	COMPRESSION_SNAPPY = 's'
+)
+
+const DEFAULT_COMPRESSION_THRESHOLD = 4096
+
+// Maximum size of a decompressed message. Protects against messages that decompress to huge sizes.
+const MAX_DECOMPRESSED_SIZE = 64 * 1024 * 1024
+
+// Compression determines how [SerializeWithOptions] compresses messages. Compressed messages have a layout that
+// older receivers (including applications written in other languages that do not support compression) do not
+// understand, so they are only sent to receivers that announced support for [CHAR_CODE_VERSION_MINOR_COMPRESSION].
+type Compression struct {
+	// One of the COMPRESSION_* constants.
+	Algorithm int
+	// Messages smaller than Threshold bytes are not compressed. Defaults to [DEFAULT_COMPRESSION_THRESHOLD].
+	Threshold int
+}
+
+// Validate returns an error when the algorithm is not supported.
+func (c *Compression) Validate() error {
+	switch c.Algorithm {
+	case COMPRESSION_NONE, COMPRESSION_ZSTD, COMPRESSION_SNAPPY:
+		return nil
+	}
+	return fmt.Errorf("wire: unsupported compression algorithm: %q", c.Algorithm)
+}
+
+func (c *Compression) enabled() bool {
+	return c != nil && c.Algorithm != COMPRESSION_NONE
+}
+
+// Encoders and decoders are safe for concurrent use when the *All methods are used.
+var zstdEncoder, _ = zstd.NewWriter(nil)
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MAX_DECOMPRESSED_SIZE))
+
+// compress compresses data according to c. Returns the algorithm and the (possibly uncompressed) data.
+// Compression is skipped for small data, and when it does not make the data smaller.
+func compress(data []byte, c *Compression) (int, []byte) {
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = DEFAULT_COMPRESSION_THRESHOLD
+	}
+	if len(data) < threshold {
+		return COMPRESSION_NONE, data
+	}
+	var compressed []byte
+	switch c.Algorithm {
+	case COMPRESSION_ZSTD:
+		compressed = zstdEncoder.EncodeAll(data, nil)
+	case COMPRESSION_SNAPPY:
+		compressed = s2.EncodeSnappy(nil, data)
+	}
+	if len(compressed) >= len(data) {
+		return COMPRESSION_NONE, data
+	}
+	return c.Algorithm, compressed
+}
+
+func decompress(algorithm int, data []byte) ([]byte, error) {
+	switch algorithm {
+	case COMPRESSION_NONE:
+		return data, nil
+	case COMPRESSION_ZSTD:
+		return zstdDecoder.DecodeAll(data, nil)
+	case COMPRESSION_SNAPPY:
+		size, err := s2.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if size > MAX_DECOMPRESSED_SIZE {
+			return nil, fmt.Errorf("wire: decompressed message too large: %d bytes", size)
+		}
+		return s2.Decode(nil, data)
+	}
+	return nil, fmt.Errorf("wire: unsupported compression algorithm: %q", algorithm)
+}
@@ -0,0 +1,96 @@
+package wire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("darlean ", 1000)
+	tags := TagsOut{}
+	tags.Transport_Receiver = "Receiver"
+	tags.ActionName = "Action"
+	tags.Arguments = []any{large, map[string]string{"text": large}}
+	tags.CallChain = []string{"c1", "c2"}
+
+	for _, algorithm := range []int{COMPRESSION_ZSTD, COMPRESSION_SNAPPY} {
+		c := &Compression{Algorithm: algorithm}
+		checks.Equal(t, nil, c.Validate(), "Valid compression")
+
+		var buf bytes.Buffer
+		err := SerializeWithOptions(&buf, tags, SerializeOptions{PeerVersion: CHAR_CODE_VERSION_MINOR_SUPPORTED, Compression: c})
+		checks.Equal(t, nil, err, "Serialize")
+		checks.Equal(t, true, buf.Len() < len(large), "Message is compressed")
+		checks.Equal(t, byte(CHAR_CODE_VERSION_MINOR_COMPRESSION), buf.Bytes()[1], "Minor version of compressed message")
+		checks.Equal(t, byte(algorithm), buf.Bytes()[2], "Compression flag")
+
+		var tags2 TagsIn
+		err = Deserialize(&buf, &tags2)
+		checks.Equal(t, nil, err, "Deserialize")
+		checks.Equal(t, "Receiver", tags2.Transport_Receiver, "Receiver")
+		checks.Equal(t, "Action", tags2.ActionName, "Action")
+		var arg0 string
+		tags2.Arguments[0].AssignTo(&arg0)
+		checks.Equal(t, large, arg0, "Large argument")
+		var arg1 map[string]string
+		tags2.Arguments[1].AssignTo(&arg1)
+		checks.Equal(t, large, arg1["text"], "Large argument in struct")
+		checks.Equal(t, []string{"c1", "c2"}, tags2.CallChain, "Call chain")
+		checks.Equal(t, int(CHAR_CODE_VERSION_MINOR_COMPRESSION), tags2.SenderVersion, "Sender version")
+	}
+
+	// Small messages are not compressed and remain readable by older receivers
+	tags.Arguments = []any{"small"}
+	tags.CallChain = nil
+	var buf bytes.Buffer
+	SerializeWithOptions(&buf, tags, SerializeOptions{PeerVersion: CHAR_CODE_VERSION_MINOR_SUPPORTED, Compression: &Compression{Algorithm: COMPRESSION_ZSTD}})
+	checks.Equal(t, byte(CHAR_CODE_VERSION_MINOR_ANNOUNCE), buf.Bytes()[1], "Minor version of uncompressed message")
+	var tags2 TagsIn
+	err := Deserialize(&buf, &tags2)
+	checks.Equal(t, nil, err, "Deserialize uncompressed")
+	checks.Equal(t, "small", tags2.Arguments[0], "Small argument")
+
+	c := &Compression{Algorithm: 'x'}
+	checks.IsNotNil(t, c.Validate(), "Unsupported algorithm")
+
+	buf.Reset()
+	buf.WriteString("02xdata")
+	err = Deserialize(&buf, &tags2)
+	checks.IsNotNil(t, err, "Unsupported algorithm in message")
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	tags := TagsOut{}
+	tags.Transport_Receiver = "peer"
+	tags.Arguments = []any{strings.Repeat("darlean ", 1000)}
+	c := &Compression{Algorithm: COMPRESSION_SNAPPY}
+	peers := NewPeerVersions()
+	checks.Equal(t, int(CHAR_CODE_VERSION_MINOR), peers.Get("peer"), "Unknown peer")
+
+	var buf bytes.Buffer
+	SerializeWithOptions(&buf, tags, SerializeOptions{PeerVersion: peers.Get("peer"), Compression: c})
+	checks.Equal(t, byte(CHAR_CODE_VERSION_MINOR_ANNOUNCE), buf.Bytes()[1], "Not compressed for peer that did not announce support")
+
+	// The peer announces its version in the messages it sends
+	var tags2 TagsIn
+	reply := TagsOut{}
+	reply.Transport_Return = "peer"
+	buf.Reset()
+	Serialize(&buf, reply)
+	checks.Equal(t, nil, Deserialize(&buf, &tags2), "Deserialize announcement")
+	checks.Equal(t, int(CHAR_CODE_VERSION_MINOR_SUPPORTED), tags2.SenderVersion, "Announced version")
+	peers.Observe(&tags2)
+
+	buf.Reset()
+	SerializeWithOptions(&buf, tags, SerializeOptions{PeerVersion: peers.Get("peer"), Compression: c})
+	checks.Equal(t, byte(CHAR_CODE_VERSION_MINOR_COMPRESSION), buf.Bytes()[1], "Compressed for peer that announced support")
+
+	// Older peers do not announce a version
+	tags2.SenderVersion = CHAR_CODE_VERSION_MINOR
+	tags2.Transport_Return = "legacy"
+	peers.Observe(&tags2)
+	checks.Equal(t, int(CHAR_CODE_VERSION_MINOR), peers.Get("legacy"), "Legacy peer")
+}
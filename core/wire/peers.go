@@ -0,0 +1,41 @@
+package wire
+
+import "sync"
+
+// PeerVersions keeps track of the minor versions that peers support, as announced in the messages they send.
+// Transports use it to determine the [SerializeOptions] for a receiver. Safe for concurrent use.
+type PeerVersions struct {
+	versions map[string]int
+	mutex    sync.RWMutex
+}
+
+func NewPeerVersions() *PeerVersions {
+	return &PeerVersions{
+		versions: make(map[string]int),
+	}
+}
+
+// Observe registers the version that the sender of tags supports. Versions never go down, so that an older
+// message that arrives late does not disable features.
+func (peers *PeerVersions) Observe(tags *TagsIn) {
+	if tags.Transport_Return == "" {
+		return
+	}
+	peers.mutex.Lock()
+	defer peers.mutex.Unlock()
+	if tags.SenderVersion > peers.versions[tags.Transport_Return] {
+		peers.versions[tags.Transport_Return] = tags.SenderVersion
+	}
+}
+
+// Get returns the highest minor version that peer supports, or [CHAR_CODE_VERSION_MINOR] when peer did not
+// announce a version yet.
+func (peers *PeerVersions) Get(peer string) int {
+	peers.mutex.RLock()
+	defer peers.mutex.RUnlock()
+	version, has := peers.versions[peer]
+	if !has {
+		return CHAR_CODE_VERSION_MINOR
+	}
+	return version
+}
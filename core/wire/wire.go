@@ -25,6 +25,8 @@ type ActorCallRequestIn struct {
 	ActorId    []string
 	ActionName string
 	Arguments  []variant.Assignable
+	// Ids of the calls that led to this call (see [invoker.Request.CallChain]).
+	CallChain []string
 }
 
 type ActorCallResponseIn struct {
@@ -38,6 +40,8 @@ type ActorCallRequestOut struct {
 	ActorId    []string
 	ActionName string
 	Arguments  []any
+	// Ids of the calls that led to this call (see [invoker.Request.CallChain]).
+	CallChain []string
 }
 
 type ActorCallResponseOut struct {
@@ -50,6 +54,9 @@ type TagsIn struct {
 	RemoteCallTags
 	ActorCallRequestIn
 	ActorCallResponseIn
+	// Highest minor version the sender of the message supports, as far as can be derived from the message
+	// (see [CHAR_CODE_VERSION_MINOR_ANNOUNCE]).
+	SenderVersion int
 }
 
 type TagsOut struct {
@@ -62,70 +69,196 @@ type TagsOut struct {
 const CHAR_CODE_VERSION_MAJOR = '0'
 const CHAR_CODE_VERSION_MINOR = '0'
 
+// Minor version from which the message ends with the call chain. The fields before it are the same as
+// for [CHAR_CODE_VERSION_MINOR], so receivers that do not know the call chain can still read the message.
+const CHAR_CODE_VERSION_MINOR_CALL_CHAIN = '1'
+
+// Minor version that indicates that the version is followed by a compression flag (see [Compression]). Only
+// used for compressed messages, and only for receivers that announced that they support it, because the layout
+// of these messages differs from the other versions.
+const CHAR_CODE_VERSION_MINOR_COMPRESSION = '2'
+
+// Minor version from which the call chain is followed by the highest minor version that the sender supports.
+// Receivers that do not know the announcement ignore it, like they ignore the call chain. Receivers that do
+// know it keep track of the announced versions (see [PeerVersions]), so that features that older receivers
+// do not understand (like compression) are only used for peers that support them.
+const CHAR_CODE_VERSION_MINOR_ANNOUNCE = '3'
+
+// Minor version from which receivers understand the extended variant type codes (see
+// [fastproto.WriteVariantExtended]). Does not change the layout of messages; arguments and values are only
+// written with these codes for receivers that announced this version.
+const CHAR_CODE_VERSION_MINOR_VARIANTS = '4'
+
+// Highest minor version that this implementation supports.
+const CHAR_CODE_VERSION_MINOR_SUPPORTED = CHAR_CODE_VERSION_MINOR_VARIANTS
+
+// Maximum number of actor id parts and arguments of a message.
+const MAX_COUNT = 65536
+
 const CHAR_CODE_RETURN = 'r'
 const CHAR_CODE_CALL = 'c'
 
 const CHAR_CODE_FALSE = 'f'
 const CHAR_CODE_TRUE = 't'
 
-func Serialize(buf *bytes.Buffer, tags TagsOut) error {
-	// Version
-	fastproto.WriteChar(buf, CHAR_CODE_VERSION_MAJOR)
-	fastproto.WriteChar(buf, CHAR_CODE_VERSION_MINOR)
+// SerializeOptions determine how a message is written for a specific receiver.
+type SerializeOptions struct {
+	// Highest minor version that the receiver supports, typically obtained via [PeerVersions.Get].
+	PeerVersion int
+	// Compression of the message. Only applied when the receiver supports [CHAR_CODE_VERSION_MINOR_COMPRESSION].
+	Compression *Compression
+}
 
-	// Transport
-	fastproto.WriteString(buf, &tags.Transport_Receiver)
-	fastproto.WriteString(buf, &tags.Transport_Return)
+// Serialize writes tags to buf for a receiver of which the supported version is unknown. When buf is
+// buffered (like a bufio.Writer), the caller is responsible for flushing it.
+func Serialize(buf fastproto.Writer, tags TagsOut) error {
+	return SerializeWithOptions(buf, tags, SerializeOptions{})
+}
 
-	// Transport failure code + message
-	fastproto.WriteString(buf, nil)
-	fastproto.WriteString(buf, nil)
+// SerializeWithOptions writes tags to buf using the features that the receiver supports according to options.
+// Messages that are not compressed announce the version that we support.
+func SerializeWithOptions(buf fastproto.Writer, tags TagsOut, options SerializeOptions) error {
+	extended := options.PeerVersion >= CHAR_CODE_VERSION_MINOR_VARIANTS
+	if options.PeerVersion < CHAR_CODE_VERSION_MINOR_COMPRESSION || !options.Compression.enabled() {
+		return serializeAnnounced(buf, tags, extended)
+	}
 
-	// Tracing cids + parentuid
-	err := fastproto.WriteVariant(buf, nil)
+	body := new(bytes.Buffer)
+	err := serializeBody(body, tags, extended)
+	if err != nil {
+		return err
+	}
+	algorithm, data := compress(body.Bytes(), options.Compression)
+	if algorithm == COMPRESSION_NONE {
+		return serializeAnnounced(buf, tags, extended)
+	}
+	err = fastproto.WriteChar(buf, CHAR_CODE_VERSION_MAJOR)
+	if err != nil {
+		return err
+	}
+	err = fastproto.WriteChar(buf, CHAR_CODE_VERSION_MINOR_COMPRESSION)
+	if err != nil {
+		return err
+	}
+	err = fastproto.WriteChar(buf, algorithm)
+	if err != nil {
+		return err
+	}
+	return fastproto.WriteBinary(buf, &data)
+}
+
+// serializeAnnounced writes an uncompressed message that ends with the version that we support.
+func serializeAnnounced(buf fastproto.Writer, tags TagsOut, extended bool) error {
+	err := fastproto.WriteChar(buf, CHAR_CODE_VERSION_MAJOR)
+	if err != nil {
+		return err
+	}
+	err = fastproto.WriteChar(buf, CHAR_CODE_VERSION_MINOR_ANNOUNCE)
 	if err != nil {
 		return err
 	}
-	err = fastproto.WriteString(buf, nil)
+	err = serializeBody(buf, tags, extended)
 	if err != nil {
 		return err
 	}
+	return fastproto.WriteChar(buf, CHAR_CODE_VERSION_MINOR_SUPPORTED)
+}
+
+// serializeBody writes the fields of tags, up to and including the call chain. Arguments and values are written
+// with the extended variant type codes when extended is true.
+func serializeBody(buf fastproto.Writer, tags TagsOut, extended bool) error {
+	writeVariant := fastproto.WriteVariant
+	if extended {
+		writeVariant = fastproto.WriteVariantExtended
+	}
+	w := bodyWriter{buf: buf}
+
+	// Transport
+	w.string(&tags.Transport_Receiver)
+	w.string(&tags.Transport_Return)
+
+	// Transport failure code + message
+	w.string(nil)
+	w.string(nil)
+
+	// Tracing cids + parentuid
+	w.variant(fastproto.WriteVariant, nil)
+	w.string(nil)
 
 	// RemoteCall
-	fastproto.WriteString(buf, &tags.Remotecall_Id)
+	w.string(&tags.Remotecall_Id)
 	if tags.Remotecall_Kind == "return" {
-		fastproto.WriteChar(buf, CHAR_CODE_RETURN)
+		w.char(CHAR_CODE_RETURN)
 	} else {
-		fastproto.WriteChar(buf, CHAR_CODE_CALL)
+		w.char(CHAR_CODE_CALL)
 	}
 
 	// Call request
 	if tags.Lazy {
-		fastproto.WriteChar(buf, CHAR_CODE_TRUE)
+		w.char(CHAR_CODE_TRUE)
 	} else {
-		fastproto.WriteChar(buf, CHAR_CODE_FALSE)
+		w.char(CHAR_CODE_FALSE)
 	}
-	fastproto.WriteString(buf, &tags.ActorType)
-	fastproto.WriteString(buf, &tags.ActionName)
-	fastproto.WriteUnsignedInt(buf, len(tags.ActorId))
+	w.string(&tags.ActorType)
+	w.string(&tags.ActionName)
+	w.count(len(tags.ActorId))
 	for _, part := range tags.ActorId {
-		fastproto.WriteString(buf, &part)
+		w.string(&part)
 	}
-	fastproto.WriteUnsignedInt(buf, len(tags.Arguments))
+	w.count(len(tags.Arguments))
 	for _, arg := range tags.Arguments {
-		err := fastproto.WriteVariant(buf, arg)
-		if err != nil {
-			return err
-		}
+		w.variant(writeVariant, arg)
 	}
 
 	// Call response
-	fastproto.WriteVariant(buf, tags.ActorCallResponseOut.Value)
-	fastproto.WriteJson(buf, tags.ActorCallResponseOut.Error)
-	return nil
+	w.variant(writeVariant, tags.ActorCallResponseOut.Value)
+	if w.err == nil {
+		w.err = fastproto.WriteJson(buf, tags.ActorCallResponseOut.Error)
+	}
+
+	// Call chain
+	w.count(len(tags.CallChain))
+	for _, id := range tags.CallChain {
+		w.string(&id)
+	}
+	return w.err
+}
+
+// bodyWriter remembers the first error that occurs, so that the fields of a message can be written without
+// checking every write.
+type bodyWriter struct {
+	buf fastproto.Writer
+	err error
 }
 
-func Deserialize(buf *bytes.Buffer, tags *TagsIn) error {
+func (w *bodyWriter) string(value *string) {
+	if w.err == nil {
+		w.err = fastproto.WriteString(w.buf, value)
+	}
+}
+
+func (w *bodyWriter) char(value int) {
+	if w.err == nil {
+		w.err = fastproto.WriteChar(w.buf, value)
+	}
+}
+
+func (w *bodyWriter) count(value int) {
+	if w.err == nil {
+		w.err = fastproto.WriteUnsignedInt(w.buf, value)
+	}
+}
+
+func (w *bodyWriter) variant(write func(fastproto.Writer, any) error, value any) {
+	if w.err == nil {
+		w.err = write(w.buf, value)
+	}
+}
+
+// Deserialize reads one message from buf into tags. Does not read beyond the end of the message, so
+// that buf can be a stream that contains multiple messages. Use [fastproto.NewReader] to read from
+// an arbitrary io.Reader.
+func Deserialize(buf fastproto.Reader, tags *TagsIn) error {
 	// Version number major + minor
 	major, err := fastproto.ReadChar(buf)
 	if err != nil {
@@ -135,11 +268,29 @@ func Deserialize(buf *bytes.Buffer, tags *TagsIn) error {
 		return fmt.Errorf("wire: invalid major version: %v", major)
 	}
 
-	_, err = fastproto.ReadChar(buf)
+	minor, err := fastproto.ReadChar(buf)
 	if err != nil {
 		return err
 	}
 
+	if minor == CHAR_CODE_VERSION_MINOR_COMPRESSION {
+		algorithm, err := fastproto.ReadChar(buf)
+		if err != nil {
+			return err
+		}
+		if algorithm != COMPRESSION_NONE {
+			compressed, err := fastproto.ReadBinary(buf)
+			if err != nil {
+				return err
+			}
+			data, err := decompress(algorithm, *compressed)
+			if err != nil {
+				return err
+			}
+			buf = bytes.NewBuffer(data)
+		}
+	}
+
 	// Transport receiver + return
 	receiver, err := fastproto.ReadString(buf)
 	if err != nil {
@@ -164,7 +315,7 @@ func Deserialize(buf *bytes.Buffer, tags *TagsIn) error {
 		return err
 	}
 
-	// Tracing cids + parentuid
+	// Tracing cids + parentuid. Not used (yet).
 	_, err = fastproto.ReadVariant(buf)
 	if err != nil {
 		return err
@@ -215,7 +366,10 @@ func Deserialize(buf *bytes.Buffer, tags *TagsIn) error {
 	}
 	tags.ActionName = *actionName
 
-	nrIdFields, err := fastproto.ReadUnsignedInt(buf)
+	nrIdFields, err := readCount(buf)
+	if err != nil {
+		return err
+	}
 	if nrIdFields > 0 {
 		parts := make([]string, nrIdFields)
 		for i := 0; i < int(nrIdFields); i++ {
@@ -228,7 +382,10 @@ func Deserialize(buf *bytes.Buffer, tags *TagsIn) error {
 		tags.ActorId = parts
 	}
 
-	nrArguments, err := fastproto.ReadUnsignedInt(buf)
+	nrArguments, err := readCount(buf)
+	if err != nil {
+		return err
+	}
 	if nrArguments > 0 {
 		args := make([]variant.Assignable, nrArguments)
 		for i := 0; i < int(nrArguments); i++ {
@@ -254,5 +411,45 @@ func Deserialize(buf *bytes.Buffer, tags *TagsIn) error {
 	}
 	tags.Error = responseError
 
+	// Call chain
+	if minor >= CHAR_CODE_VERSION_MINOR_CALL_CHAIN {
+		nrIds, err := readCount(buf)
+		if err != nil {
+			return err
+		}
+		if nrIds > 0 {
+			chain := make([]string, nrIds)
+			for i := range chain {
+				id, err := fastproto.ReadString(buf)
+				if err != nil {
+					return err
+				}
+				chain[i] = *id
+			}
+			tags.CallChain = chain
+		}
+	}
+
+	tags.SenderVersion = minor
+	if minor >= CHAR_CODE_VERSION_MINOR_ANNOUNCE {
+		announced, err := fastproto.ReadChar(buf)
+		if err != nil {
+			return err
+		}
+		tags.SenderVersion = max(minor, announced)
+	}
+
 	return nil
 }
+
+// readCount reads the number of actor id parts or arguments and checks it against [MAX_COUNT].
+func readCount(buf fastproto.Reader) (uint32, error) {
+	count, err := fastproto.ReadUnsignedInt(buf)
+	if err != nil {
+		return 0, err
+	}
+	if count > MAX_COUNT {
+		return 0, fmt.Errorf("wire: too many items: %d", count)
+	}
+	return count, nil
+}
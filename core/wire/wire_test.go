@@ -1,11 +1,15 @@
 package wire
 
 import (
+	"bufio"
 	"bytes"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/darlean-io/darlean.go/utils/binary"
 	"github.com/darlean-io/darlean.go/utils/checks"
+	"github.com/darlean-io/darlean.go/utils/fastproto"
 	"github.com/darlean-io/darlean.go/utils/variant"
 )
 
@@ -120,3 +124,100 @@ func TestActorContainerWithStructs(t *testing.T) {
 	checks.Equal(t, 3.1, st2.AFloat, "ActorCallResponse Value")
 	checks.Equal(t, true, st2.ABool, "ActorCallResponse Value")
 }
+
+func TestCallChain(t *testing.T) {
+	tags := TagsOut{}
+	tags.Remotecall_Kind = "call"
+	tags.CallChain = []string{"c1", "c2"}
+
+	var buf bytes.Buffer
+	Serialize(&buf, tags)
+	var tags2 TagsIn
+	checks.Equal(t, nil, Deserialize(&buf, &tags2), "Deserialize should succeed")
+	checks.Equal(t, []string{"c1", "c2"}, tags2.CallChain, "Call chain")
+
+	buf.Reset()
+	tags.CallChain = nil
+	Serialize(&buf, tags)
+	checks.Equal(t, byte(CHAR_CODE_VERSION_MINOR_ANNOUNCE), buf.Bytes()[1], "Announced minor version without call chain")
+	var tags3 TagsIn
+	Deserialize(&buf, &tags3)
+	checks.Equal(t, 0, len(tags3.CallChain), "Empty call chain")
+}
+
+func TestCallChainCompatibility(t *testing.T) {
+	// Tracing cids of other applications are not mistaken for a call chain
+	var buf bytes.Buffer
+	Serialize(&buf, TagsOut{})
+	data := buf.Bytes()
+	var tracing bytes.Buffer
+	fastproto.WriteVariant(&tracing, []string{"trace-1"})
+	// Version (2), receiver, return, failure code and message (1 each), followed by the tracing cids
+	message := append(append(append([]byte{}, data[:6]...), tracing.Bytes()...), data[7:]...)
+	var tags TagsIn
+	checks.Equal(t, nil, Deserialize(bytes.NewBuffer(message), &tags), "Deserialize with tracing cids")
+	checks.Equal(t, 0, len(tags.CallChain), "Tracing cids are not a call chain")
+
+	// Receivers that do not know the call chain can read messages with a call chain up to the call chain
+	tagsOut := TagsOut{}
+	tagsOut.ActionName = "Action"
+	tagsOut.CallChain = []string{"c1"}
+	buf.Reset()
+	Serialize(&buf, tagsOut)
+	checks.Equal(t, byte(CHAR_CODE_VERSION_MINOR_ANNOUNCE), buf.Bytes()[1], "Announced minor version with call chain")
+	withoutChain := buf.Bytes()
+	withoutChain[1] = CHAR_CODE_VERSION_MINOR
+	var tags2 TagsIn
+	checks.Equal(t, nil, Deserialize(bytes.NewBuffer(withoutChain), &tags2), "Deserialize as older receiver")
+	checks.Equal(t, "Action", tags2.ActionName, "Action")
+}
+
+func TestStream(t *testing.T) {
+	reader, writer := io.Pipe()
+	go func() {
+		w := bufio.NewWriter(writer)
+		for _, action := range []string{"one", "two", "three"} {
+			tags := TagsOut{}
+			tags.ActionName = action
+			tags.Arguments = []any{action}
+			Serialize(w, tags)
+		}
+		w.Flush()
+		writer.Close()
+	}()
+
+	r := fastproto.NewReader(reader)
+	for _, action := range []string{"one", "two", "three"} {
+		var tags TagsIn
+		err := Deserialize(r, &tags)
+		checks.Equal(t, nil, err, "Deserialize from stream")
+		checks.Equal(t, action, tags.ActionName, "Action name")
+		checks.Equal(t, action, tags.Arguments[0], "Argument")
+	}
+	var tags TagsIn
+	err := Deserialize(r, &tags)
+	checks.Equal(t, io.EOF, err, "End of stream")
+}
+
+func TestExtendedVariants(t *testing.T) {
+	tags := TagsOut{}
+	tags.Arguments = []any{42, time.UnixMilli(1000)}
+
+	serialize := func(peerVersion int) []byte {
+		var buf bytes.Buffer
+		checks.Equal(t, nil, SerializeWithOptions(&buf, tags, SerializeOptions{PeerVersion: peerVersion}), "Serialize")
+		return buf.Bytes()
+	}
+	legacy := serialize(CHAR_CODE_VERSION_MINOR)
+	checks.Equal(t, legacy, serialize(CHAR_CODE_VERSION_MINOR_ANNOUNCE), "No extended codes before the variants version")
+	extended := serialize(CHAR_CODE_VERSION_MINOR_VARIANTS)
+	checks.Equal(t, false, bytes.Equal(legacy, extended), "Extended codes for peers that support them")
+
+	for _, data := range [][]byte{legacy, extended} {
+		var tags2 TagsIn
+		checks.Equal(t, nil, Deserialize(bytes.NewBuffer(data), &tags2), "Deserialize")
+		var i int
+		checks.Equal(t, nil, tags2.Arguments[0].AssignTo(&i), "Assign integer")
+		checks.Equal(t, 42, i, "Integer")
+	}
+}
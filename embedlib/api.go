@@ -20,6 +20,7 @@ package main
 import "C"
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/darlean-io/darlean.go/base/actionerror"
@@ -28,9 +29,12 @@ import (
 	"github.com/darlean-io/darlean.go/core/backoff"
 	"github.com/darlean-io/darlean.go/core/invoke"
 	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/natsserver"
 	"github.com/darlean-io/darlean.go/core/natstransport"
 	"github.com/darlean-io/darlean.go/core/normalized"
 	"github.com/darlean-io/darlean.go/core/remoteactorregistry"
+	"github.com/darlean-io/darlean.go/core/shutdown"
+	"github.com/darlean-io/darlean.go/core/streams"
 	"github.com/darlean-io/darlean.go/core/transporthandler"
 	"github.com/darlean-io/darlean.go/utils/variant"
 )
@@ -41,13 +45,15 @@ type actionCb func(call PendingCall, arguments []variant.Assignable)
 type ActionInfo struct {
 	ActionName normalized.ActionName
 	Locking    inward.ActionLockKind
+	Reentrant  bool
 	Callback   actionCb
 }
 
 type ActorInfo struct {
-	ActorType   normalized.ActorType
-	Actions     map[normalized.ActionName]ActionInfo
-	CallManager CallManager
+	ActorType        normalized.ActorType
+	MigrationVersion string
+	Actions          map[normalized.ActionName]ActionInfo
+	CallManager      CallManager
 }
 
 type CallManager interface {
@@ -62,12 +68,14 @@ type Api struct {
 	Invoker       *invoke.DynamicInvoker
 	registry      *remoteactorregistry.RemoteActorRegistryFetcher
 	transport     *natstransport.NatsTransport
+	natsServer    *natsserver.Server
 	staticInvoker *transporthandler.TransportHandler
 	fetcher       *remoteactorregistry.RemoteActorRegistryFetcher
 	actorTypes    map[normalized.ActorType]ActorInfo
 	pusher        *remoteactorregistry.RemoteActorRegistryPusher
 	dispatcher    *inward.Dispatcher
 	containers    []*inward.StandardActorContainer
+	streams       *streams.Host
 }
 
 type RegisteredActor interface {
@@ -81,8 +89,10 @@ type PendingCall interface {
 	HandleResponse(options SubmitActionResultOptions)
 }
 
+// NewApi creates an app that connects to the NATS server at natsAddr. When natsAddr is [natsserver.EMBEDDED]
+// (optionally followed by a port), the app runs its own NATS server (see [natsserver.Open]).
 func NewApi(appId string, natsAddr string, hosts []string) *Api {
-	transport, err := natstransport.New(natsAddr, appId)
+	transport, natsServer, err := natsserver.Open(natsAddr, appId, natstransport.Options{})
 	if err != nil {
 		panic(err)
 	}
@@ -95,38 +105,55 @@ func NewApi(appId string, natsAddr string, hosts []string) *Api {
 
 	registryPusher := remoteactorregistry.NewPusher(hosts, appId, staticInvoker)
 	dispatcher := inward.NewDispatcher(registryPusher)
+	fetcher.EnableSubscription(appId, dispatcher)
 
 	return &Api{
 		Invoker:       &invoker,
 		registry:      fetcher,
 		transport:     transport,
+		natsServer:    natsServer,
 		staticInvoker: staticInvoker,
 		fetcher:       fetcher,
 		pusher:        registryPusher,
 		dispatcher:    dispatcher,
 		actorTypes:    map[normalized.ActorType]ActorInfo{},
 		containers:    []*inward.StandardActorContainer{},
+		streams:       streams.NewHost(appId),
 	}
 }
 
+// EnableReadinessGate makes the app hold incoming calls until the first fetch from the actor registry succeeded.
+// Calls that are not released within timeout fail, so that the caller can retry elsewhere. The gate is disabled
+// by default. Must be invoked before [Api.Start].
+func (api *Api) EnableReadinessGate(timeout time.Duration) {
+	api.dispatcher.SetReadinessGate(api.fetcher.Ready(), timeout)
+}
+
+// Start starts the app. The streams host is always registered (and hence advertised), so that other
+// applications can read the streams that this app writes.
 func (api *Api) Start() {
 	api.registerActors()
+	api.dispatcher.RegisterActorType(api.streams.ActorInfo())
 	api.staticInvoker.Start(api.dispatcher)
 	api.fetcher.Start()
-	if len(api.actorTypes) > 0 {
-		api.pusher.Start()
-	}
+	api.pusher.Start()
 }
 
 func (api *Api) Stop() {
-	if len(api.actorTypes) > 0 {
-		for idx := len(api.containers) - 1; idx >= 0; idx-- {
-			api.containers[idx].Stop()
-		}
-		api.pusher.Stop()
+	options := shutdown.Options{
+		Dispatcher: api.dispatcher,
+		Pusher:     api.pusher,
+		Components: []shutdown.Stopper{api.registry},
+		Transport:  api.transport,
+		Timeout:    10 * time.Second,
+	}
+	err := shutdown.Graceful(options)
+	if err != nil {
+		fmt.Printf("embedlib: %v\n", err)
+	}
+	if api.natsServer != nil {
+		api.natsServer.Shutdown()
 	}
-	api.registry.Stop()
-	api.transport.Stop()
 }
 
 func (api *Api) Invoke(request *invoker.Request, goCb invokeCb) {
@@ -134,6 +161,17 @@ func (api *Api) Invoke(request *invoker.Request, goCb invokeCb) {
 	goCb(result, err)
 }
 
+// NewStreamWriter creates a stream that is hosted by this app. The handle can be passed to other actors,
+// which read the stream via [Api.NewStreamReader].
+func (api *Api) NewStreamWriter() (streams.Handle, *streams.Writer) {
+	return api.streams.NewWriter()
+}
+
+// NewStreamReader returns a reader for the stream with handle, which can be hosted by any app.
+func (api *Api) NewStreamReader(handle streams.Handle) *streams.Reader {
+	return streams.NewReader(api.Invoker, handle)
+}
+
 func (actor *ActorInfo) RegisterAction(options RegisterActionOptions, callback actionCb) RegisteredAction {
 	normalizedActionName := normalized.NormalizeActionName(options.ActionName)
 	var actionLocking inward.ActionLockKind
@@ -148,6 +186,7 @@ func (actor *ActorInfo) RegisterAction(options RegisterActionOptions, callback a
 	action := ActionInfo{
 		ActionName: normalizedActionName,
 		Locking:    actionLocking,
+		Reentrant:  options.Reentrant,
 		Callback:   callback,
 	}
 	actor.Actions[normalizedActionName] = action
@@ -157,9 +196,10 @@ func (actor *ActorInfo) RegisterAction(options RegisterActionOptions, callback a
 func (api *Api) RegisterActor(options RegisterActorOptions) RegisteredActor {
 	normalizedActorType := normalized.NormalizeActorType(options.ActorType)
 	info := ActorInfo{
-		ActorType:   normalizedActorType,
-		Actions:     map[normalized.ActionName]ActionInfo{},
-		CallManager: api,
+		ActorType:        normalizedActorType,
+		MigrationVersion: options.MigrationVersion,
+		Actions:          map[normalized.ActionName]ActionInfo{},
+		CallManager:      api,
 	}
 
 	api.actorTypes[normalizedActorType] = info
@@ -187,7 +227,8 @@ func (api *Api) registerActors() {
 		actionDefs := map[normalized.ActionName]inward.ActionDef{}
 		for _, action := range actor.Actions {
 			actionDefs[action.ActionName] = inward.ActionDef{
-				Locking: action.Locking,
+				Locking:   action.Locking,
+				Reentrant: action.Reentrant,
 			}
 		}
 
@@ -206,9 +247,10 @@ func (api *Api) registerActors() {
 		api.containers = append(api.containers, container)
 
 		api.dispatcher.RegisterActorType(inward.ActorInfo{
-			ActorType: actor.ActorType,
-			Container: container,
-			Placement: actorregistry.ActorPlacement{},
+			ActorType:        actor.ActorType,
+			Container:        container,
+			Placement:        actorregistry.ActorPlacement{},
+			MigrationVersion: actor.MigrationVersion,
 		})
 	}
 }
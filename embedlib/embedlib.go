@@ -15,6 +15,7 @@ import "C"
 import (
 	"runtime/cgo"
 	"strings"
+	"time"
 
 	"github.com/darlean-io/darlean.go/base/actionerror"
 	"github.com/darlean-io/darlean.go/base/invoker"
@@ -43,6 +44,14 @@ func getApi(app Handle) *Api {
 	return handle.Value().(*Api)
 }
 
+// EnableReadinessGate holds incoming calls for at most timeoutMs milliseconds until the app knows the actor
+// registry. Must be invoked before StartApp.
+//
+//export EnableReadinessGate
+func EnableReadinessGate(app Handle, timeoutMs int) {
+	getApi(app).EnableReadinessGate(time.Duration(timeoutMs) * time.Millisecond)
+}
+
 //export StartApp
 func StartApp(app Handle) {
 	getApi(app).Start()
@@ -1,7 +1,8 @@
 package main
 
 type RegisterActorOptions struct {
-	ActorType string
+	ActorType        string
+	MigrationVersion string
 }
 
 type RegisterActorResult struct {
@@ -11,6 +12,8 @@ type RegisterActorResult struct {
 type RegisterActionOptions struct {
 	ActionName string
 	Locking    string
+	// Reentrant allows callbacks from the same call chain while the actor is locked.
+	Reentrant bool
 }
 
 type SubmitActionResultOptions struct {
@@ -13,9 +13,12 @@ import (
 	"github.com/darlean-io/darlean.go/core/backoff"
 	"github.com/darlean-io/darlean.go/core/invoke"
 	"github.com/darlean-io/darlean.go/core/inward"
+	"github.com/darlean-io/darlean.go/core/natsserver"
 	"github.com/darlean-io/darlean.go/core/natstransport"
 	"github.com/darlean-io/darlean.go/core/normalized"
 	"github.com/darlean-io/darlean.go/core/remoteactorregistry"
+	"github.com/darlean-io/darlean.go/core/shutdown"
+	"github.com/darlean-io/darlean.go/core/streams"
 	"github.com/darlean-io/darlean.go/core/transporthandler"
 	"github.com/darlean-io/darlean.go/utils/variant"
 )
@@ -114,10 +117,13 @@ type UnexistingActor struct {
 func main() {
 
 	const OUR_APP_ID = "client"
+	// Use natsserver.EMBEDDED (or "embedded:4500" to let other apps connect) to run the NATS server within this app
 	const NATS_ADDR = "localhost:4500"
 	HOSTS := []string{"server"}
+	// Set to a positive duration to hold incoming calls until the actor registry is known
+	const READINESS_TIMEOUT = 0 * time.Second
 
-	transport, err := natstransport.New(NATS_ADDR, OUR_APP_ID)
+	transport, natsServer, err := natsserver.Open(NATS_ADDR, OUR_APP_ID, natstransport.Options{})
 	if err != nil {
 		panic(err)
 	}
@@ -126,10 +132,18 @@ func main() {
 	registryFetcher := remoteactorregistry.NewFetcher(HOSTS, transportHandler)
 	registryPusher := remoteactorregistry.NewPusher(HOSTS, OUR_APP_ID, transportHandler)
 	disp := inward.NewDispatcher(registryPusher)
+	registryFetcher.EnableSubscription(OUR_APP_ID, disp)
+	if READINESS_TIMEOUT > 0 {
+		disp.SetReadinessGate(registryFetcher.Ready(), READINESS_TIMEOUT)
+	}
 
 	backoff := backoff.Exponential(1*time.Millisecond, 6, 4.0, 0.25)
 	invoker := invoke.NewDynamicInvoker(transportHandler, backoff, registryFetcher)
 
+	// Host the streams that this app writes, so that other apps can read them
+	streamsHost := streams.NewHost(OUR_APP_ID)
+	disp.RegisterActorType(streamsHost.ActorInfo())
+
 	transportHandler.Start(disp)
 	registryPusher.Start()
 	registryFetcher.Start()
@@ -180,8 +194,17 @@ func main() {
 	go toLowerCase(&invoker, "World")
 
 	time.Sleep(15 * time.Second)
-	container.Stop()
-	registryFetcher.Stop()
-	registryPusher.Stop()
-	transport.Stop()
+	err = shutdown.Graceful(shutdown.Options{
+		Pusher:     registryPusher,
+		Dispatcher: disp,
+		Components: []shutdown.Stopper{registryFetcher},
+		Transport:  transport,
+		Timeout:    5 * time.Second,
+	})
+	if err != nil {
+		fmt.Printf("Shutdown: %v\n", err)
+	}
+	if natsServer != nil {
+		natsServer.Shutdown()
+	}
 }
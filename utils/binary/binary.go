@@ -4,14 +4,20 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"strconv"
+
+	"github.com/goccy/go-json"
 )
 
 type BufferContext struct {
 	Uid     string
 	Buffers [][]byte
+	// Index of the next buffer for references without index (before JB01)
+	next int
 }
 
 type bufferContextKeyType int
@@ -24,8 +30,10 @@ func NewContext(ctx context.Context, data *BufferContext) context.Context {
 	return context.WithValue(ctx, bufferContextKey, data)
 }
 
+// FromContext returns the buffer context of ctx, or nil when ctx does not have one.
 func FromContext(ctx context.Context) *BufferContext {
-	return ctx.Value(bufferContextKey).(*BufferContext)
+	c, _ := ctx.Value(bufferContextKey).(*BufferContext)
+	return c
 }
 
 type Binary struct {
@@ -92,3 +100,52 @@ func newSeed() string {
 	}
 	return hex.EncodeToString(slice)
 }
+
+// reference is the json representation of a Binary that refers to a buffer outside of the json.
+type reference struct {
+	Uid      string  `json:"__b"`
+	Index    *int    `json:"i"`
+	B64      *string `json:"b64"`
+	Internal []byte
+}
+
+// UnmarshalJSON is invoked for binaries that are decoded via json.UnmarshalContext with a context that
+// contains the buffers of the message. The binary refers to its buffer without copying it. Inline buffers
+// (base64 encoded in the json) are decoded.
+func (binary *Binary) UnmarshalJSON(ctx context.Context, data []byte) error {
+	var ref reference
+	err := json.Unmarshal(data, &ref)
+	if err != nil {
+		return err
+	}
+	if ref.Uid == "" {
+		// Plain json representation of a Binary
+		binary.Internal = ref.Internal
+		return nil
+	}
+
+	c := FromContext(ctx)
+	if c == nil || c.Uid != ref.Uid {
+		return errors.New("binary: buffer reference does not match the message")
+	}
+	if ref.Index != nil {
+		if *ref.Index < 0 || *ref.Index >= len(c.Buffers) {
+			return fmt.Errorf("binary: invalid buffer index: %d", *ref.Index)
+		}
+		binary.Internal = c.Buffers[*ref.Index]
+	} else if ref.B64 == nil {
+		if c.next >= len(c.Buffers) {
+			return errors.New("binary: missing buffer")
+		}
+		binary.Internal = c.Buffers[c.next]
+		c.next++
+	}
+	if binary.Internal == nil && ref.B64 != nil {
+		decoded, err := base64.StdEncoding.DecodeString(*ref.B64)
+		if err != nil {
+			return err
+		}
+		binary.Internal = decoded
+	}
+	return nil
+}
@@ -1,8 +1,16 @@
 package fastproto
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/darlean-io/darlean.go/utils/binary"
 	"github.com/darlean-io/darlean.go/utils/jsonbinary"
@@ -14,28 +22,84 @@ import (
 
 const CHAR_CODE_ZERO_DIGITS = 'a'
 const CHAR_CODE_BUFFER = 'b'
+const CHAR_CODE_TIMESTAMP = 'd'
 const CHAR_CODE_FALSE = 'f'
+const CHAR_CODE_BIG_NUMBER = 'g'
+const CHAR_CODE_INTEGER = 'i'
 const CHAR_CODE_JSON = 'j'
+const CHAR_CODE_NULL = 'l'
 const CHAR_CODE_NUMBER = 'n'
 const CHAR_CODE_STRING = 's'
 const CHAR_CODE_TRUE = 't'
 const CHAR_CODE_UNDEFINED = 'u'
 
+// Null can be passed to [WriteVariant] to write an explicit null (as opposed to undefined, which is
+// written for nil). Both are read back as nil.
+type Null struct{}
+
 var bufferPool = new(pool.BufferPool)
-var bytesPool = jsonbinary.BytesPool(bufferPool)
 
-func WriteUnsignedInt(buf *bytes.Buffer, value int) error {
+// DEFAULT_MAX_LENGTH is the default maximum length of strings and binary data that are read.
+const DEFAULT_MAX_LENGTH = 64 * 1024 * 1024
+
+// Size of the first step in which strings and binary data are read. Every next step doubles the amount of
+// data read, so that memory is allocated in proportion to the data that is actually received, and not to
+// the length that the sender claims.
+const READ_STEP_SIZE = 64 * 1024
+
+// Maximum number of digits of an unsigned int (max uint32 has 10 digits).
+const MAX_UNSIGNED_INT_DIGITS = 10
+
+var ErrTooLong = errors.New("fastproto: length exceeds maximum")
+
+var maxLength atomic.Int64
+
+func init() {
+	maxLength.Store(DEFAULT_MAX_LENGTH)
+}
+
+// SetMaxLength sets the maximum length of strings and binary data that are read. Longer values result in
+// [ErrTooLong] before any memory is allocated for them.
+func SetMaxLength(length int) {
+	maxLength.Store(int64(length))
+}
+
+// Writer is the destination of the Write functions. Satisfied by *bytes.Buffer and *bufio.Writer.
+type Writer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// Reader is the source of the Read functions. Satisfied by *bytes.Buffer, *bytes.Reader and *bufio.Reader.
+type Reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// NewReader returns r when it satisfies [Reader], and a buffered reader around r otherwise.
+func NewReader(r io.Reader) Reader {
+	if reader, ok := r.(Reader); ok {
+		return reader
+	}
+	return bufio.NewReader(r)
+}
+
+func WriteUnsignedInt(buf Writer, value int) error {
 	if value == 0 {
 		return buf.WriteByte(CHAR_CODE_ZERO_DIGITS)
 	}
 	str := strconv.FormatUint(uint64(value), 10)
 	strlen := len(str)
-	buf.WriteByte(CHAR_CODE_ZERO_DIGITS + byte(strlen))
-	buf.WriteString(str)
-	return nil
+	err := buf.WriteByte(CHAR_CODE_ZERO_DIGITS + byte(strlen))
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteString(str)
+	return err
 }
 
-func ReadUnsignedInt(buf *bytes.Buffer) (uint32, error) {
+func ReadUnsignedInt(buf Reader) (uint32, error) {
 	lenbyte, err := buf.ReadByte()
 	if err != nil {
 		return 0, err
@@ -43,32 +107,74 @@ func ReadUnsignedInt(buf *bytes.Buffer) (uint32, error) {
 	if lenbyte == CHAR_CODE_ZERO_DIGITS {
 		return 0, nil
 	}
+	if lenbyte < CHAR_CODE_ZERO_DIGITS || lenbyte > CHAR_CODE_ZERO_DIGITS+MAX_UNSIGNED_INT_DIGITS {
+		return 0, fmt.Errorf("fastproto: invalid unsigned int length: %d", lenbyte)
+	}
 	strlen := lenbyte - CHAR_CODE_ZERO_DIGITS
-	localbuf := make([]byte, strlen)
-	_, err = buf.Read(localbuf)
+	var localbuf [MAX_UNSIGNED_INT_DIGITS]byte
+	_, err = io.ReadFull(buf, localbuf[:strlen])
 	if err != nil {
-		return 0, err
+		return 0, unexpectedEOF(err)
 	}
-	parsed, err := strconv.ParseUint(string(localbuf), 10, 32)
+	parsed, err := strconv.ParseUint(string(localbuf[:strlen]), 10, 32)
 	return uint32(parsed), err
 }
 
-func WriteString(buf *bytes.Buffer, value *string) error {
+// readLength reads a length and checks it against the maximum length.
+func readLength(buf Reader) (uint32, error) {
+	length, err := ReadUnsignedInt(buf)
+	if err != nil {
+		return 0, err
+	}
+	if int64(length) > maxLength.Load() {
+		return 0, ErrTooLong
+	}
+	return length, nil
+}
+
+// readBytes reads length bytes in steps (see [READ_STEP_SIZE]).
+func readBytes(buf Reader, length uint32) ([]byte, error) {
+	n := int(length)
+	data := make([]byte, 0, min(n, READ_STEP_SIZE))
+	for len(data) < n {
+		step := min(n-len(data), max(len(data), READ_STEP_SIZE))
+		data = slices.Grow(data, step)
+		start := len(data)
+		data = data[:start+step]
+		_, err := io.ReadFull(buf, data[start:])
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	return data, nil
+}
+
+// unexpectedEOF converts io.EOF into io.ErrUnexpectedEOF for reads that started a value.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func WriteString(buf Writer, value *string) error {
 	if (value == nil) || (len(*value) == 0) {
 		return WriteUnsignedInt(buf, 0)
 	}
-	WriteUnsignedInt(buf, len(*value))
-	buf.WriteString(*value)
-	return nil
+	err := WriteUnsignedInt(buf, len(*value))
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteString(*value)
+	return err
 }
 
-func ReadString(buf *bytes.Buffer) (*string, error) {
-	strlen, err := ReadUnsignedInt(buf)
+func ReadString(buf Reader) (*string, error) {
+	strlen, err := readLength(buf)
 	if err != nil {
 		return nil, err
 	}
-	localbuf := make([]byte, strlen)
-	_, err = buf.Read(localbuf)
+	localbuf, err := readBytes(buf, strlen)
 	if err != nil {
 		return nil, err
 	}
@@ -76,35 +182,40 @@ func ReadString(buf *bytes.Buffer) (*string, error) {
 	return &asString, nil
 }
 
-func WriteChar(buf *bytes.Buffer, value int) error {
+func WriteChar(buf Writer, value int) error {
 	return buf.WriteByte(byte(value))
 }
 
-func ReadChar(buf *bytes.Buffer) (int, error) {
+func ReadChar(buf Reader) (int, error) {
 	value, err := buf.ReadByte()
 	return int(value), err
 }
 
-func WriteBinary(buf *bytes.Buffer, value *[]byte) error {
+func WriteBinary(buf Writer, value *[]byte) error {
 	if (value == nil) || (len(*value) == 0) {
 		return WriteUnsignedInt(buf, 0)
 	}
-	WriteUnsignedInt(buf, len(*value))
-	buf.Write(*value)
-	return nil
+	err := WriteUnsignedInt(buf, len(*value))
+	if err != nil {
+		return err
+	}
+	_, err = buf.Write(*value)
+	return err
 }
 
-func ReadBinary(buf *bytes.Buffer) (*[]byte, error) {
-	strlen, err := ReadUnsignedInt(buf)
+func ReadBinary(buf Reader) (*[]byte, error) {
+	strlen, err := readLength(buf)
+	if err != nil {
+		return nil, err
+	}
+	localbuf, err := readBytes(buf, strlen)
 	if err != nil {
 		return nil, err
 	}
-	localbuf := make([]byte, strlen)
-	_, err = buf.Read(localbuf)
-	return &localbuf, err
+	return &localbuf, nil
 }
 
-func WriteJson(buf *bytes.Buffer, value any) error {
+func WriteJson(buf Writer, value any) error {
 	if value == nil {
 		return WriteBinary(buf, nil)
 	}
@@ -113,11 +224,10 @@ func WriteJson(buf *bytes.Buffer, value any) error {
 		return err
 	}
 	defer bufferPool.Put(serialized)
-	WriteBinary(buf, &serialized)
-	return nil
+	return WriteBinary(buf, &serialized)
 }
 
-func ReadJson(buf *bytes.Buffer) (variant.Assignable, error) {
+func ReadJson(buf Reader) (variant.Assignable, error) {
 	data, err := ReadBinary(buf)
 	if err != nil {
 		return nil, err
@@ -129,64 +239,171 @@ func ReadJson(buf *bytes.Buffer) (variant.Assignable, error) {
 	return jsonvariant.FromJson(*data), err
 }
 
-func WriteVariant(buf *bytes.Buffer, value any) error {
+// WriteVariant writes value with the type codes that all receivers understand: int64 and float64 are written
+// as number, and values of types other than string, bool and binary data as json. Use [WriteVariantExtended]
+// for receivers that support the integer, big number, timestamp and null type codes.
+func WriteVariant(buf Writer, value any) error {
 	switch v := (value).(type) {
-	case nil:
-		return WriteChar(buf, CHAR_CODE_UNDEFINED)
 	case int64:
-		WriteChar(buf, CHAR_CODE_NUMBER)
-		str := strconv.FormatInt(v, 10)
-		return WriteString(buf, &str)
+		return writeCoded(buf, CHAR_CODE_NUMBER, strconv.FormatInt(v, 10))
 	case float64:
-		WriteChar(buf, CHAR_CODE_NUMBER)
-		str := strconv.FormatFloat(v, 'e', 15, 64)
-		return WriteString(buf, &str)
+		return writeFloat(buf, v)
+	case Null:
+		return writeJsonVariant(buf, nil)
+	}
+	return writeBasicVariant(buf, value)
+}
+
+// WriteVariantExtended writes value with a type code that is derived from its type. Integers of all widths
+// are written as integer, float32 and float64 as number, *big.Int as big number and time.Time as timestamp
+// (with millisecond precision). Values of other types are written like [WriteVariant] does. Older receivers
+// can not read these type codes, so callers must only use it for receivers that support them.
+func WriteVariantExtended(buf Writer, value any) error {
+	switch v := (value).(type) {
+	case Null:
+		return WriteChar(buf, CHAR_CODE_NULL)
+	case int:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatInt(int64(v), 10))
+	case int8:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatInt(int64(v), 10))
+	case int16:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatInt(int64(v), 10))
+	case int32:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatInt(int64(v), 10))
+	case int64:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatInt(v, 10))
+	case uint:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatUint(uint64(v), 10))
+	case uint8:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		return writeCoded(buf, CHAR_CODE_INTEGER, strconv.FormatUint(v, 10))
+	case float32:
+		return writeFloat(buf, float64(v))
+	case float64:
+		return writeFloat(buf, v)
+	case *big.Int:
+		if v == nil {
+			return WriteChar(buf, CHAR_CODE_NULL)
+		}
+		return writeCoded(buf, CHAR_CODE_BIG_NUMBER, v.String())
+	case big.Int:
+		return writeCoded(buf, CHAR_CODE_BIG_NUMBER, v.String())
+	case time.Time:
+		return writeCoded(buf, CHAR_CODE_TIMESTAMP, strconv.FormatInt(v.UnixMilli(), 10))
+	}
+	return writeBasicVariant(buf, value)
+}
+
+// writeBasicVariant writes the types for which the type code is the same for all receivers.
+func writeBasicVariant(buf Writer, value any) error {
+	switch v := (value).(type) {
+	case nil:
+		return WriteChar(buf, CHAR_CODE_UNDEFINED)
 	case string:
-		WriteChar(buf, CHAR_CODE_STRING)
-		return WriteString(buf, &v)
+		return writeCoded(buf, CHAR_CODE_STRING, v)
 	case bool:
 		if v {
 			return WriteChar(buf, CHAR_CODE_TRUE)
 		}
 		return WriteChar(buf, CHAR_CODE_FALSE)
 	case bytes.Buffer:
-		WriteChar(buf, CHAR_CODE_BUFFER)
-		b := v.Bytes()
-		return WriteBinary(buf, &b)
+		return writeBinaryVariant(buf, v.Bytes())
 	case []byte:
-		WriteChar(buf, CHAR_CODE_BUFFER)
-		return WriteBinary(buf, &v)
+		return writeBinaryVariant(buf, v)
 	case binary.Binary:
-		WriteChar(buf, CHAR_CODE_BUFFER)
-		b := v.Bytes()
-		return WriteBinary(buf, &b)
+		return writeBinaryVariant(buf, v.Bytes())
 	default:
-		WriteChar(buf, CHAR_CODE_JSON)
-		return WriteJson(buf, v)
+		return writeJsonVariant(buf, v)
+	}
+}
+
+// writeCoded writes code followed by str.
+func writeCoded(buf Writer, code int, str string) error {
+	err := WriteChar(buf, code)
+	if err != nil {
+		return err
+	}
+	return WriteString(buf, &str)
+}
+
+func writeBinaryVariant(buf Writer, value []byte) error {
+	err := WriteChar(buf, CHAR_CODE_BUFFER)
+	if err != nil {
+		return err
+	}
+	return WriteBinary(buf, &value)
+}
+
+func writeJsonVariant(buf Writer, value any) error {
+	err := WriteChar(buf, CHAR_CODE_JSON)
+	if err != nil {
+		return err
 	}
+	return WriteJson(buf, value)
+}
+
+func writeFloat(buf Writer, value float64) error {
+	return writeCoded(buf, CHAR_CODE_NUMBER, strconv.FormatFloat(value, 'e', 15, 64))
 }
 
 /*
-ReadVariant reads a value from buf that was previously stored via [WriteVariant].
+ReadVariant reads a value from buf that was previously stored via [WriteVariant] or [WriteVariantExtended]. Returns an error
+(and never panics) for unknown type codes and malformed values.
 */
-func ReadVariant(buf *bytes.Buffer) (variant.Assignable, error) {
+func ReadVariant(buf Reader) (variant.Assignable, error) {
 	kind, err := ReadChar(buf)
 	if err != nil {
 		return nil, err
 	}
 	switch kind {
-	case CHAR_CODE_UNDEFINED:
+	case CHAR_CODE_UNDEFINED, CHAR_CODE_NULL:
 		return nil, nil
 	case CHAR_CODE_STRING:
 		val, err := ReadString(buf)
-		return variant.FromString(*val), err
+		if err != nil {
+			return nil, err
+		}
+		return variant.FromString(*val), nil
 	case CHAR_CODE_NUMBER:
 		str, err := ReadString(buf)
 		if err != nil {
 			return nil, err
 		}
 		value, err := strconv.ParseFloat(*str, 64)
-		return variant.FromFloatNumber(value), err
+		if err != nil {
+			return nil, fmt.Errorf("fastproto: invalid number: %w", err)
+		}
+		return variant.FromFloatNumber(value), nil
+	case CHAR_CODE_INTEGER, CHAR_CODE_BIG_NUMBER:
+		str, err := ReadString(buf)
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseInt(*str, 10, 64)
+		if err == nil {
+			return variant.FromInt(value), nil
+		}
+		// Does not fit in an int64 (like large uint64 values or big numbers)
+		number, ok := new(big.Int).SetString(*str, 10)
+		if !ok {
+			return nil, fmt.Errorf("fastproto: invalid integer: %q", *str)
+		}
+		return variant.FromBigInt(number), nil
+	case CHAR_CODE_TIMESTAMP:
+		str, err := ReadString(buf)
+		if err != nil {
+			return nil, err
+		}
+		millis, err := strconv.ParseInt(*str, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("fastproto: invalid timestamp: %w", err)
+		}
+		return variant.FromTime(time.UnixMilli(millis)), nil
 	case CHAR_CODE_JSON:
 		return ReadJson(buf)
 	case CHAR_CODE_FALSE:
@@ -195,8 +412,11 @@ func ReadVariant(buf *bytes.Buffer) (variant.Assignable, error) {
 		return variant.FromBool(true), nil
 	case CHAR_CODE_BUFFER:
 		val, err := ReadBinary(buf)
-		return variant.FromBytes(*val), err
+		if err != nil {
+			return nil, err
+		}
+		return variant.FromBytes(*val), nil
 	default:
-		panic("Not supported")
+		return nil, fmt.Errorf("fastproto: unsupported variant type code: %q", rune(kind))
 	}
 }
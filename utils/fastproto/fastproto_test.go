@@ -0,0 +1,231 @@
+package fastproto
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"runtime"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/darlean-io/darlean.go/utils/checks"
+)
+
+func TestShortReads(t *testing.T) {
+	var buf bytes.Buffer
+	value := "Hello, streaming world"
+	data := []byte{1, 2, 3, 4, 5}
+	WriteString(&buf, &value)
+	WriteBinary(&buf, &data)
+	WriteUnsignedInt(&buf, 4294967295)
+
+	// A reader that returns one byte per Read call
+	reader := NewReader(iotest.OneByteReader(&buf))
+	s, err := ReadString(reader)
+	checks.Equal(t, nil, err, "Read string")
+	checks.Equal(t, value, *s, "String value")
+	b, err := ReadBinary(reader)
+	checks.Equal(t, nil, err, "Read binary")
+	checks.Equal(t, data, *b, "Binary value")
+	n, err := ReadUnsignedInt(reader)
+	checks.Equal(t, nil, err, "Read unsigned int")
+	checks.Equal(t, uint32(4294967295), n, "Unsigned int value")
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	value := "Truncated"
+	WriteString(&buf, &value)
+	truncated := buf.Bytes()[:buf.Len()-2]
+
+	_, err := ReadString(bytes.NewBuffer(truncated))
+	checks.Equal(t, io.ErrUnexpectedEOF, err, "Truncated string")
+	_, err = ReadBinary(bytes.NewBuffer(truncated))
+	checks.Equal(t, io.ErrUnexpectedEOF, err, "Truncated binary")
+	_, err = ReadUnsignedInt(bytes.NewBufferString("c1"))
+	checks.Equal(t, io.ErrUnexpectedEOF, err, "Truncated unsigned int")
+	_, err = ReadUnsignedInt(bytes.NewBufferString("z123"))
+	checks.IsNotNil(t, err, "Invalid unsigned int length")
+}
+
+func TestMaxLength(t *testing.T) {
+	defer SetMaxLength(DEFAULT_MAX_LENGTH)
+	SetMaxLength(4)
+
+	var buf bytes.Buffer
+	value := "Too long"
+	WriteString(&buf, &value)
+	_, err := ReadString(&buf)
+	checks.Equal(t, ErrTooLong, err, "String longer than maximum")
+
+	// A huge length must be rejected before the data is allocated or read
+	_, err = ReadBinary(bytes.NewBufferString("j999999999"))
+	checks.Equal(t, ErrTooLong, err, "Binary longer than maximum")
+}
+
+func TestClaimedLength(t *testing.T) {
+	// A tiny input that claims a length just below the maximum must not allocate that length
+	var buf bytes.Buffer
+	WriteUnsignedInt(&buf, DEFAULT_MAX_LENGTH-1)
+	buf.WriteString("tiny")
+	input := buf.Bytes()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ReadBinary(bytes.NewBuffer(input))
+	checks.Equal(t, io.ErrUnexpectedEOF, err, "Binary is truncated")
+	_, err = ReadString(bytes.NewBuffer(input))
+	checks.Equal(t, io.ErrUnexpectedEOF, err, "String is truncated")
+	runtime.ReadMemStats(&after)
+	checks.Equal(t, true, after.TotalAlloc-before.TotalAlloc < 1024*1024, "Allocation is bounded by the input")
+
+	// Lengths beyond the first step are read completely
+	data := make([]byte, READ_STEP_SIZE*5+7)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	buf.Reset()
+	WriteBinary(&buf, &data)
+	result, err := ReadBinary(NewReader(iotest.HalfReader(&buf)))
+	checks.Equal(t, nil, err, "Read large binary")
+	checks.Equal(t, data, *result, "Large binary")
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := bufio.NewWriter(&buf)
+	WriteVariant(writer, "value")
+	WriteVariant(writer, true)
+	writer.Flush()
+
+	reader := NewReader(&buf)
+	v, err := ReadVariant(reader)
+	checks.Equal(t, nil, err, "Read variant")
+	checks.Equal(t, "value", v, "String variant")
+	v, _ = ReadVariant(reader)
+	checks.Equal(t, true, v, "Bool variant")
+}
+
+func roundtrip(t *testing.T, value any, target any) {
+	var buf bytes.Buffer
+	err := WriteVariantExtended(&buf, value)
+	checks.Equal(t, nil, err, "Write variant")
+	v, err := ReadVariant(&buf)
+	checks.Equal(t, nil, err, "Read variant")
+	err = v.AssignTo(target)
+	checks.Equal(t, nil, err, "Assign variant")
+}
+
+func TestNumericVariants(t *testing.T) {
+	var i int
+	roundtrip(t, int8(-42), &i)
+	checks.Equal(t, -42, i, "int8")
+	roundtrip(t, uint16(42), &i)
+	checks.Equal(t, 42, i, "uint16")
+
+	var i64 int64
+	roundtrip(t, int64(math.MaxInt64), &i64)
+	checks.Equal(t, int64(math.MaxInt64), i64, "int64 without loss of precision")
+
+	var u64 uint64
+	roundtrip(t, uint64(math.MaxUint64), &u64)
+	checks.Equal(t, uint64(math.MaxUint64), u64, "uint64 beyond int64")
+
+	var f float64
+	roundtrip(t, 42, &f)
+	checks.Equal(t, 42.0, f, "int to float")
+	roundtrip(t, float32(1.5), &f)
+	checks.Equal(t, 1.5, f, "float32")
+
+	var u8 uint8
+	var buf bytes.Buffer
+	WriteVariantExtended(&buf, 300)
+	v, _ := ReadVariant(&buf)
+	checks.IsNotNil(t, v.AssignTo(&u8), "Overflow of target")
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	var b *big.Int
+	roundtrip(t, huge, &b)
+	checks.Equal(t, huge.String(), b.String(), "Big number")
+	roundtrip(t, *big.NewInt(7), &i)
+	checks.Equal(t, 7, i, "Small big number to int")
+
+	var a any
+	roundtrip(t, 42, &a)
+	checks.Equal(t, int64(42), a, "int to any")
+	n, ok := a.(int64)
+	checks.Equal(t, true, ok, "int arrives in any as int64")
+	checks.Equal(t, int64(42), n, "int64 value")
+}
+
+func TestOtherVariants(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	var ts time.Time
+	roundtrip(t, now, &ts)
+	checks.Equal(t, true, now.Equal(ts), "Timestamp")
+
+	var buf bytes.Buffer
+	WriteVariantExtended(&buf, Null{})
+	v, err := ReadVariant(&buf)
+	checks.Equal(t, nil, err, "Read null")
+	checks.Equal(t, true, v == nil, "Null is nil")
+}
+
+func TestCompatibleVariants(t *testing.T) {
+	// WriteVariant only uses the type codes that older receivers understand
+	for _, c := range []struct {
+		value any
+		code  byte
+	}{
+		{int64(42), CHAR_CODE_NUMBER},
+		{42.5, CHAR_CODE_NUMBER},
+		{42, CHAR_CODE_JSON},
+		{uint64(42), CHAR_CODE_JSON},
+		{big.NewInt(42), CHAR_CODE_JSON},
+		{time.UnixMilli(0), CHAR_CODE_JSON},
+		{Null{}, CHAR_CODE_JSON},
+	} {
+		var buf bytes.Buffer
+		checks.Equal(t, nil, WriteVariant(&buf, c.value), "Write variant")
+		checks.Equal(t, c.code, buf.Bytes()[0], fmt.Sprintf("Type code for %T", c.value))
+		_, err := ReadVariant(&buf)
+		checks.Equal(t, nil, err, "Read variant")
+	}
+
+	var i64 int64
+	var buf bytes.Buffer
+	WriteVariant(&buf, int64(1234567))
+	v, _ := ReadVariant(&buf)
+	v.AssignTo(&i64)
+	checks.Equal(t, int64(1234567), i64, "int64 as number")
+
+	buf.Reset()
+	WriteVariant(&buf, Null{})
+	v, _ = ReadVariant(&buf)
+	checks.Equal(t, true, v == nil, "Null as json is nil")
+}
+
+// failingWriter fails every write, to check that errors are returned.
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error)       { return 0, io.ErrShortWrite }
+func (w failingWriter) WriteByte(c byte) error            { return io.ErrShortWrite }
+func (w failingWriter) WriteString(s string) (int, error) { return 0, io.ErrShortWrite }
+
+func TestWriteErrors(t *testing.T) {
+	for _, value := range []any{nil, "s", true, int64(1), 1, time.Now(), []byte{1}, map[string]int{}} {
+		checks.IsNotNil(t, WriteVariant(failingWriter{}, value), fmt.Sprintf("Write error for %T", value))
+		checks.IsNotNil(t, WriteVariantExtended(failingWriter{}, value), fmt.Sprintf("Extended write error for %T", value))
+	}
+}
+
+func TestInvalidVariants(t *testing.T) {
+	for _, data := range []string{"x", "s", "sc1", "nc1e", "id1.5", "dbxx", "b"} {
+		_, err := ReadVariant(bytes.NewBufferString(data))
+		checks.IsNotNil(t, err, "Invalid variant "+data)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -78,78 +79,114 @@ func Serialize(data any, pool BytesPool) (result []byte, err error) {
 	return
 }
 
+// Deserialize decodes data that was produced by [Serialize] into value. Binary fields are decoded in the
+// same pass as the rest of the value and refer to the buffers within data without copying them, so data
+// must not be modified while value is in use.
+//
+// When value is (a pointer to) an interface, or a map or slice of interfaces, binaries are represented as
+// maps with an [binary.INTERNAL_FIELD] field instead.
 func Deserialize(data []byte, value any) error {
-	buf := bytes.NewBuffer(data)
-	header, err := buf.ReadString('\n')
-	if err != nil {
-		return err
+	headerLen := bytes.IndexByte(data, '\n')
+	if headerLen < 0 {
+		return errors.New("jsonbinary: invalid header")
 	}
-
+	header := string(data[:headerLen])
 	if !strings.HasPrefix(header, c_JB_MAGIC) {
-		return fmt.Errorf("jsonbinary: invalid magic: %s", header[0:2])
+		return fmt.Errorf("jsonbinary: invalid magic: %.2s", header)
 	}
-	if len(header) < len(c_JB_HEADER) {
+	if len(header) < len(c_JB_TAG) {
 		return errors.New("jsonbinary: invalid header")
 	}
 	if header[2] > c_JB_MAJOR {
 		return fmt.Errorf("jsonbinary: unsupported major version: %s", header[2:3])
 	}
 
-	if len(header) == len(c_JB_HEADER) {
+	body := data[headerLen+1:]
+	if len(header) == len(c_JB_TAG) {
 		// No buffers
-		return json.Unmarshal(buf.Bytes(), value)
+		return json.Unmarshal(body, value)
 	}
 
-	parts := strings.Split(header[:len(header)-1], ";")
-	if len(parts) == 0 {
-		return errors.New("jsonbinary: invalid header")
+	bufferdata, jsonData, err := parseBuffers(header, body)
+	if err != nil {
+		return err
 	}
-	if len(parts) >= 3 {
-		lengths := strings.Split(parts[3], ",")
 
-		if len(lengths) > 0 {
-			jsonLen, err := strconv.ParseInt(parts[2], 10, 32)
-			if err != nil {
-				return err
-			}
-			offset := len(header) + int(jsonLen) + 1
-			buffers := make([][]byte, len(lengths))
-			for i, length := range lengths {
-				if length == "" {
-					// Inline b64, not yet supported
-					continue
-				}
-				lengthInt, err := strconv.ParseInt(length, 10, 32)
-				if err != nil {
-					return err
-				}
-				buffer := data[offset : offset+int(lengthInt)]
-				buffers[i] = buffer
-				offset = offset + int(lengthInt) + 1
-			}
-			bufferdata := binary.BufferContext{
-				Uid:     parts[1],
-				Buffers: buffers,
-			}
-			var temp any
-			// Unmarshall to new "any" so that we receive plain maps instead of filled-in structs
-			// that are harder to iterate
-			offset = len(header)
-			err = json.Unmarshal(data[offset:offset+int(jsonLen)], &temp)
-			if err != nil {
-				return err
-			}
-			// Find all buffer occurrances and add the "data" to them
-			err = analyzeBufferStructs(&bufferdata, temp)
-			if err != nil {
-				return err
-			}
-			// Try to map the plain structure onto the result value
-			variant.Assign(temp, value)
-			return nil
+	if needsTreeWalk(value) {
+		return deserializeTree(bufferdata, jsonData, value)
+	}
+	ctx := binary.NewContext(context.Background(), bufferdata)
+	return json.UnmarshalContext(ctx, jsonData, value)
+}
+
+// parseBuffers parses the header "JB01;uid;jsonlength;length1,length2,..." and returns the buffers and the
+// json part of body. The buffers refer to body. An empty length indicates a buffer that is inline (b64) in
+// the json.
+func parseBuffers(header string, body []byte) (*binary.BufferContext, []byte, error) {
+	parts := strings.Split(header, ";")
+	if len(parts) < 4 {
+		return nil, nil, errors.New("jsonbinary: invalid header")
+	}
+	jsonLen, err := strconv.Atoi(parts[2])
+	if err != nil || jsonLen < 0 || jsonLen > len(body) {
+		return nil, nil, fmt.Errorf("jsonbinary: invalid json length: %s", parts[2])
+	}
+
+	lengths := strings.Split(parts[3], ",")
+	buffers := make([][]byte, len(lengths))
+	offset := jsonLen + 1
+	for i, length := range lengths {
+		if length == "" {
+			continue
+		}
+		n, err := strconv.Atoi(length)
+		if err != nil || n < 0 || offset+n > len(body) {
+			return nil, nil, fmt.Errorf("jsonbinary: invalid buffer length: %s", length)
 		}
+		// Limit the capacity so that appending to a buffer cannot overwrite the next one
+		buffers[i] = body[offset : offset+n : offset+n]
+		offset += n + 1
+	}
+
+	bufferdata := binary.BufferContext{
+		Uid:     parts[1],
+		Buffers: buffers,
+	}
+	return &bufferdata, body[:jsonLen], nil
+}
+
+// needsTreeWalk returns whether value can receive binaries as plain values (like maps), so that
+// the binaries are not decoded via [binary.Binary.UnmarshalJSON].
+func needsTreeWalk(value any) bool {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
 	}
-	return json.Unmarshal(buf.Bytes(), value)
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return t.Elem().Kind() == reflect.Interface
+	}
+	return false
+}
+
+// deserializeTree decodes jsonData into plain maps and slices, adds the buffers to the binary structs
+// within and assigns the result to value.
+func deserializeTree(bufferdata *binary.BufferContext, jsonData []byte, value any) error {
+	var temp any
+	err := json.Unmarshal(jsonData, &temp)
+	if err != nil {
+		return err
+	}
+	err = analyzeBufferStructs(bufferdata, temp)
+	if err != nil {
+		return err
+	}
+	return variant.Assign(temp, value)
 }
 
 func analyzeBufferStructs(bufferData *binary.BufferContext, value any) error {
@@ -163,24 +200,34 @@ func analyzeBufferStructs(bufferData *binary.BufferContext, value any) error {
 				// Check whether we have an index (introduced in JB01). If not, fall back
 				// to the original behaviour of processen buffers in order. But this may not
 				// be ok because go shuffles map items.
+				var buffer []byte
 				idx, has := m["i"]
 				if has {
-					m[binary.INTERNAL_FIELD] = bufferData.Buffers[int(idx.(float64))]
-				} else {
-					m[binary.INTERNAL_FIELD] = bufferData.Buffers[0]
+					i, ok := idx.(float64)
+					if !ok || i < 0 || int(i) >= len(bufferData.Buffers) {
+						return fmt.Errorf("jsonbinary: invalid buffer index: %v", idx)
+					}
+					buffer = bufferData.Buffers[int(i)]
+				} else if len(bufferData.Buffers) > 0 {
+					buffer = bufferData.Buffers[0]
 					bufferData.Buffers = bufferData.Buffers[1:]
 				}
 
-				if m[binary.INTERNAL_FIELD] == nil {
+				if buffer == nil {
 					b64, has := m["b64"]
 					if has {
-						decoded, err := base64.StdEncoding.DecodeString(b64.(string))
+						str, ok := b64.(string)
+						if !ok {
+							return errors.New("jsonbinary: invalid inline buffer")
+						}
+						decoded, err := base64.StdEncoding.DecodeString(str)
 						if err != nil {
 							return err
 						}
-						m[binary.INTERNAL_FIELD] = decoded
+						buffer = decoded
 					}
 				}
+				m[binary.INTERNAL_FIELD] = buffer
 			}
 			return nil
 		}
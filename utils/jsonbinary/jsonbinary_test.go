@@ -2,6 +2,7 @@ package jsonbinary
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/darlean-io/darlean.go/utils/checks"
@@ -59,6 +60,89 @@ func TestToAny(t *testing.T) {
 	checks.Equal(t, []byte{'D', 'E'}, aaa.A2.Bytes(), "Subsequent binary field")
 }
 
+func TestZeroCopy(t *testing.T) {
+	a := A{
+		A0: "Hello",
+		A1: binary.FromBytes([]byte{'A', 'B', 'C'}),
+		A2: binary.FromBytes([]byte{'D', 'E'}),
+	}
+	b, _ := Serialize(&a, nil)
+
+	var aa A
+	err := Deserialize(b, &aa)
+	checks.Equal(t, nil, err, "Deserialize")
+	aa.A1.Bytes()[0] = 'X'
+	checks.Equal(t, true, strings.Contains(string(b), "XBC"), "Binary refers to the serialized data")
+	checks.Equal(t, 3, cap(aa.A1.Bytes()), "Capacity of buffer is limited")
+}
+
+func TestInline(t *testing.T) {
+	// Second buffer is inline (empty length) and base64-encoded in the json
+	json := `{"A0":"Hello","A1":{"__b":"uid","i":0},"A2":{"__b":"uid","i":1,"b64":"REU="}}`
+	data := fmt.Sprintf("JB01;uid;%d;3,\n%s\nABC\n", len(json), json)
+
+	var aa A
+	err := Deserialize([]byte(data), &aa)
+	checks.Equal(t, nil, err, "Deserialize typed")
+	checks.Equal(t, []byte{'A', 'B', 'C'}, aa.A1.Bytes(), "Binary buffer")
+	checks.Equal(t, []byte{'D', 'E'}, aa.A2.Bytes(), "Inline buffer")
+
+	var any0 any
+	err = Deserialize([]byte(data), &any0)
+	checks.Equal(t, nil, err, "Deserialize to any")
+	var aaa A
+	variant.Assign(any0, &aaa)
+	checks.Equal(t, []byte{'D', 'E'}, aaa.A2.Bytes(), "Inline buffer via any")
+}
+
+func TestErrors(t *testing.T) {
+	a := A{A0: "Hello", A1: binary.FromBytes([]byte{'A'})}
+	b, _ := Serialize(&a, nil)
+
+	var wrongType struct{ A0 int }
+	checks.IsNotNil(t, Deserialize(b, &wrongType), "Type mismatch for typed target")
+	var wrongAny []any
+	checks.IsNotNil(t, Deserialize(b, &wrongAny), "Assignment error for tree target")
+
+	for _, data := range []string{"", "XX01\n", "JB01;uid;100;1\n{}\nA\n", "JB01;uid;2;100\n{}\nA\n", "JB01;uid\n{}", `JB01;other;27;1` + "\n" + `{"A1":{"__b":"uid","i":0}}` + "\nA\n"} {
+		var aa A
+		checks.IsNotNil(t, Deserialize([]byte(data), &aa), "Invalid data "+data)
+	}
+}
+
+func BenchmarkDeserialize(bench *testing.B) {
+	for _, size := range []int{20, 1000, 100000} {
+		a := A{
+			A0: "Hello",
+			A1: binary.FromBytes(make([]byte, size)),
+			A2: binary.FromBytes(make([]byte, size)),
+		}
+		b, _ := Serialize(&a, nil)
+		headerLen := strings.IndexByte(string(b), '\n')
+
+		bench.Run(fmt.Sprintf("SinglePass,Length=%v", size), func(bench *testing.B) {
+			for i := 0; i < bench.N; i++ {
+				var aa A
+				err := Deserialize(b, &aa)
+				if err != nil {
+					panic(err)
+				}
+			}
+		})
+
+		bench.Run(fmt.Sprintf("TreeWalk,Length=%v", size), func(bench *testing.B) {
+			for i := 0; i < bench.N; i++ {
+				var aa A
+				bufferdata, jsonData, _ := parseBuffers(string(b[:headerLen]), b[headerLen+1:])
+				err := deserializeTree(bufferdata, jsonData, &aa)
+				if err != nil {
+					panic(err)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkJsonBinary(bench *testing.B) {
 	sizes := []int{-1, 0, 20, 100, 1000, 10000, 100000, 1000000}
 	p := new(pool.BufferPool)
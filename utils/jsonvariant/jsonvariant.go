@@ -18,7 +18,15 @@ func (data jsonVariant) AssignTo(target any) error {
 }
 
 func (data jsonVariant) AssignToReflectValue(targetVal *reflect.Value) error {
-	return jsonbinary.Deserialize(data, targetVal)
+	// Deserialize into a new value of the proper type, because the deserializer does
+	// not understand reflect values.
+	ptr := reflect.New(targetVal.Type())
+	err := jsonbinary.Deserialize(data, ptr.Interface())
+	if err != nil {
+		return err
+	}
+	targetVal.Set(ptr.Elem())
+	return nil
 }
 
 func (data jsonVariant) MarshalJSON() ([]byte, error) {
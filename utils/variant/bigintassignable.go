@@ -0,0 +1,96 @@
+package variant
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+)
+
+type bigIntVariant struct {
+	value *big.Int
+}
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+func (data bigIntVariant) AssignTo(target any) error {
+	targetVal := reflect.Indirect(reflect.ValueOf(target))
+	return data.AssignToReflectValue(&targetVal)
+}
+
+func (data bigIntVariant) AssignToReflectValue(targetVal *reflect.Value) error {
+	if targetVal.Type() == bigIntType {
+		targetVal.Set(reflect.ValueOf(*new(big.Int).Set(data.value)))
+		return nil
+	}
+	if targetVal.Type() == reflect.PointerTo(bigIntType) {
+		targetVal.Set(reflect.ValueOf(new(big.Int).Set(data.value)))
+		return nil
+	}
+
+	targetKind := getKind(*targetVal)
+
+	if targetKind == reflect.Int {
+		if !data.value.IsInt64() || targetVal.OverflowInt(data.value.Int64()) {
+			return errors.New("variant: big int does not fit in target")
+		}
+		targetVal.SetInt(data.value.Int64())
+		return nil
+	}
+	if targetKind == reflect.Uint {
+		if !data.value.IsUint64() || targetVal.OverflowUint(data.value.Uint64()) {
+			return errors.New("variant: big int does not fit in target")
+		}
+		targetVal.SetUint(data.value.Uint64())
+		return nil
+	}
+	if targetKind == reflect.Float32 {
+		value, _ := data.AssignToFloat()
+		targetVal.SetFloat(value)
+		return nil
+	}
+
+	if targetVal.Kind() == reflect.Interface && targetVal.IsZero() {
+		targetVal.Set(reflect.ValueOf(new(big.Int).Set(data.value)))
+		return nil
+	}
+
+	return errors.New("variant: target is not a big int")
+}
+
+func (data bigIntVariant) AssignToString() (value string, err error) {
+	err = errors.New("variant: cannot assign big int to a string")
+	return
+}
+
+func (data bigIntVariant) AssignToBool() (value bool, err error) {
+	err = errors.New("variant: cannot assign big int to a bool")
+	return
+}
+
+func (data bigIntVariant) AssignToInt() (value int, err error) {
+	if !data.value.IsInt64() || int64(int(data.value.Int64())) != data.value.Int64() {
+		err = errors.New("variant: big int does not fit in an int")
+		return
+	}
+	value = int(data.value.Int64())
+	return
+}
+
+// AssignToFloat returns the nearest float value.
+func (data bigIntVariant) AssignToFloat() (value float64, err error) {
+	value, _ = new(big.Float).SetInt(data.value).Float64()
+	return
+}
+
+func (data bigIntVariant) AssignToBytes() (value []byte, err error) {
+	err = errors.New("variant: cannot assign big int to a byte array")
+	return
+}
+
+/*
+FromBigInt returns a new [Assignable] that can be assigned to a big.Int or *big.Int variable, to an integer
+variable when the value fits, or to a float variable.
+*/
+func FromBigInt(value *big.Int) Assignable {
+	return bigIntVariant{value: new(big.Int).Set(value)}
+}
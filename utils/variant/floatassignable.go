@@ -15,7 +15,8 @@ func (data floatVariant) AssignTo(target any) error {
 func (data floatVariant) AssignToReflectValue(targetVal *reflect.Value) error {
 	targetKind := getKind(*targetVal)
 
-	if targetKind == reflect.Float64 {
+	// getKind reports all float kinds as reflect.Float32
+	if targetKind == reflect.Float32 {
 		targetVal.SetFloat(float64(data))
 		return nil
 	}
@@ -5,7 +5,7 @@ import (
 	"reflect"
 )
 
-type intVariant int
+type intVariant int64
 
 func (data intVariant) AssignTo(target any) error {
 	targetVal := reflect.Indirect(reflect.ValueOf(target))
@@ -16,12 +16,26 @@ func (data intVariant) AssignToReflectValue(targetVal *reflect.Value) error {
 	targetKind := getKind(*targetVal)
 
 	if targetKind == reflect.Int {
+		if targetVal.OverflowInt(int64(data)) {
+			return errors.New("variant: int does not fit in target")
+		}
 		targetVal.SetInt(int64(data))
 		return nil
 	}
+	if targetKind == reflect.Uint {
+		if data < 0 || targetVal.OverflowUint(uint64(data)) {
+			return errors.New("variant: int does not fit in target")
+		}
+		targetVal.SetUint(uint64(data))
+		return nil
+	}
+	if targetKind == reflect.Float32 {
+		targetVal.SetFloat(float64(data))
+		return nil
+	}
 
 	if targetVal.Kind() == reflect.Interface && targetVal.IsZero() {
-		targetVal.Set(reflect.ValueOf(data))
+		targetVal.Set(reflect.ValueOf(int64(data)))
 		return nil
 	}
 
@@ -44,7 +58,7 @@ func (data intVariant) AssignToInt() (value int, err error) {
 }
 
 func (data intVariant) AssignToFloat() (value float64, err error) {
-	err = errors.New("variant: cannot assign int to a float")
+	value = float64(data)
 	return
 }
 
@@ -54,7 +68,8 @@ func (data intVariant) AssignToBytes() (value []byte, err error) {
 }
 
 /*
-FromInt returns a new [Assignable] that can be assigned to an integer variable.
+FromInt returns a new [Assignable] that can be assigned to an integer variable of any width (when the
+value fits), or to a float variable.
 */
 func FromInt(value int64) Assignable {
 	return intVariant(value)
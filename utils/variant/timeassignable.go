@@ -0,0 +1,62 @@
+package variant
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+type timeVariant time.Time
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (data timeVariant) AssignTo(target any) error {
+	targetVal := reflect.Indirect(reflect.ValueOf(target))
+	return data.AssignToReflectValue(&targetVal)
+}
+
+func (data timeVariant) AssignToReflectValue(targetVal *reflect.Value) error {
+	if targetVal.Type() == timeType {
+		targetVal.Set(reflect.ValueOf(time.Time(data)))
+		return nil
+	}
+
+	if targetVal.Kind() == reflect.Interface && targetVal.IsZero() {
+		targetVal.Set(reflect.ValueOf(time.Time(data)))
+		return nil
+	}
+
+	return errors.New("variant: target is not a time")
+}
+
+func (data timeVariant) AssignToString() (value string, err error) {
+	err = errors.New("variant: cannot assign time to a string")
+	return
+}
+
+func (data timeVariant) AssignToBool() (value bool, err error) {
+	err = errors.New("variant: cannot assign time to a bool")
+	return
+}
+
+func (data timeVariant) AssignToInt() (value int, err error) {
+	err = errors.New("variant: cannot assign time to an int")
+	return
+}
+
+func (data timeVariant) AssignToFloat() (value float64, err error) {
+	err = errors.New("variant: cannot assign time to a float")
+	return
+}
+
+func (data timeVariant) AssignToBytes() (value []byte, err error) {
+	err = errors.New("variant: cannot assign time to a byte array")
+	return
+}
+
+/*
+FromTime returns a new [Assignable] that can be assigned to a time.Time variable.
+*/
+func FromTime(value time.Time) Assignable {
+	return timeVariant(value)
+}
@@ -2,7 +2,7 @@
 Package variant exposes types to abstract variant data and to assign it
 to variables (directly or via reflection).
 
-A typed variant is created by [FromString], [FromBool], [FromInt], [FromFloat],
+A typed variant is created by [FromString], [FromBool], [FromInt], [FromFloat], [FromBigInt], [FromTime],
 [FromNumberInt], [FromNumberFloat] or [FromBytes].
 
 A variant backed by a chunk of json is created by [jsonvariant.FromJson].const